@@ -1,6 +1,7 @@
 package main
 
 import (
+	"errors"
 	"flag"
 	"fmt"
 	"io"
@@ -11,6 +12,7 @@ import (
 	"github.com/Victor3563/NoteLine/cli-notebook/internal/crash"
 	"github.com/Victor3563/NoteLine/cli-notebook/internal/docs"
 	"github.com/Victor3563/NoteLine/cli-notebook/internal/i18n"
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/store"
 )
 
 var version = "dev"
@@ -37,8 +39,9 @@ func main() {
 	case "init":
 		fs := flag.NewFlagSet("init", flag.ExitOnError)
 		root := fs.String("root", "", "Путь к каталогу данных (по умолчанию ~/.noteline)")
+		lang := fs.String("lang", "", "Язык полнотекстового поиска: en или ru (по умолчанию — язык интерфейса)")
 		_ = fs.Parse(args)
-		if err := cli.CmdInit(*root); err != nil {
+		if err := cli.CmdInit(*root, *lang); err != nil {
 			fmt.Fprintln(os.Stderr, "init:", err)
 			os.Exit(1)
 		}
@@ -49,8 +52,27 @@ func main() {
 		title := fs.String("title", "", "Заголовок заметки")
 		text := fs.String("text", "", "Текст заметки (если пусто — будет прочитан из stdin)")
 		tags := fs.String("tags", "", "Список тегов через запятую")
+		var set multiFlag
+		fs.Var(&set, "set", "Поле метаданных key=value (флаг можно повторять)")
+		inEditor := fs.Bool("edit", false, "Написать заметку в $VISUAL/$EDITOR (флаги заполняют шаблон)")
 		_ = fs.Parse(args)
 
+		meta, err := cli.ParseSet(set)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "create:", err)
+			os.Exit(2)
+		}
+
+		if *inEditor {
+			id, err := cli.CmdCreateEdit(*root, *title, *text, splitCSV(*tags), meta)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "%s: %v\n", i18n.T("cmd.create"), err)
+				os.Exit(1)
+			}
+			fmt.Println(id)
+			return
+		}
+
 		body := strings.TrimSpace(*text)
 		if body == "" {
 
@@ -69,17 +91,7 @@ func main() {
 			os.Exit(2)
 		}
 
-		var tagsSlice []string
-		if strings.TrimSpace(*tags) != "" {
-			for _, t := range strings.Split(*tags, ",") {
-				t = strings.TrimSpace(t)
-				if t != "" {
-					tagsSlice = append(tagsSlice, t)
-				}
-			}
-		}
-
-		id, err := cli.CmdCreate(*root, *title, body, tagsSlice)
+		id, err := cli.CmdCreate(*root, *title, body, splitCSV(*tags), meta)
 		if err != nil {
 			fmt.Fprintf(os.Stderr, "%s: %v\n", i18n.T("cmd.create"), err)
 			os.Exit(1)
@@ -89,16 +101,11 @@ func main() {
 	case "read":
 		fs := flag.NewFlagSet("read", flag.ExitOnError)
 		root := fs.String("root", "", "Путь к каталогу данных (по умолчанию ~/.noteline)")
-		id := fs.String("id", "", "ID заметки")
+		ref := newRefFlags(fs)
 		asJSON := fs.Bool("json", false, "Вывести заметку в JSON")
 		_ = fs.Parse(args)
 
-		if strings.TrimSpace(*id) == "" {
-			fmt.Fprintln(os.Stderr, i18n.T("main.read_missing_id"))
-			os.Exit(2)
-		}
-
-		if err := cli.CmdRead(*root, *id, *asJSON); err != nil {
+		if err := cli.CmdRead(*root, ref.ref("read"), *asJSON); err != nil {
 			fmt.Fprintf(os.Stderr, "%s: %v\n", i18n.T("cmd.read"), err)
 			os.Exit(1)
 		}
@@ -106,89 +113,198 @@ func main() {
 	case "update":
 		fs := flag.NewFlagSet("update", flag.ExitOnError)
 		root := fs.String("root", "", "Путь к каталогу данных (по умолчанию ~/.noteline)")
-		id := fs.String("id", "", "ID заметки")
+		ref := newRefFlags(fs)
 		title := fs.String("title", "", "Новый заголовок заметки")
-		text := fs.String("text", "", "Новый текст заметки (если пусто — будет прочитан из stdin)")
+		text := fs.String("text", "", "Новый текст заметки (\"-\" — прочитать из stdin)")
 		tags := fs.String("tags", "", "Новый список тегов через запятую (полностью заменяет старый)")
+		var addTags, removeTags multiFlag
+		fs.Var(&addTags, "add-tags", "Добавить теги (через запятую, флаг можно повторять)")
+		fs.Var(&removeTags, "remove-tags", "Убрать теги (через запятую, флаг можно повторять)")
+		appendText := fs.String("append-text", "", "Дописать текст в конец заметки")
+		prependText := fs.String("prepend-text", "", "Дописать текст в начало заметки")
+		var set, unset multiFlag
+		fs.Var(&set, "set", "Задать поле метаданных key=value (флаг можно повторять)")
+		fs.Var(&unset, "unset", "Убрать поле метаданных (флаг можно повторять)")
+		ifVersion := fs.Int("if-version", 0, "Изменить, только если текущая версия заметки равна N")
 		_ = fs.Parse(args)
-
-		if strings.TrimSpace(*id) == "" {
-			fmt.Fprintln(os.Stderr, "update: требуется --id")
+		note := ref.ref("update")
+		meta, err := cli.ParseSet(set)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "update:", err)
 			os.Exit(2)
 		}
 
-		body := strings.TrimSpace(*text)
-		if body == "" {
+		opts := cli.UpdateOptions{
+			AddTags:     addTags,
+			RemoveTags:  removeTags,
+			AppendText:  *appendText,
+			PrependText: *prependText,
+			Set:         meta,
+			Unset:       unset,
+			IfVersion:   *ifVersion,
+		}
+		changed := false
+		fs.Visit(func(f *flag.Flag) {
+			switch f.Name {
+			case "root", "id", "title-match", "if-version":
+				return
+			case "title":
+				opts.Title = title
+			case "text":
+				opts.Text = text
+			case "tags":
+				opts.Tags = &[]string{*tags}
+			}
+			changed = true
+		})
+		// Текст читается из stdin по --text - или, как раньше, если кроме
+		// --id ничего не передано.
+		if !changed || *text == "-" {
 			data, err := io.ReadAll(os.Stdin)
 			if err == nil {
-				body = strings.TrimSpace(string(data))
+				body := strings.TrimSpace(string(data))
+				opts.Text = &body
 			}
 		}
 
-		if strings.TrimSpace(*title) == "" {
-			fmt.Fprintln(os.Stderr, "update: требуется --title (непустой)")
-			os.Exit(2)
-		}
-		if body == "" {
-			fmt.Fprintln(os.Stderr, "update: требуется текст: укажи --text или подай stdin")
-			os.Exit(2)
-		}
-
-		var tagsSlice []string
-		if strings.TrimSpace(*tags) != "" {
-			for _, t := range strings.Split(*tags, ",") {
-				t = strings.TrimSpace(t)
-				if t != "" {
-					tagsSlice = append(tagsSlice, t)
-				}
+		if err := cli.CmdUpdate(*root, note, opts); err != nil {
+			fmt.Fprintln(os.Stderr, "update:", err)
+			// Отдельный код, чтобы скрипты могли перечитать заметку и
+			// повторить попытку.
+			if errors.Is(err, store.ErrConflict) {
+				os.Exit(3)
 			}
+			os.Exit(1)
 		}
 
-		if err := cli.CmdUpdate(*root, *id, *title, body, tagsSlice); err != nil {
-			fmt.Fprintln(os.Stderr, "update:", err)
+	case "edit":
+		fs := flag.NewFlagSet("edit", flag.ExitOnError)
+		root := fs.String("root", "", "Путь к каталогу данных (по умолчанию ~/.noteline)")
+		ref := newRefFlags(fs)
+		_ = fs.Parse(args)
+
+		if err := cli.CmdEdit(*root, ref.ref("edit")); err != nil {
+			fmt.Fprintln(os.Stderr, "edit:", err)
+			if errors.Is(err, store.ErrConflict) {
+				os.Exit(3)
+			}
 			os.Exit(1)
 		}
 
 	case "delete":
 		fs := flag.NewFlagSet("delete", flag.ExitOnError)
 		root := fs.String("root", "", "Путь к каталогу данных (по умолчанию ~/.noteline)")
-		id := fs.String("id", "", "ID заметки")
+		ref := newRefFlags(fs)
 		_ = fs.Parse(args)
 
-		if strings.TrimSpace(*id) == "" {
-			fmt.Fprintln(os.Stderr, "delete: требуется --id")
+		if err := cli.CmdDelete(*root, ref.ref("delete")); err != nil {
+			fmt.Fprintln(os.Stderr, "delete:", err)
+			os.Exit(1)
+		}
+
+	case "history":
+		fs := flag.NewFlagSet("history", flag.ExitOnError)
+		root := fs.String("root", "", "Путь к каталогу данных (по умолчанию ~/.noteline)")
+		ref := newRefFlags(fs)
+		asJSON := fs.Bool("json", false, "Вывести историю в JSON")
+		_ = fs.Parse(args)
+
+		if err := cli.CmdHistory(*root, ref.ref("history"), *asJSON); err != nil {
+			fmt.Fprintln(os.Stderr, "history:", err)
+			os.Exit(1)
+		}
+
+	case "restore":
+		fs := flag.NewFlagSet("restore", flag.ExitOnError)
+		root := fs.String("root", "", "Путь к каталогу данных (по умолчанию ~/.noteline)")
+		ref := newRefFlags(fs)
+		version := fs.Int("version", 0, "Номер версии из history (по умолчанию — последняя до удаления)")
+		at := fs.String("at", "", "Восстановить версию, действовавшую на указанный момент")
+		_ = fs.Parse(args)
+
+		if *version != 0 && strings.TrimSpace(*at) != "" {
+			fmt.Fprintln(os.Stderr, "restore: укажи либо --version, либо --at")
 			os.Exit(2)
 		}
-		if err := cli.CmdDelete(*root, *id); err != nil {
-			fmt.Fprintln(os.Stderr, "delete:", err)
+		if err := cli.CmdRestore(*root, ref.ref("restore"), *version, *at); err != nil {
+			fmt.Fprintln(os.Stderr, "restore:", err)
 			os.Exit(1)
 		}
 
 	case "list":
 		fs := flag.NewFlagSet("list", flag.ExitOnError)
 		root := fs.String("root", "", "Путь к каталогу данных (по умолчанию ~/.noteline)")
-		tag := fs.String("tag", "", "Фильтр по тегу (входит в список тегов)")
+		var tags, anyTags, notTags multiFlag
+		fs.Var(&tags, "tag", "Заметки со всеми указанными тегами (повторяемый, через запятую)")
+		fs.Var(&anyTags, "any-tag", "Заметки хотя бы с одним из тегов (повторяемый, через запятую)")
+		fs.Var(&notTags, "not-tag", "Исключить заметки с любым из тегов (повторяемый, через запятую)")
+		var where multiFlag
+		fs.Var(&where, "where", "Условие на поле метаданных: key=value, key!=value, key>N, key (повторяемый)")
 		contains := fs.String("contains", "", "Фильтр по вхождению подстроки в заголовок/текст")
+		query := fs.String("query", "", "Запрос: title:, text:, tag:, created:, updated:, meta.KEY:, \"фраза\", OR, NOT/-, скобки")
+		since := fs.String("since", "", "Созданные не раньше: 2025-01-02, 7d, today, yesterday, RFC3339")
+		until := fs.String("until", "", "Созданные не позже (день включительно)")
+		updatedSince := fs.String("updated-since", "", "Изменённые не раньше")
 		limit := fs.Int("limit", 0, "Ограничить количество результатов")
+		color := fs.String("color", "auto", "Подсветка совпадений: auto, always или never")
 		asJSON := fs.Bool("json", false, "Вывести список в JSON")
 		_ = fs.Parse(args)
 
-		if err := cli.CmdList(*root, *tag, *contains, *limit, *asJSON); err != nil {
+		opts := cli.ListOptions{
+			Tags:         tags,
+			AnyTags:      anyTags,
+			NotTags:      notTags,
+			Contains:     *contains,
+			Query:        joinQuery(*query, fs.Args()),
+			Where:        where,
+			Since:        *since,
+			Until:        *until,
+			UpdatedSince: *updatedSince,
+			Limit:        *limit,
+			Color:        *color,
+			JSON:         *asJSON,
+		}
+		if err := cli.CmdList(*root, opts); err != nil {
 			fmt.Fprintf(os.Stderr, "%s: %v\n", i18n.T("cmd.list"), err)
 			os.Exit(1)
 		}
 
 	case "search":
-		// алиас для list, просто логически другая команда
 		fs := flag.NewFlagSet("search", flag.ExitOnError)
 		root := fs.String("root", "", "Путь к каталогу данных (по умолчанию ~/.noteline)")
-		tag := fs.String("tag", "", "Фильтр по тегу (точное совпадение)")
+		var tags, anyTags, notTags multiFlag
+		fs.Var(&tags, "tag", "Заметки со всеми указанными тегами (повторяемый, через запятую)")
+		fs.Var(&anyTags, "any-tag", "Заметки хотя бы с одним из тегов (повторяемый, через запятую)")
+		fs.Var(&notTags, "not-tag", "Исключить заметки с любым из тегов (повторяемый, через запятую)")
+		var where multiFlag
+		fs.Var(&where, "where", "Условие на поле метаданных: key=value, key!=value, key>N, key (повторяемый)")
 		contains := fs.String("contains", "", "Фильтр по вхождению подстроки в заголовок/текст")
+		query := fs.String("query", "", "Запрос: title:, text:, tag:, created:, updated:, meta.KEY:, \"фраза\", OR, NOT/-, скобки")
+		since := fs.String("since", "", "Созданные не раньше: 2025-01-02, 7d, today, yesterday, RFC3339")
+		until := fs.String("until", "", "Созданные не позже (день включительно)")
+		updatedSince := fs.String("updated-since", "", "Изменённые не раньше")
 		limit := fs.Int("limit", 0, "Ограничить количество результатов")
-		asJSON := fs.Bool("json", false, "Вывести список в JSON")
+		sortBy := fs.String("sort", "relevance", "Порядок результатов: relevance, created, updated или title")
+		color := fs.String("color", "auto", "Подсветка совпадений: auto, always или never")
+		asJSON := fs.Bool("json", false, "Вывести результаты в JSON с оценкой релевантности и отрывками")
 		_ = fs.Parse(args)
 
-		if err := cli.CmdList(*root, *tag, *contains, *limit, *asJSON); err != nil {
+		opts := cli.ListOptions{
+			Tags:         tags,
+			AnyTags:      anyTags,
+			NotTags:      notTags,
+			Contains:     *contains,
+			Query:        joinQuery(*query, fs.Args()),
+			Where:        where,
+			Since:        *since,
+			Until:        *until,
+			UpdatedSince: *updatedSince,
+			Limit:        *limit,
+			Sort:         *sortBy,
+			Color:        *color,
+			JSON:         *asJSON,
+		}
+		if err := cli.CmdSearch(*root, opts); err != nil {
 			fmt.Fprintln(os.Stderr, "search:", err)
 			os.Exit(1)
 		}
@@ -200,6 +316,8 @@ func main() {
 		exts := fs.String("ext", "md,markdown,txt", "Список расширений через запятую (без точки или с точкой)")
 		dryRun := fs.Bool("dry-run", false, "Показать, что будет сделано, но не изменять хранилище")
 		verbose := fs.Bool("verbose", false, "Подробный отчёт по каждому файлу")
+		prune := fs.Bool("prune", false, "Удалить заметки, файлы которых пропали из каталога")
+		tagOrphans := fs.Bool("tag-orphans", false, "С --prune: пометить такие заметки тегом orphaned вместо удаления")
 		_ = fs.Parse(args)
 
 		if strings.TrimSpace(*dir) == "" {
@@ -213,11 +331,143 @@ func main() {
 			os.Exit(2)
 		}
 
-		if err := cli.CmdImport(*root, *dir, *exts, *dryRun, *verbose); err != nil {
+		if *tagOrphans && !*prune {
+			fmt.Fprintln(os.Stderr, "import: --tag-orphans работает только вместе с --prune")
+			os.Exit(2)
+		}
+
+		opts := cli.ImportOptions{Ext: *exts, DryRun: *dryRun, Verbose: *verbose, Prune: *prune, TagOrphans: *tagOrphans}
+		if err := cli.CmdImport(*root, *dir, opts); err != nil {
 			fmt.Fprintln(os.Stderr, "import:", err)
 			os.Exit(1)
 		}
 
+	case "export":
+		fs := flag.NewFlagSet("export", flag.ExitOnError)
+		root := fs.String("root", "", "Путь к каталогу данных (по умолчанию ~/.noteline)")
+		dir := fs.String("dir", "", "Каталог, куда записать markdown-файлы")
+		tag := fs.String("tag", "", "Выгрузить только заметки с тегом (включая вложенные)")
+		layout := fs.String("layout", "flat", "Раскладка файлов: flat, by-tag или by-date")
+		_ = fs.Parse(args)
+
+		if strings.TrimSpace(*dir) == "" {
+			if rest := fs.Args(); len(rest) > 0 {
+				*dir = rest[0]
+			}
+		}
+		if strings.TrimSpace(*dir) == "" {
+			fmt.Fprintln(os.Stderr, "export: требуется указать --dir PATH или позиционный параметр каталога")
+			os.Exit(2)
+		}
+
+		if err := cli.CmdExport(*root, *dir, *tag, *layout); err != nil {
+			fmt.Fprintln(os.Stderr, "export:", err)
+			os.Exit(1)
+		}
+
+	case "sync":
+		fs := flag.NewFlagSet("sync", flag.ExitOnError)
+		root := fs.String("root", "", "Путь к каталогу данных (по умолчанию ~/.noteline)")
+		dir := fs.String("dir", "", "Каталог с markdown-файлами")
+		exts := fs.String("ext", "md,markdown,txt", "Список расширений через запятую (без точки или с точкой)")
+		dryRun := fs.Bool("dry-run", false, "Показать, что будет сделано, но ничего не изменять")
+		verbose := fs.Bool("verbose", false, "Подробный отчёт по каждому файлу")
+		_ = fs.Parse(args)
+
+		if strings.TrimSpace(*dir) == "" {
+			if rest := fs.Args(); len(rest) > 0 {
+				*dir = rest[0]
+			}
+		}
+		if strings.TrimSpace(*dir) == "" {
+			fmt.Fprintln(os.Stderr, "sync: требуется указать --dir PATH или позиционный параметр каталога")
+			os.Exit(2)
+		}
+
+		if err := cli.CmdSync(*root, *dir, *exts, *dryRun, *verbose); err != nil {
+			fmt.Fprintln(os.Stderr, "sync:", err)
+			os.Exit(1)
+		}
+
+	case "compact":
+		fs := flag.NewFlagSet("compact", flag.ExitOnError)
+		root := fs.String("root", "", "Путь к каталогу данных (по умолчанию ~/.noteline)")
+		asJSON := fs.Bool("json", false, "Вывести отчёт в JSON")
+		_ = fs.Parse(args)
+
+		if err := cli.CmdCompact(*root, *asJSON); err != nil {
+			fmt.Fprintln(os.Stderr, "compact:", err)
+			os.Exit(1)
+		}
+
+	case "reindex":
+		fs := flag.NewFlagSet("reindex", flag.ExitOnError)
+		root := fs.String("root", "", "Путь к каталогу данных (по умолчанию ~/.noteline)")
+		_ = fs.Parse(args)
+
+		if err := cli.CmdReindex(*root); err != nil {
+			fmt.Fprintln(os.Stderr, "reindex:", err)
+			os.Exit(1)
+		}
+
+	case "tags":
+		sub := "list"
+		if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+			sub, args = args[0], args[1:]
+		}
+		fs := flag.NewFlagSet("tags "+sub, flag.ExitOnError)
+		root := fs.String("root", "", "Путь к каталогу данных (по умолчанию ~/.noteline)")
+
+		var err error
+		switch sub {
+		case "list":
+			tree := fs.Bool("tree", false, "Показать иерархию тегов a/b/c с числом заметок в каждом узле")
+			asJSON := fs.Bool("json", false, "Вывести теги в JSON")
+			_ = fs.Parse(args)
+			err = cli.CmdTags(*root, *tree, *asJSON)
+		case "rename":
+			pos := parseInterspersed(fs, args)
+			if len(pos) != 2 {
+				fmt.Fprintln(os.Stderr, "tags rename: нужно указать OLD и NEW")
+				os.Exit(2)
+			}
+			err = cli.CmdTagsRename(*root, pos[:1], pos[1])
+		case "merge":
+			into := fs.String("into", "", "Тег, в который сливаются остальные")
+			pos := parseInterspersed(fs, args)
+			if len(pos) == 0 || strings.TrimSpace(*into) == "" {
+				fmt.Fprintln(os.Stderr, "tags merge: нужно указать теги и --into")
+				os.Exit(2)
+			}
+			err = cli.CmdTagsRename(*root, pos, *into)
+		case "rm":
+			pos := parseInterspersed(fs, args)
+			if len(pos) != 1 {
+				fmt.Fprintln(os.Stderr, "tags rm: нужно указать TAG")
+				os.Exit(2)
+			}
+			err = cli.CmdTagsRemove(*root, pos[0])
+		default:
+			fmt.Fprintf(os.Stderr, "tags: неизвестная подкоманда %q (list, rename, merge, rm)\n", sub)
+			os.Exit(2)
+		}
+		if err != nil {
+			fmt.Fprintln(os.Stderr, "tags:", err)
+			os.Exit(1)
+		}
+
+	case "fsck":
+		fs := flag.NewFlagSet("fsck", flag.ExitOnError)
+		root := fs.String("root", "", "Путь к каталогу данных (по умолчанию ~/.noteline)")
+		repair := fs.Bool("repair", false, "Исправить найденные проблемы")
+		asJSON := fs.Bool("json", false, "Вывести отчёт в JSON")
+		_ = fs.Parse(args)
+
+		if err := cli.CmdFsck(*root, *repair, *asJSON); err != nil {
+			fmt.Fprintln(os.Stderr, "fsck:", err)
+			os.Exit(1)
+		}
+
 	case "completion":
 		fs := flag.NewFlagSet("completion", flag.ExitOnError)
 		shell := fs.String("shell", "", "Тип оболочки: bash, zsh или fish")
@@ -255,3 +505,68 @@ func main() {
 		os.Exit(2)
 	}
 }
+
+// multiFlag собирает значения повторяемого флага.
+type multiFlag []string
+
+func (f *multiFlag) String() string { return strings.Join(*f, ",") }
+
+func (f *multiFlag) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
+
+// joinQuery склеивает --query и позиционные аргументы в один запрос.
+func joinQuery(query string, args []string) string {
+	parts := append([]string{query}, args...)
+	return strings.TrimSpace(strings.Join(parts, " "))
+}
+
+// parseInterspersed разбирает флаги, стоящие и до, и после позиционных
+// аргументов (tags merge a b --into c), и возвращает позиционные.
+func parseInterspersed(fs *flag.FlagSet, args []string) []string {
+	var pos []string
+	for {
+		_ = fs.Parse(args)
+		args = fs.Args()
+		if len(args) == 0 {
+			return pos
+		}
+		pos = append(pos, args[0])
+		args = args[1:]
+	}
+}
+
+// splitCSV разбивает список через запятую, пропуская пустые элементы.
+func splitCSV(s string) []string {
+	var out []string
+	for _, v := range strings.Split(s, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// refFlags — флаги, которыми команда указывает на заметку.
+type refFlags struct {
+	id, title *string
+}
+
+func newRefFlags(fs *flag.FlagSet) refFlags {
+	return refFlags{
+		id:    fs.String("id", "", "ID заметки или его уникальный префикс (от 4 символов)"),
+		title: fs.String("title-match", "", "Точный заголовок заметки вместо --id"),
+	}
+}
+
+// ref возвращает ссылку на заметку; если не указан ровно один из --id и
+// --title-match, завершает программу.
+func (f refFlags) ref(cmd string) cli.NoteRef {
+	id, title := strings.TrimSpace(*f.id), *f.title
+	if (id == "") == (title == "") {
+		fmt.Fprintln(os.Stderr, i18n.T("main.missing_ref", cmd))
+		os.Exit(2)
+	}
+	return cli.NoteRef{ID: id, Title: title}
+}
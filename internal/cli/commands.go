@@ -2,10 +2,13 @@ package cli
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
-	"regexp"
+	"reflect"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
@@ -24,12 +27,48 @@ func defaultRoot(root string) string {
 	return filepath.Join(home, ".noteline")
 }
 
-func CmdInit(root string) error {
+// CmdInit создаёт хранилище. Непустой lang задаёт язык полнотекстового
+// поиска (en или ru); у существующего хранилища индекс перестраивается.
+func CmdInit(root, lang string) error {
 	root = defaultRoot(root)
-	return store.Ensure(root)
+	if err := store.Ensure(root); err != nil {
+		return err
+	}
+	lang = strings.ToLower(strings.TrimSpace(lang))
+	if lang == "" {
+		return nil
+	}
+
+	s, err := store.Open(root)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+	return s.SetLanguage(lang)
 }
 
-func CmdCreate(root, title, text string, tags []string) (string, error) {
+// ParseSet разбирает значения --set вида key=value в поля Meta; типы
+// значений — как у model.ParseMetaValue.
+func ParseSet(pairs []string) (map[string]any, error) {
+	var meta map[string]any
+	for _, p := range pairs {
+		k, v, ok := strings.Cut(p, "=")
+		if !ok {
+			return nil, fmt.Errorf("--set %q: ожидается ключ=значение", p)
+		}
+		key, err := model.CleanMetaKey(k)
+		if err != nil {
+			return nil, fmt.Errorf("--set %q: %v", p, err)
+		}
+		if meta == nil {
+			meta = make(map[string]any)
+		}
+		meta[key] = model.ParseMetaValue(v)
+	}
+	return meta, nil
+}
+
+func CmdCreate(root, title, text string, tags []string, meta map[string]any) (string, error) {
 	root = defaultRoot(root)
 	s, err := store.Open(root)
 	if err != nil {
@@ -38,20 +77,43 @@ func CmdCreate(root, title, text string, tags []string) (string, error) {
 	defer s.Close()
 
 	n := model.NewNote(title, text, tags)
+	if n.Meta, err = model.NormalizeMeta(meta); err != nil {
+		return "", err
+	}
 	if err := s.Append(n); err != nil {
 		return "", err
 	}
 	return n.ID, nil
 }
 
-func CmdRead(root, id string, asJSON bool) error {
+// NoteRef указывает на заметку: ID или его уникальный префикс либо
+// точный заголовок.
+type NoteRef struct {
+	ID, Title string
+}
+
+// IDRef — ссылка на заметку по ID или префиксу.
+func IDRef(id string) NoteRef { return NoteRef{ID: id} }
+
+func (r NoteRef) resolve(s *store.Store) (string, error) {
+	if r.Title != "" {
+		return s.ResolveTitle(r.Title)
+	}
+	return s.ResolveID(r.ID)
+}
+
+func CmdRead(root string, ref NoteRef, asJSON bool) error {
 	root = defaultRoot(root)
-	s, err := store.Open(root)
+	s, err := store.OpenWith(root, store.Options{Lock: store.LockShared})
 	if err != nil {
 		return err
 	}
 	defer s.Close()
 
+	id, err := ref.resolve(s)
+	if err != nil {
+		return err
+	}
 	n, err := s.GetByID(id)
 	if err != nil {
 		return err
@@ -71,61 +133,304 @@ func CmdRead(root, id string, asJSON bool) error {
 	if !n.UpdatedAt.IsZero() && !n.UpdatedAt.Equal(n.CreatedAt) {
 		fmt.Printf(i18n.T("cmd.updated")+"\n", n.UpdatedAt.Format("2006-01-02 15:04:05"))
 	}
+	fmt.Printf(i18n.T("cmd.version")+"\n", n.Version)
+	keys := make([]string, 0, len(n.Meta))
+	for k := range n.Meta {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Printf(i18n.T("cmd.meta")+"\n", k, model.MetaString(n.Meta[k]))
+	}
 	fmt.Println(i18n.T("cmd.sep"))
 	fmt.Println(n.Text)
 	return nil
 }
 
-func CmdList(root, tag, contains string, limit int, asJSON bool) error {
+// ListOptions — параметры list и search. Элементы Tags, AnyTags и NotTags
+// могут содержать несколько тегов через запятую. Since, Until и
+// UpdatedSince принимают форматы store.ParseTimeBound; Until включает
+// указанный день.
+type ListOptions struct {
+	Tags         []string
+	AnyTags      []string
+	NotTags      []string
+	Contains     string
+	Query        string
+	Where        []string
+	Since        string
+	Until        string
+	UpdatedSince string
+	Limit        int
+	Sort         string
+	Color        string
+	JSON         bool
+}
+
+func (o ListOptions) filter(now time.Time) (store.Filter, error) {
+	f := store.Filter{
+		Tags:     splitTags(o.Tags),
+		AnyTags:  splitTags(o.AnyTags),
+		NotTags:  splitTags(o.NotTags),
+		Contains: strings.TrimSpace(o.Contains),
+		Query:    o.Query,
+		Where:    o.Where,
+		Limit:    o.Limit,
+		Sort:     strings.ToLower(strings.TrimSpace(o.Sort)),
+	}
+	bound := func(flag, v string) (from, to time.Time, err error) {
+		if strings.TrimSpace(v) == "" {
+			return time.Time{}, time.Time{}, nil
+		}
+		from, to, err = store.ParseTimeBound(v, now)
+		if err != nil {
+			return from, to, fmt.Errorf("--%s: %w", flag, err)
+		}
+		return from, to, nil
+	}
+
+	var err error
+	if f.Since, _, err = bound("since", o.Since); err != nil {
+		return f, err
+	}
+	from, to, err := bound("until", o.Until)
+	if err != nil {
+		return f, err
+	}
+	f.Until = from
+	if !to.Equal(from) {
+		f.Until = to.Add(-time.Nanosecond)
+	}
+	if f.UpdatedSince, _, err = bound("updated-since", o.UpdatedSince); err != nil {
+		return f, err
+	}
+	return f, nil
+}
+
+// splitTags разворачивает значения повторяемых флагов вида "a,b".
+func splitTags(vals []string) []string {
+	var out []string
+	for _, v := range vals {
+		for _, t := range strings.Split(v, ",") {
+			if t = strings.TrimSpace(t); t != "" {
+				out = append(out, t)
+			}
+		}
+	}
+	return out
+}
+
+func CmdList(root string, opts ListOptions) error {
 	root = defaultRoot(root)
-	s, err := store.Open(root)
+	colored, err := useColor(opts.Color)
+	if err != nil {
+		return err
+	}
+	opts.Sort = store.SortCreated
+	filter, err := opts.filter(time.Now())
+	if err != nil {
+		return err
+	}
+	s, err := store.OpenWith(root, store.Options{Lock: store.LockShared})
 	if err != nil {
 		return err
 	}
 	defer s.Close()
 
-	filter := store.Filter{
-		Tag:      strings.TrimSpace(tag),
-		Contains: strings.TrimSpace(contains),
-		Limit:    limit,
-	}
-	list, err := s.List(filter)
+	hits, err := s.Search(filter)
 	if err != nil {
 		return err
 	}
 
-	if asJSON {
+	if opts.JSON {
+		list := make([]model.Note, 0, len(hits))
+		for _, h := range hits {
+			list = append(list, h.Note)
+		}
 		enc := json.NewEncoder(os.Stdout)
 		enc.SetIndent("", "  ")
 		return enc.Encode(list)
 	}
 
-	for i, n := range list {
-		if limit > 0 && i >= limit {
-			break
+	short := s.ShortIDs()
+	for i := range hits {
+		printListItem(&hits[i], short, colored)
+	}
+
+	return nil
+}
+
+// CmdSearch печатает результаты поиска по запросу opts.Query (синтаксис
+// описан в store/query.go); по умолчанию они упорядочены по релевантности.
+// В JSON у каждой заметки есть поле score и отрывки с байтовыми смещениями
+// совпадений.
+func CmdSearch(root string, opts ListOptions) error {
+	root = defaultRoot(root)
+	colored, err := useColor(opts.Color)
+	if err != nil {
+		return err
+	}
+	filter, err := opts.filter(time.Now())
+	if err != nil {
+		return err
+	}
+	s, err := store.OpenWith(root, store.Options{Lock: store.LockShared})
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	hits, err := s.Search(filter)
+	if err != nil {
+		return err
+	}
+
+	if opts.JSON {
+		if hits == nil {
+			hits = []store.Hit{}
 		}
-		if len(n.Title) > 0 {
-			fmt.Printf("[%s] %s\n", n.ID, n.Title)
-		} else {
-			fmt.Printf("[%s]\n", n.ID)
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(hits)
+	}
+
+	short := s.ShortIDs()
+	for i := range hits {
+		printListItem(&hits[i], short, colored)
+		if hits[i].Score > 0 {
+			fmt.Printf(i18n.T("cmd.score_indented")+"\n", hits[i].Score)
 		}
-		if len(n.Tags) > 0 {
-			fmt.Printf(i18n.T("cmd.tags_indented")+"\n", strings.Join(n.Tags, ", "))
+	}
+	return nil
+}
+
+// printListItem печатает заметку с кратчайшим уникальным префиксом ID из
+// short; JSON-вывод по-прежнему содержит полные ID.
+func printListItem(h *store.Hit, short map[string]string, color bool) {
+	n := &h.Note
+	id := n.ID
+	if p, ok := short[id]; ok {
+		id = p
+	}
+	if len(n.Title) > 0 {
+		fmt.Printf("[%s] %s\n", id, n.Title)
+	} else {
+		fmt.Printf("[%s]\n", id)
+	}
+	if len(n.Tags) > 0 {
+		fmt.Printf(i18n.T("cmd.tags_indented")+"\n", strings.Join(n.Tags, ", "))
+	}
+	fmt.Printf(i18n.T("cmd.created_indented")+"\n", n.CreatedAt.Format("2006-01-02 15:04:05"))
+	if !n.UpdatedAt.Equal(n.CreatedAt) {
+		fmt.Printf(i18n.T("cmd.updated_indented")+"\n", n.UpdatedAt.Format("2006-01-02 15:04:05"))
+	}
+
+	for _, fr := range h.Fragments {
+		fmt.Printf(i18n.T("cmd.match_in")+"\n", fr.Field, renderFragment(n, fr, color))
+	}
+}
+
+// UpdateOptions — изменения для update. Поля с nil и пустые списки
+// заметку не трогают.
+type UpdateOptions struct {
+	Title, Text *string
+	// Tags заменяет теги целиком; AddTags и RemoveTags применяются после
+	// него.
+	Tags                    *[]string
+	AddTags, RemoveTags     []string
+	AppendText, PrependText string
+	// Set задаёт поля Meta, Unset убирает их; Unset применяется первым.
+	Set   map[string]any
+	Unset []string
+	// IfVersion, если не 0, — версия, от которой сделаны изменения: если
+	// заметка успела измениться, update завершается с store.ErrConflict.
+	IfVersion int
+}
+
+func (o UpdateOptions) empty() bool {
+	return o.Title == nil && o.Text == nil && o.Tags == nil &&
+		len(o.AddTags) == 0 && len(o.RemoveTags) == 0 && o.AppendText == "" && o.PrependText == "" &&
+		len(o.Set) == 0 && len(o.Unset) == 0
+}
+
+func (o UpdateOptions) apply(n *model.Note) error {
+	if o.IfVersion != 0 {
+		if err := store.CheckVersion(n, o.IfVersion); err != nil {
+			return err
+		}
+	}
+	if o.Title != nil {
+		if strings.TrimSpace(*o.Title) == "" {
+			return errors.New("заголовок не может быть пустым")
 		}
-		fmt.Printf(i18n.T("cmd.created_indented")+"\n", n.CreatedAt.Format("2006-01-02 15:04:05"))
+		n.Title = *o.Title
+	}
+	if o.Text != nil {
+		n.Text = *o.Text
+	}
+	if o.PrependText != "" {
+		n.Text = joinText(o.PrependText, n.Text)
+	}
+	if o.AppendText != "" {
+		n.Text = joinText(n.Text, o.AppendText)
+	}
+	// Текст проверяется, только если его меняют: правка тегов или Meta
+	// заметки с пустым текстом (например, импортированной из файла с
+	// одним front matter) допустима.
+	textChanged := o.Text != nil || o.PrependText != "" || o.AppendText != ""
+	if textChanged && strings.TrimSpace(n.Text) == "" {
+		return errors.New("текст не может быть пустым")
+	}
 
-		if strings.TrimSpace(contains) != "" && !asJSON {
-			sn := highlightSnippet(&n, contains)
-			if sn != "" {
-				fmt.Printf(i18n.T("cmd.match")+"\n", sn)
-			}
+	if o.Tags != nil {
+		n.Tags = splitTags(*o.Tags)
+	}
+	remove := map[string]bool{}
+	for _, t := range splitTags(o.RemoveTags) {
+		remove[model.CleanTag(t)] = true
+	}
+	tags := n.Tags[:0:0]
+	seen := map[string]bool{}
+	for _, t := range append(n.Tags, splitTags(o.AddTags)...) {
+		t = model.CleanTag(t)
+		if t == "" || remove[t] || seen[t] {
+			continue
 		}
+		seen[t] = true
+		tags = append(tags, t)
 	}
+	n.Tags = tags
 
+	for _, k := range o.Unset {
+		key, err := model.CleanMetaKey(k)
+		if err != nil {
+			return fmt.Errorf("--unset %q: %v", k, err)
+		}
+		delete(n.Meta, key)
+	}
+	if len(o.Set) > 0 && n.Meta == nil {
+		n.Meta = make(map[string]any, len(o.Set))
+	}
+	for k, v := range o.Set {
+		n.Meta[k] = v
+	}
 	return nil
 }
 
-func CmdUpdate(root, id, title, text string, tags []string) error {
+// joinText склеивает части текста через перевод строки.
+func joinText(a, b string) string {
+	a, b = strings.TrimRight(a, "\n"), strings.TrimLeft(b, "\n")
+	if a == "" || b == "" {
+		return a + b
+	}
+	return a + "\n" + b
+}
+
+// CmdUpdate меняет у заметки только переданные поля.
+func CmdUpdate(root string, ref NoteRef, opts UpdateOptions) error {
+	if opts.empty() {
+		return errors.New("нечего менять: укажи --title, --text, --tags или другие поля")
+	}
 	root = defaultRoot(root)
 	s, err := store.Open(root)
 	if err != nil {
@@ -133,26 +438,15 @@ func CmdUpdate(root, id, title, text string, tags []string) error {
 	}
 	defer s.Close()
 
-	old, err := s.GetByID(id)
+	id, err := ref.resolve(s)
 	if err != nil {
 		return err
 	}
-
-	now := time.Now().UTC()
-	n := &model.Note{
-		ID:        old.ID,
-		Title:     title,
-		Text:      text,
-		Tags:      tags,
-		CreatedAt: old.CreatedAt,
-		UpdatedAt: now,
-		Deleted:   false,
-	}
-
-	return s.Append(n)
+	_, err = s.Patch(id, opts.apply)
+	return err
 }
 
-func CmdDelete(root, id string) error {
+func CmdDelete(root string, ref NoteRef) error {
 	root = defaultRoot(root)
 	s, err := store.Open(root)
 	if err != nil {
@@ -160,6 +454,10 @@ func CmdDelete(root, id string) error {
 	}
 	defer s.Close()
 
+	id, err := ref.resolve(s)
+	if err != nil {
+		return err
+	}
 	old, err := s.GetByID(id)
 	if err != nil {
 		return err
@@ -171,6 +469,7 @@ func CmdDelete(root, id string) error {
 		Title:     old.Title,
 		Text:      old.Text,
 		Tags:      old.Tags,
+		Meta:      old.Meta,
 		CreatedAt: old.CreatedAt,
 		UpdatedAt: now,
 		Deleted:   true,
@@ -179,7 +478,246 @@ func CmdDelete(root, id string) error {
 	return s.Append(tomb)
 }
 
-func CmdImport(root, dir, extList string, dryRun, verbose bool) error {
+type historyEntry struct {
+	Version int        `json:"version"`
+	Note    model.Note `json:"note"`
+	Diff    []string   `json:"diff,omitempty"`
+}
+
+func noteDiff(prev, cur *model.Note) []string {
+	var out []string
+	if prev.Title != cur.Title {
+		out = append(out, fmt.Sprintf("~ title: %q -> %q", prev.Title, cur.Title))
+	}
+	if strings.Join(prev.Tags, ",") != strings.Join(cur.Tags, ",") {
+		out = append(out, fmt.Sprintf("~ tags: [%s] -> [%s]", strings.Join(prev.Tags, ", "), strings.Join(cur.Tags, ", ")))
+	}
+	out = append(out, metaDiff(prev.Meta, cur.Meta)...)
+	return append(out, diffLines(prev.Text, cur.Text)...)
+}
+
+// metaDiff описывает добавленные, изменённые и удалённые поля Meta по
+// алфавиту ключей; отсутствующее значение печатается как <none>.
+func metaDiff(prev, cur map[string]any) []string {
+	keys := make([]string, 0, len(prev)+len(cur))
+	for k := range prev {
+		keys = append(keys, k)
+	}
+	for k := range cur {
+		if _, ok := prev[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	show := func(m map[string]any, k string) string {
+		v, ok := m[k]
+		if !ok {
+			return "<none>"
+		}
+		return strconv.Quote(model.MetaString(v))
+	}
+	var out []string
+	for _, k := range keys {
+		a, aok := prev[k]
+		b, bok := cur[k]
+		if aok == bok && reflect.DeepEqual(a, b) {
+			continue
+		}
+		out = append(out, fmt.Sprintf("~ meta.%s: %s -> %s", k, show(prev, k), show(cur, k)))
+	}
+	return out
+}
+
+func CmdHistory(root string, ref NoteRef, asJSON bool) error {
+	root = defaultRoot(root)
+	s, err := store.OpenWith(root, store.Options{Lock: store.LockShared})
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	id, err := ref.resolve(s)
+	if err != nil {
+		return err
+	}
+	versions, err := s.History(id)
+	if err != nil {
+		return err
+	}
+
+	entries := make([]historyEntry, 0, len(versions))
+	for i := range versions {
+		e := historyEntry{Version: store.VersionNumber(versions, i), Note: versions[i]}
+		if i > 0 && !versions[i].Deleted {
+			e.Diff = noteDiff(&versions[i-1], &versions[i])
+		}
+		entries = append(entries, e)
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	}
+
+	for i, e := range entries {
+		if i > 0 {
+			fmt.Println()
+		}
+		fmt.Printf(i18n.T("history.version")+"\n", e.Version, e.Note.UpdatedAt.Format("2006-01-02 15:04:05"))
+		switch {
+		case e.Note.Deleted:
+			fmt.Println(i18n.T("history.deleted"))
+		case i == 0:
+			fmt.Printf(i18n.T("history.created")+"\n", e.Note.Title)
+		case len(e.Diff) == 0:
+			fmt.Println(i18n.T("history.no_changes"))
+		default:
+			for _, line := range e.Diff {
+				fmt.Println("  " + line)
+			}
+		}
+	}
+	return nil
+}
+
+func CmdRestore(root string, ref NoteRef, version int, at string) error {
+	root = defaultRoot(root)
+	s, err := store.Open(root)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	id, err := ref.resolve(s)
+	if err != nil {
+		return err
+	}
+	var n *model.Note
+	if strings.TrimSpace(at) != "" {
+		// --at разбирается как --until: неполная дата означает конец
+		// своего дня, месяца или года, время без зоны — местное.
+		from, to, err := store.ParseTimeBound(at, time.Now())
+		if err != nil {
+			return fmt.Errorf("--at: %w", err)
+		}
+		ts := from
+		if !to.Equal(from) {
+			ts = to.Add(-time.Nanosecond)
+		}
+		n, err = s.RestoreAt(id, ts.UTC())
+		if err != nil {
+			return err
+		}
+	} else {
+		n, err = s.Restore(id, version)
+		if err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf(i18n.T("restore.done")+"\n", n.ID, n.Title)
+	return nil
+}
+
+func CmdCompact(root string, asJSON bool) error {
+	root = defaultRoot(root)
+	s, err := store.Open(root)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	rep, err := s.Compact()
+	if err != nil {
+		return err
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rep)
+	}
+
+	fmt.Printf(i18n.T("compact.segments")+"\n", rep.SegmentsBefore, rep.SegmentsAfter)
+	fmt.Printf(i18n.T("compact.records")+"\n", rep.RecordsBefore, rep.RecordsAfter)
+	fmt.Printf(i18n.T("compact.bytes")+"\n", rep.BytesBefore, rep.BytesAfter)
+	fmt.Printf(i18n.T("compact.reclaimed")+"\n", rep.BytesReclaimed)
+	return nil
+}
+
+func CmdReindex(root string) error {
+	root = defaultRoot(root)
+	s, err := store.Open(root)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	n, err := s.Reindex()
+	if err != nil {
+		return err
+	}
+	fmt.Printf(i18n.T("reindex.done")+"\n", n)
+	return nil
+}
+
+func CmdFsck(root string, repair, asJSON bool) error {
+	root = defaultRoot(root)
+	s, err := store.Open(root)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	rep, err := s.Fsck(repair)
+	if err != nil {
+		return err
+	}
+	imports, err := importer.CheckIndex(root, s.Exists, repair)
+	if err != nil {
+		return err
+	}
+	rep.Issues = append(rep.Issues, imports...)
+	if rep.Issues == nil {
+		rep.Issues = []store.FsckIssue{}
+	}
+
+	if asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(rep); err != nil {
+			return err
+		}
+	} else {
+		fmt.Printf(i18n.T("fsck.summary")+"\n", rep.Segments, rep.Records, rep.LiveNotes)
+		for _, is := range rep.Issues {
+			line := fmt.Sprintf("%-16s %s: %s", is.Kind, is.Location, is.Detail)
+			if is.Repaired {
+				line += " " + i18n.T("fsck.repaired")
+			}
+			fmt.Println(line)
+		}
+		if len(rep.Issues) == 0 {
+			fmt.Println(i18n.T("fsck.ok"))
+		}
+	}
+
+	if n := rep.Unrepaired(); n > 0 {
+		return fmt.Errorf(i18n.T("fsck.unrepaired"), n)
+	}
+	return nil
+}
+
+// ImportOptions — флаги команды import.
+type ImportOptions struct {
+	Ext               string
+	DryRun, Verbose   bool
+	Prune, TagOrphans bool
+}
+
+func CmdImport(root, dir string, opts ImportOptions) error {
 	root = defaultRoot(root)
 
 	dir = filepath.Clean(dir)
@@ -192,9 +730,13 @@ func CmdImport(root, dir, extList string, dryRun, verbose bool) error {
 		return fmt.Errorf("%q не является каталогом", dir)
 	}
 
-	exts := parseExtList(extList)
-
-	rep, err := importer.ImportDir(root, dir, exts, dryRun)
+	dryRun, verbose := opts.DryRun, opts.Verbose
+	rep, err := importer.ImportDir(root, dir, importer.ImportOptions{
+		Exts:       parseExtList(opts.Ext),
+		DryRun:     dryRun,
+		Prune:      opts.Prune,
+		TagOrphans: opts.TagOrphans,
+	})
 	if err != nil {
 		return err
 	}
@@ -207,6 +749,11 @@ func CmdImport(root, dir, extList string, dryRun, verbose bool) error {
 	fmt.Fprintf(os.Stdout, "Создано новых заметок: %d\n", rep.Created)
 	fmt.Fprintf(os.Stdout, "Обновлено заметок: %d\n", rep.Updated)
 	fmt.Fprintf(os.Stdout, "Пропущено (без изменений): %d\n", rep.Skipped)
+	if opts.Prune && opts.TagOrphans {
+		fmt.Fprintf(os.Stdout, "Помечено тегом %s (файл удалён): %d\n", importer.OrphanedTag, rep.Orphaned)
+	} else if opts.Prune {
+		fmt.Fprintf(os.Stdout, "Удалено заметок (файл удалён): %d\n", rep.Deleted)
+	}
 	fmt.Fprintf(os.Stdout, "Ошибок: %d\n", rep.Errors)
 
 	if verbose && len(rep.Results) > 0 {
@@ -227,6 +774,53 @@ func CmdImport(root, dir, extList string, dryRun, verbose bool) error {
 	return nil
 }
 
+func CmdExport(root, dir, tag, layout string) error {
+	root = defaultRoot(root)
+
+	dir = filepath.Clean(dir)
+	rep, err := importer.ExportDir(root, dir, tag, layout)
+	if err != nil {
+		return err
+	}
+	fmt.Println(i18n.T("export.done", rep.Exported, rep.Dir))
+	return nil
+}
+
+func CmdSync(root, dir, extList string, dryRun, verbose bool) error {
+	root = defaultRoot(root)
+
+	dir = filepath.Clean(dir)
+	if info, err := os.Stat(dir); err != nil {
+		if os.IsNotExist(err) {
+			return fmt.Errorf("каталог %q не существует", dir)
+		}
+		return err
+	} else if !info.IsDir() {
+		return fmt.Errorf("%q не является каталогом", dir)
+	}
+
+	rep, err := importer.SyncDir(root, dir, parseExtList(extList), dryRun)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(i18n.T("sync.summary", rep.Imported, rep.Exported, rep.DeletedNotes,
+		rep.DeletedFiles, rep.Conflicts, rep.Unchanged, rep.Errors))
+	if verbose {
+		for _, r := range rep.Results {
+			line := fmt.Sprintf("%-12s %s", r.Action, r.Path)
+			if r.Error != "" {
+				line += " (" + r.Error + ")"
+			}
+			fmt.Println(line)
+		}
+	}
+	if dryRun {
+		fmt.Println(i18n.T("sync.dry_run"))
+	}
+	return nil
+}
+
 func parseExtList(list string) []string {
 	list = strings.TrimSpace(list)
 	if list == "" {
@@ -246,81 +840,3 @@ func parseExtList(list string) []string {
 	}
 	return exts
 }
-
-var tokenRe = regexp.MustCompile(`[\p{L}\p{N}]+`)
-
-func extractTokens(query string) []string {
-	if strings.TrimSpace(query) == "" {
-		return nil
-	}
-	m := tokenRe.FindAllString(query, -1)
-	out := make([]string, 0, len(m))
-	for _, t := range m {
-		t = strings.ToLower(strings.TrimSpace(t))
-		if t != "" {
-			out = append(out, t)
-		}
-	}
-	return out
-}
-
-func highlightSnippet(n *model.Note, query string) string {
-	toks := extractTokens(query)
-	if len(toks) == 0 {
-		return ""
-	}
-
-	var txt string
-	if strings.TrimSpace(n.Title) != "" {
-		if strings.TrimSpace(n.Text) != "" {
-			txt = n.Title + " — " + n.Text
-		} else {
-			txt = n.Title
-		}
-	} else {
-		txt = n.Text
-	}
-
-	esc := make([]string, 0, len(toks))
-	for _, t := range toks {
-		esc = append(esc, regexp.QuoteMeta(t))
-	}
-	pattern := `(?i)(` + strings.Join(esc, `|`) + `)`
-	re := regexp.MustCompile(pattern)
-
-	loc := re.FindStringIndex(txt)
-
-	if loc == nil {
-		tags := strings.Join(n.Tags, " ")
-		loc = re.FindStringIndex(tags)
-		if loc == nil {
-			return ""
-		}
-		txt = tags
-	}
-
-	left := 40
-	right := 40
-	start := loc[0] - left
-	if start < 0 {
-		start = 0
-	}
-	end := loc[1] + right
-	if end > len(txt) {
-		end = len(txt)
-	}
-	snippet := txt[start:end]
-
-	highlighted := re.ReplaceAllStringFunc(snippet, func(m string) string {
-
-		return "\x1b[31;1m" + m + "\x1b[0m"
-	})
-
-	if start > 0 {
-		highlighted = "..." + highlighted
-	}
-	if end < len(txt) {
-		highlighted = highlighted + "..."
-	}
-	return highlighted
-}
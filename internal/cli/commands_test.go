@@ -1,19 +1,26 @@
 package cli
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/model"
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/store"
 )
 
 func TestCmdCreateReadUpdateDelete(t *testing.T) {
 	root := filepath.Join(t.TempDir(), "store")
 
-	if err := CmdInit(root); err != nil {
+	if err := CmdInit(root, ""); err != nil {
 		t.Fatalf("CmdInit: %v", err)
 	}
 
-	id, err := CmdCreate(root, "Title", "Text", []string{"go", "cli"})
+	id, err := CmdCreate(root, "Title", "Text", []string{"go", "cli"}, nil)
 	if err != nil {
 		t.Fatalf("CmdCreate: %v", err)
 	}
@@ -21,26 +28,198 @@ func TestCmdCreateReadUpdateDelete(t *testing.T) {
 		t.Fatalf("CmdCreate returned empty id")
 	}
 
-	if err := CmdRead(root, id, false); err != nil {
+	if err := CmdRead(root, IDRef(id), false); err != nil {
 		t.Fatalf("CmdRead: %v", err)
 	}
 
-	if err := CmdList(root, "", "", 0, false); err != nil {
+	if err := CmdList(root, ListOptions{Color: "auto"}); err != nil {
 		t.Fatalf("CmdList: %v", err)
 	}
 
-	if err := CmdUpdate(root, id, "New title", "New text", []string{"go", "updated"}); err != nil {
+	title, text := "New title", "New text"
+	if err := CmdUpdate(root, IDRef(id), UpdateOptions{Title: &title, Text: &text, Tags: &[]string{"go,updated"}}); err != nil {
 		t.Fatalf("CmdUpdate: %v", err)
 	}
 
-	if err := CmdDelete(root, id); err != nil {
+	if err := CmdDelete(root, IDRef(id)); err != nil {
 		t.Fatalf("CmdDelete: %v", err)
 	}
+
+	if err := CmdHistory(root, IDRef(id), false); err != nil {
+		t.Fatalf("CmdHistory: %v", err)
+	}
+
+	if err := CmdRestore(root, IDRef(id), 0, ""); err != nil {
+		t.Fatalf("CmdRestore: %v", err)
+	}
+	if err := CmdRead(root, IDRef(id), false); err != nil {
+		t.Fatalf("CmdRead after restore: %v", err)
+	}
+
+	if err := CmdCompact(root, false); err != nil {
+		t.Fatalf("CmdCompact: %v", err)
+	}
+}
+
+func TestCmdUpdatePartial(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "store")
+	if err := CmdInit(root, ""); err != nil {
+		t.Fatalf("CmdInit: %v", err)
+	}
+	id, err := CmdCreate(root, "Title", "body", []string{"go", "cli"}, nil)
+	if err != nil {
+		t.Fatalf("CmdCreate: %v", err)
+	}
+
+	steps := []UpdateOptions{
+		{AddTags: []string{"work,go"}, RemoveTags: []string{"cli"}},
+		{AppendText: "tail"},
+		{PrependText: "head"},
+	}
+	for _, o := range steps {
+		if err := CmdUpdate(root, IDRef(id), o); err != nil {
+			t.Fatalf("CmdUpdate(%+v): %v", o, err)
+		}
+	}
+	// Создание и три правки — версия 4.
+	if err := CmdUpdate(root, IDRef(id), UpdateOptions{IfVersion: 3, AppendText: "x"}); !errors.Is(err, store.ErrConflict) {
+		t.Fatalf("CmdUpdate with stale version = %v", err)
+	}
+	empty := " "
+	for _, o := range []UpdateOptions{{}, {Title: &empty}, {Text: &empty}} {
+		if err := CmdUpdate(root, IDRef(id), o); err == nil {
+			t.Fatalf("CmdUpdate(%+v) succeeded", o)
+		}
+	}
+
+	s, err := store.OpenWith(root, store.Options{Lock: store.LockShared})
+	if err != nil {
+		t.Fatalf("OpenWith: %v", err)
+	}
+	defer s.Close()
+	n, err := s.GetByID(id)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if n.Title != "Title" || n.Text != "head\nbody\ntail" || strings.Join(n.Tags, ",") != "go,work" || n.Version != 4 {
+		t.Fatalf("note = %q %q %v v%d", n.Title, n.Text, n.Tags, n.Version)
+	}
+}
+
+func TestCmdUpdateEmptyTextNote(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "store")
+	s, err := store.Open(root)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	n := model.NewNote("Front matter only", "", nil)
+	if err := s.Append(n); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	s.Close()
+
+	// Теги и Meta заметки с пустым текстом править можно, пустой текст
+	// задать нельзя.
+	for _, o := range []UpdateOptions{
+		{AddTags: []string{"imported"}},
+		{Set: map[string]any{"status": "open"}},
+	} {
+		if err := CmdUpdate(root, IDRef(n.ID), o); err != nil {
+			t.Fatalf("CmdUpdate(%+v): %v", o, err)
+		}
+	}
+	empty := ""
+	if err := CmdUpdate(root, IDRef(n.ID), UpdateOptions{Text: &empty, AddTags: []string{"x"}}); err == nil {
+		t.Fatalf("CmdUpdate with empty --text succeeded")
+	}
+}
+
+func TestCmdMeta(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "store")
+	if err := CmdInit(root, ""); err != nil {
+		t.Fatalf("CmdInit: %v", err)
+	}
+	for _, bad := range [][]string{{"status"}, {"title=x"}, {"=x"}} {
+		if _, err := ParseSet(bad); err == nil {
+			t.Fatalf("ParseSet(%q) succeeded", bad)
+		}
+	}
+	meta, err := ParseSet([]string{"Status=open", "points=3", "draft=true"})
+	if err != nil {
+		t.Fatalf("ParseSet: %v", err)
+	}
+	id, err := CmdCreate(root, "Title", "body", nil, meta)
+	if err != nil {
+		t.Fatalf("CmdCreate: %v", err)
+	}
+	if _, err := CmdCreate(root, "Other", "body", nil, nil); err != nil {
+		t.Fatalf("CmdCreate: %v", err)
+	}
+	if err := CmdRead(root, IDRef(id), false); err != nil {
+		t.Fatalf("CmdRead: %v", err)
+	}
+
+	set, _ := ParseSet([]string{"status=done", "owner=ann", "version=7"})
+	if err := CmdUpdate(root, IDRef(id), UpdateOptions{Set: set, Unset: []string{"Draft"}}); err != nil {
+		t.Fatalf("CmdUpdate: %v", err)
+	}
+	if err := CmdUpdate(root, IDRef(id), UpdateOptions{Unset: []string{"tags"}}); err == nil {
+		t.Fatalf("CmdUpdate(--unset tags) succeeded")
+	}
+
+	s, err := store.OpenWith(root, store.Options{Lock: store.LockShared})
+	if err != nil {
+		t.Fatalf("OpenWith: %v", err)
+	}
+	defer s.Close()
+	n, err := s.GetByID(id)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	want := map[string]any{"status": "done", "points": float64(3), "owner": "ann", "version": float64(7)}
+	if !reflect.DeepEqual(n.Meta, want) || n.Version != 2 {
+		t.Fatalf("Meta = %v v%d", n.Meta, n.Version)
+	}
+
+	o := ListOptions{Where: []string{"status=done", "points>2", "version=7"}}
+	f, err := o.filter(time.Now())
+	if err != nil {
+		t.Fatalf("filter: %v", err)
+	}
+	notes, err := s.List(f)
+	if err != nil || len(notes) != 1 || notes[0].ID != id {
+		t.Fatalf("List(where) = %v, %v", notes, err)
+	}
+}
+
+func TestNoteRefs(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "store")
+	if err := CmdInit(root, ""); err != nil {
+		t.Fatalf("CmdInit: %v", err)
+	}
+	id, err := CmdCreate(root, "Unique title", "body", nil, nil)
+	if err != nil {
+		t.Fatalf("CmdCreate: %v", err)
+	}
+	for _, ref := range []NoteRef{IDRef(id[:6]), {Title: "Unique title"}} {
+		if err := CmdRead(root, ref, false); err != nil {
+			t.Fatalf("CmdRead(%+v): %v", ref, err)
+		}
+	}
+	if err := CmdRead(root, NoteRef{Title: "unique title"}, false); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("CmdRead by inexact title = %v", err)
+	}
+	if err := CmdDelete(root, IDRef(id[:4])); err != nil {
+		t.Fatalf("CmdDelete by prefix: %v", err)
+	}
+	if err := CmdRestore(root, IDRef(id[:4]), 0, ""); err != nil {
+		t.Fatalf("CmdRestore of deleted note by prefix: %v", err)
+	}
 }
 
 func TestCmdImport(t *testing.T) {
 	root := filepath.Join(t.TempDir(), "store")
-	if err := CmdInit(root); err != nil {
+	if err := CmdInit(root, "ru"); err != nil {
 		t.Fatalf("CmdInit: %v", err)
 	}
 
@@ -60,15 +239,111 @@ Body
 		t.Fatalf("WriteFile: %v", err)
 	}
 
-	if err := CmdImport(root, src, "md", true, true); err != nil {
+	if err := CmdImport(root, src, ImportOptions{Ext: "md", DryRun: true, Verbose: true, Prune: true}); err != nil {
 		t.Fatalf("CmdImport dry-run: %v", err)
 	}
 
-	if err := CmdImport(root, src, "md", false, true); err != nil {
+	if err := CmdImport(root, src, ImportOptions{Ext: "md", Verbose: true, Prune: true, TagOrphans: true}); err != nil {
 		t.Fatalf("CmdImport real: %v", err)
 	}
 
-	if err := CmdList(root, "", "Imported", 10, false); err != nil {
+	if err := CmdList(root, ListOptions{Contains: "Imported", Limit: 10, Color: "never"}); err != nil {
 		t.Fatalf("CmdList after import: %v", err)
 	}
+
+	if err := CmdList(root, ListOptions{AnyTags: []string{"cli,go", "misc"}, Query: "tag:cli -tag:archived created:>2000-01-01", Since: "7d", Until: "today", Limit: 10, Color: "never", JSON: true}); err != nil {
+		t.Fatalf("CmdList with query: %v", err)
+	}
+
+	if err := CmdSearch(root, ListOptions{Tags: []string{"cli"}, NotTags: []string{"archived,old"}, Query: "Imported", Sort: "relevance", Limit: 10, Color: "never", JSON: true}); err != nil {
+		t.Fatalf("CmdSearch: %v", err)
+	}
+	if err := CmdSearch(root, ListOptions{Query: `title:imported OR "no such phrase"`, UpdatedSince: "yesterday", Color: "never"}); err != nil {
+		t.Fatalf("CmdSearch with query: %v", err)
+	}
+	if err := CmdSearch(root, ListOptions{Query: "Imported", Sort: "newest", Color: "auto"}); err == nil {
+		t.Fatalf("CmdSearch with unknown sort succeeded")
+	}
+	if err := CmdSearch(root, ListOptions{Query: "(Imported", Color: "auto"}); err == nil {
+		t.Fatalf("CmdSearch with broken query succeeded")
+	}
+	if err := CmdList(root, ListOptions{Since: "last week", Color: "never"}); err == nil {
+		t.Fatalf("CmdList with bad --since succeeded")
+	}
+
+	if err := CmdFsck(root, false, false); err != nil {
+		t.Fatalf("CmdFsck: %v", err)
+	}
+
+	if err := CmdReindex(root); err != nil {
+		t.Fatalf("CmdReindex: %v", err)
+	}
+}
+
+func TestCmdSync(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "store")
+	if err := CmdInit(root, "en"); err != nil {
+		t.Fatalf("CmdInit: %v", err)
+	}
+	if _, err := CmdCreate(root, "Synced", "Text", []string{"go"}, nil); err != nil {
+		t.Fatalf("CmdCreate: %v", err)
+	}
+
+	dir := t.TempDir()
+	if err := CmdSync(root, dir, "", true, true); err != nil {
+		t.Fatalf("CmdSync dry-run: %v", err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*.md")); len(files) != 0 {
+		t.Fatalf("dry-run wrote %v", files)
+	}
+	if err := CmdSync(root, dir, "", false, false); err != nil {
+		t.Fatalf("CmdSync: %v", err)
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*.md")); len(files) != 1 {
+		t.Fatalf("sync wrote %v", files)
+	}
+	if err := CmdSync(root, filepath.Join(dir, "missing"), "", false, false); err == nil {
+		t.Fatal("CmdSync into a missing dir succeeded")
+	}
+}
+
+func TestCmdRestoreAtLocalDate(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "store")
+	s, err := store.Open(root)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	n := model.NewNote("Day one", "first", nil)
+	n.CreatedAt = time.Date(2025, 1, 2, 23, 30, 0, 0, time.Local).UTC()
+	n.UpdatedAt = n.CreatedAt
+	if err := s.Append(n); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	v2 := *n
+	v2.Title, v2.Text = "Day two", "second"
+	v2.UpdatedAt = time.Date(2025, 1, 3, 9, 0, 0, 0, time.Local).UTC()
+	if err := s.Append(&v2); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	_ = s.Close()
+
+	// Дата без времени — весь день в местной зоне, как у --until.
+	if err := CmdRestore(root, IDRef(n.ID), 0, "2025-01-02"); err != nil {
+		t.Fatalf("CmdRestore --at date: %v", err)
+	}
+	if err := CmdRestore(root, IDRef(n.ID), 0, "sometime"); err == nil || !strings.Contains(err.Error(), "--at") {
+		t.Fatalf("CmdRestore with bad --at = %v", err)
+	}
+	s, err = store.Open(root)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer s.Close()
+	got, err := s.GetByID(n.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if got.Title != "Day one" {
+		t.Fatalf("restored title = %q, want %q", got.Title, "Day one")
+	}
 }
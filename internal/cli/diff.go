@@ -0,0 +1,52 @@
+package cli
+
+import "strings"
+
+// diffLines строит построчный diff по наибольшей общей подпоследовательности
+// и возвращает только изменённые строки с префиксами "- " и "+ ".
+func diffLines(a, b string) []string {
+	if a == b {
+		return nil
+	}
+	al := strings.Split(a, "\n")
+	bl := strings.Split(b, "\n")
+
+	lcs := make([][]int, len(al)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(bl)+1)
+	}
+	for i := len(al) - 1; i >= 0; i-- {
+		for j := len(bl) - 1; j >= 0; j-- {
+			if al[i] == bl[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	var out []string
+	i, j := 0, 0
+	for i < len(al) && j < len(bl) {
+		switch {
+		case al[i] == bl[j]:
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, "- "+al[i])
+			i++
+		default:
+			out = append(out, "+ "+bl[j])
+			j++
+		}
+	}
+	for ; i < len(al); i++ {
+		out = append(out, "- "+al[i])
+	}
+	for ; j < len(bl); j++ {
+		out = append(out, "+ "+bl[j])
+	}
+	return out
+}
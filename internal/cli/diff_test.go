@@ -0,0 +1,43 @@
+package cli
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/model"
+)
+
+func TestDiffLines(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want []string
+	}{
+		{"same", "same", nil},
+		{"a\nb\nc", "a\nc", []string{"- b"}},
+		{"a\nc", "a\nb\nc", []string{"+ b"}},
+		{"a\nold\nc", "a\nnew\nc", []string{"- old", "+ new"}},
+		{"", "x", []string{"- ", "+ x"}},
+	}
+	for _, tt := range tests {
+		got := diffLines(tt.a, tt.b)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Fatalf("diffLines(%q, %q) = %q, want %q", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestNoteDiffMeta(t *testing.T) {
+	prev := &model.Note{Title: "T", Text: "x", Meta: map[string]any{"status": "open", "points": float64(3), "same": true}}
+	cur := &model.Note{Title: "T", Text: "x", Meta: map[string]any{"status": "done", "owner": "ann", "same": true}}
+	want := []string{
+		`~ meta.owner: <none> -> "ann"`,
+		`~ meta.points: "3" -> <none>`,
+		`~ meta.status: "open" -> "done"`,
+	}
+	if got := noteDiff(prev, cur); !reflect.DeepEqual(got, want) {
+		t.Fatalf("noteDiff = %q, want %q", got, want)
+	}
+	if got := noteDiff(cur, cur); got != nil {
+		t.Fatalf("noteDiff(same) = %q", got)
+	}
+}
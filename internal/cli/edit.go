@@ -0,0 +1,158 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/i18n"
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/importer"
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/model"
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/store"
+)
+
+// runEditor открывает файл в редакторе и ждёт его завершения. В тестах
+// подменяется.
+var runEditor = func(path string) error {
+	args := strings.Fields(editorCommand())
+	cmd := exec.Command(args[0], append(args[1:], path)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("редактор %s: %w", args[0], err)
+	}
+	return nil
+}
+
+// editorCommand — команда редактора из $VISUAL или $EDITOR, может
+// содержать аргументы (code --wait).
+func editorCommand() string {
+	for _, env := range []string{"VISUAL", "EDITOR"} {
+		if v := strings.TrimSpace(os.Getenv(env)); v != "" {
+			return v
+		}
+	}
+	if runtime.GOOS == "windows" {
+		return "notepad"
+	}
+	return "vi"
+}
+
+// editNote записывает заметку во временный markdown-файл, открывает его в
+// редакторе и разбирает результат. Файл возвращается, чтобы при ошибке
+// сохранения правки не пропали; после успеха его удаляет вызывающий.
+func editNote(n *model.Note) (path string, edited *model.Note, err error) {
+	f, err := os.CreateTemp("", "noteline-*.md")
+	if err != nil {
+		return "", nil, err
+	}
+	path = f.Name()
+	_, err = f.WriteString(importer.FormatMarkdown(n))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil {
+		err = runEditor(path)
+	}
+	if err != nil {
+		_ = os.Remove(path)
+		return "", nil, err
+	}
+
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return path, nil, err
+	}
+	edited, err = importer.ParseMarkdown(string(b))
+	if err != nil {
+		return path, nil, err
+	}
+	if edited.Title == "" {
+		return path, nil, errors.New("пустой заголовок (title в front matter)")
+	}
+	if edited.Text == "" {
+		return path, nil, errors.New("пустой текст заметки")
+	}
+	return path, edited, nil
+}
+
+// keepEdits сообщает, где лежат несохранённые правки.
+func keepEdits(path string, err error) error {
+	if path != "" {
+		fmt.Fprintln(os.Stderr, i18n.T("edit.kept", path))
+	}
+	return err
+}
+
+// CmdEdit открывает заметку в $VISUAL/$EDITOR и сохраняет новую версию,
+// если что-то изменилось. Пока открыт редактор, хранилище не
+// блокируется; если заметку за это время изменили, возвращается
+// store.ErrConflict, а правки остаются во временном файле.
+func CmdEdit(root string, ref NoteRef) error {
+	root = defaultRoot(root)
+	s, err := store.OpenWith(root, store.Options{Lock: store.LockShared})
+	if err != nil {
+		return err
+	}
+	var n *model.Note
+	id, err := ref.resolve(s)
+	if err == nil {
+		n, err = s.GetByID(id)
+	}
+	s.Close()
+	if err != nil {
+		return err
+	}
+
+	path, edited, err := editNote(n)
+	if err != nil {
+		return keepEdits(path, err)
+	}
+
+	s, err = store.Open(root)
+	if err != nil {
+		return keepEdits(path, err)
+	}
+	defer s.Close()
+	saved, err := s.Patch(id, func(cur *model.Note) error {
+		if err := store.CheckVersion(cur, n.Version); err != nil {
+			return err
+		}
+		cur.Title, cur.Tags, cur.Meta = edited.Title, edited.Tags, edited.Meta
+		// ParseMarkdown обрезает пустые строки по краям текста: если
+		// больше ничего не изменилось, текст остаётся прежним и Patch
+		// не пишет новую версию.
+		if edited.Text != strings.TrimSpace(cur.Text) {
+			cur.Text = edited.Text
+		}
+		return nil
+	})
+	if err != nil {
+		return keepEdits(path, err)
+	}
+	_ = os.Remove(path)
+
+	if saved.Version == n.Version {
+		fmt.Println(i18n.T("edit.unchanged"))
+		return nil
+	}
+	fmt.Println(i18n.T("edit.saved", saved.ID, saved.Version))
+	return nil
+}
+
+// CmdCreateEdit создаёт заметку в $VISUAL/$EDITOR; title, text, tags и
+// meta заполняют шаблон.
+func CmdCreateEdit(root, title, text string, tags []string, meta map[string]any) (string, error) {
+	path, edited, err := editNote(&model.Note{Title: title, Text: text, Tags: tags, Meta: meta})
+	if err != nil {
+		return "", keepEdits(path, err)
+	}
+	id, err := CmdCreate(root, edited.Title, edited.Text, edited.Tags, edited.Meta)
+	if err != nil {
+		return "", keepEdits(path, err)
+	}
+	_ = os.Remove(path)
+	return id, nil
+}
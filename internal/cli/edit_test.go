@@ -0,0 +1,153 @@
+package cli
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/model"
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/store"
+)
+
+// fakeEditor подменяет редактор функцией, которая правит содержимое файла.
+func fakeEditor(t *testing.T, edit func(content string) string) {
+	t.Helper()
+	t.Setenv("TMPDIR", t.TempDir())
+	prev := runEditor
+	t.Cleanup(func() { runEditor = prev })
+	runEditor = func(path string) error {
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		return os.WriteFile(path, []byte(edit(string(b))), 0o600)
+	}
+}
+
+func TestCmdEdit(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "store")
+	if err := CmdInit(root, ""); err != nil {
+		t.Fatalf("CmdInit: %v", err)
+	}
+	id, err := CmdCreate(root, "Title", "body", []string{"go"}, nil)
+	if err != nil {
+		t.Fatalf("CmdCreate: %v", err)
+	}
+	read := func() (string, string, string, int) {
+		s, err := store.OpenWith(root, store.Options{Lock: store.LockShared})
+		if err != nil {
+			t.Fatalf("OpenWith: %v", err)
+		}
+		defer s.Close()
+		n, err := s.GetByID(id)
+		if err != nil {
+			t.Fatalf("GetByID: %v", err)
+		}
+		return n.Title, strings.Join(n.Tags, ","), n.Text, n.Version
+	}
+
+	fakeEditor(t, func(c string) string {
+		c = strings.Replace(c, "title: Title", "title: New title", 1)
+		c = strings.Replace(c, "tags: [go]", "tags: [go, cli]", 1)
+		return strings.Replace(c, "body", "new body", 1)
+	})
+	if err := CmdEdit(root, IDRef(id)); err != nil {
+		t.Fatalf("CmdEdit: %v", err)
+	}
+	if title, tags, text, ver := read(); title != "New title" || tags != "go,cli" || text != "new body" || ver != 2 {
+		t.Fatalf("after edit: %q %q %q v%d", title, tags, text, ver)
+	}
+
+	fakeEditor(t, func(c string) string { return c })
+	if err := CmdEdit(root, IDRef(id)); err != nil {
+		t.Fatalf("CmdEdit without changes: %v", err)
+	}
+	if _, _, _, ver := read(); ver != 2 {
+		t.Fatalf("unchanged edit wrote version %d", ver)
+	}
+
+	// Заметку меняют, пока открыт редактор: правки не записываются.
+	fakeEditor(t, func(c string) string {
+		other := "other"
+		if err := CmdUpdate(root, IDRef(id), UpdateOptions{Text: &other}); err != nil {
+			t.Fatalf("CmdUpdate: %v", err)
+		}
+		return strings.Replace(c, "new body", "lost?", 1)
+	})
+	if err := CmdEdit(root, IDRef(id)); !errors.Is(err, store.ErrConflict) {
+		t.Fatalf("CmdEdit with concurrent update = %v", err)
+	}
+	if _, _, text, _ := read(); text != "other" {
+		t.Fatalf("text after conflict = %q", text)
+	}
+
+	fakeEditor(t, func(c string) string { return strings.Replace(c, "title: New title", "title:", 1) })
+	if err := CmdEdit(root, IDRef(id)); err == nil {
+		t.Fatalf("CmdEdit with empty title succeeded")
+	}
+}
+
+func TestCmdEditKeepsSurroundingWhitespace(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "store")
+	s, err := store.Open(root)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	n := model.NewNote("Imported", "\n  indented body\n\n", nil)
+	n.Meta = map[string]any{"status": "open"}
+	if err := s.Append(n); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	s.Close()
+
+	fakeEditor(t, func(c string) string { return c })
+	if err := CmdEdit(root, IDRef(n.ID)); err != nil {
+		t.Fatalf("CmdEdit: %v", err)
+	}
+	s, err = store.OpenWith(root, store.Options{Lock: store.LockShared})
+	if err != nil {
+		t.Fatalf("OpenWith: %v", err)
+	}
+	defer s.Close()
+	got, err := s.GetByID(n.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if got.Version != 1 || got.Text != n.Text {
+		t.Fatalf("no-op edit wrote v%d with text %q", got.Version, got.Text)
+	}
+}
+
+func TestCmdCreateEdit(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "store")
+	if err := CmdInit(root, ""); err != nil {
+		t.Fatalf("CmdInit: %v", err)
+	}
+
+	fakeEditor(t, func(c string) string { return c })
+	if _, err := CmdCreateEdit(root, "", "", nil, nil); err == nil {
+		t.Fatalf("CmdCreateEdit with untouched empty template succeeded")
+	}
+
+	fakeEditor(t, func(c string) string {
+		return strings.Replace(c, "title: Draft", "title: Idea", 1) + "written in editor\n"
+	})
+	id, err := CmdCreateEdit(root, "Draft", "", []string{"ideas"}, nil)
+	if err != nil {
+		t.Fatalf("CmdCreateEdit: %v", err)
+	}
+	s, err := store.OpenWith(root, store.Options{Lock: store.LockShared})
+	if err != nil {
+		t.Fatalf("OpenWith: %v", err)
+	}
+	defer s.Close()
+	n, err := s.GetByID(id)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if n.Title != "Idea" || n.Text != "written in editor" || strings.Join(n.Tags, ",") != "ideas" {
+		t.Fatalf("created note = %+v", n)
+	}
+}
@@ -0,0 +1,90 @@
+package cli
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	fts "github.com/Victor3563/NoteLine/cli-notebook/internal/fulltext"
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/model"
+)
+
+const (
+	colorAuto   = "auto"
+	colorAlways = "always"
+	colorNever  = "never"
+
+	highlightOn  = "\x1b[31;1m"
+	highlightOff = "\x1b[0m"
+)
+
+// useColor решает, подсвечивать ли совпадения escape-последовательностями.
+// В режиме auto цвет включается, только если stdout — терминал и не задан
+// NO_COLOR.
+func useColor(mode string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(mode)) {
+	case "", colorAuto:
+		if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+			return false, nil
+		}
+		st, err := os.Stdout.Stat()
+		return err == nil && st.Mode()&os.ModeCharDevice != 0, nil
+	case colorAlways:
+		return true, nil
+	case colorNever:
+		return false, nil
+	default:
+		return false, fmt.Errorf("неизвестное значение --color: %q (auto, always, never)", mode)
+	}
+}
+
+func fragmentField(n *model.Note, field string) string {
+	switch field {
+	case "title":
+		return n.Title
+	case "text":
+		return n.Text
+	case "tags":
+		return strings.Join(n.Tags, " ")
+	}
+	return ""
+}
+
+// renderFragment собирает отрывок в одну строку: переводы строк заменяются
+// пробелами, обрезанные края помечаются многоточием.
+func renderFragment(n *model.Note, fr fts.Fragment, color bool) string {
+	var b strings.Builder
+	if fr.Start > 0 {
+		b.WriteString("...")
+	}
+	pos := fr.Start
+	for _, h := range fr.Highlights {
+		if h.Start < pos || h.End > fr.End {
+			continue
+		}
+		b.WriteString(flattenSpace(fr.Text[pos-fr.Start : h.Start-fr.Start]))
+		if color {
+			b.WriteString(highlightOn)
+		}
+		b.WriteString(flattenSpace(fr.Text[h.Start-fr.Start : h.End-fr.Start]))
+		if color {
+			b.WriteString(highlightOff)
+		}
+		pos = h.End
+	}
+	b.WriteString(flattenSpace(fr.Text[pos-fr.Start:]))
+	if fr.End < len(fragmentField(n, fr.Field)) {
+		b.WriteString("...")
+	}
+	return b.String()
+}
+
+func flattenSpace(s string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case '\n', '\r', '\t':
+			return ' '
+		}
+		return r
+	}, s)
+}
@@ -0,0 +1,44 @@
+package cli
+
+import (
+	"testing"
+
+	fts "github.com/Victor3563/NoteLine/cli-notebook/internal/fulltext"
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/model"
+)
+
+func TestRenderFragment(t *testing.T) {
+	n := &model.Note{Text: "Первая строка\nпро kiwi и ещё"}
+	start := len("Первая ")
+	hl := len("Первая строка\nпро ")
+	fr := fts.Fragment{
+		Field:      "text",
+		Text:       n.Text[start : hl+len("kiwi")],
+		Start:      start,
+		End:        hl + len("kiwi"),
+		Highlights: []fts.Span{{Start: hl, End: hl + len("kiwi")}},
+	}
+
+	if got, want := renderFragment(n, fr, false), "...строка про kiwi..."; got != want {
+		t.Fatalf("renderFragment = %q, want %q", got, want)
+	}
+	if got, want := renderFragment(n, fr, true), "...строка про "+highlightOn+"kiwi"+highlightOff+"..."; got != want {
+		t.Fatalf("renderFragment with color = %q, want %q", got, want)
+	}
+}
+
+func TestUseColor(t *testing.T) {
+	if on, err := useColor("always"); err != nil || !on {
+		t.Fatalf("useColor(always) = %v, %v", on, err)
+	}
+	if on, err := useColor("never"); err != nil || on {
+		t.Fatalf("useColor(never) = %v, %v", on, err)
+	}
+	t.Setenv("NO_COLOR", "1")
+	if on, err := useColor("auto"); err != nil || on {
+		t.Fatalf("useColor(auto) with NO_COLOR = %v, %v", on, err)
+	}
+	if _, err := useColor("rainbow"); err == nil {
+		t.Fatalf("useColor(rainbow) succeeded")
+	}
+}
@@ -0,0 +1,103 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/i18n"
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/model"
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/store"
+)
+
+// CmdTags печатает теги с числом заметок и датой последнего изменения.
+// Без tree выводятся только теги, которые стоят на заметках; с tree — вся
+// иерархия a/b/c. Число заметок считается по поддереву — столько найдёт
+// list --tag.
+func CmdTags(root string, tree, asJSON bool) error {
+	root = defaultRoot(root)
+	s, err := store.OpenWith(root, store.Options{Lock: store.LockShared})
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	counts, err := s.TagCounts()
+	if err != nil {
+		return err
+	}
+	if !tree {
+		direct := counts[:0:0]
+		for _, c := range counts {
+			if c.Direct > 0 {
+				direct = append(direct, c)
+			}
+		}
+		counts = direct
+	}
+
+	if asJSON {
+		if counts == nil {
+			counts = []store.TagCount{}
+		}
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(counts)
+	}
+	if len(counts) == 0 {
+		fmt.Println(i18n.T("tags.empty"))
+		return nil
+	}
+	printTags(os.Stdout, counts, tree)
+	return nil
+}
+
+func printTags(w io.Writer, counts []store.TagCount, tree bool) {
+	for _, c := range counts {
+		used := c.LastUsed.Local().Format("2006-01-02")
+		if !tree {
+			fmt.Fprintln(w, i18n.T("tags.line", c.Tag, c.Total, used))
+			continue
+		}
+		depth := strings.Count(c.Tag, model.TagSeparator)
+		name := c.Tag[strings.LastIndex(c.Tag, model.TagSeparator)+1:]
+		fmt.Fprintln(w, strings.Repeat("  ", depth)+i18n.T("tags.line", name, c.Total, used))
+	}
+}
+
+// CmdTagsRename переименовывает тег или сливает несколько тегов в один;
+// потомки переезжают вместе с тегом.
+func CmdTagsRename(root string, from []string, to string) error {
+	root = defaultRoot(root)
+	s, err := store.Open(root)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	n, err := s.RenameTags(from, to)
+	if err != nil {
+		return err
+	}
+	fmt.Println(i18n.T("tags.renamed", strings.Join(from, ", "), model.CleanTag(to), n))
+	return nil
+}
+
+// CmdTagsRemove снимает тег и его потомков со всех заметок.
+func CmdTagsRemove(root, tag string) error {
+	root = defaultRoot(root)
+	s, err := store.Open(root)
+	if err != nil {
+		return err
+	}
+	defer s.Close()
+
+	n, err := s.RemoveTag(tag)
+	if err != nil {
+		return err
+	}
+	fmt.Println(i18n.T("tags.removed", model.CleanTag(tag), n))
+	return nil
+}
@@ -0,0 +1,95 @@
+package cli
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/i18n"
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/store"
+)
+
+func TestPrintTags(t *testing.T) {
+	if err := i18n.LoadLocale(filepath.Join("..", "i18n", "en.json"), "en"); err != nil {
+		t.Fatalf("LoadLocale: %v", err)
+	}
+	day := func(d int) time.Time { return time.Date(2025, 3, d, 12, 0, 0, 0, time.Local) }
+	counts := []store.TagCount{
+		{Tag: "go", Direct: 2, Total: 2, LastUsed: day(1)},
+		{Tag: "project", Direct: 0, Total: 3, LastUsed: day(5)},
+		{Tag: "project/noteline", Direct: 1, Total: 2, LastUsed: day(5)},
+		{Tag: "project/noteline/bugs", Direct: 1, Total: 1, LastUsed: day(4)},
+	}
+
+	var buf bytes.Buffer
+	printTags(&buf, counts, true)
+	want := "go (2, last used 2025-03-01)\n" +
+		"project (3, last used 2025-03-05)\n" +
+		"  noteline (2, last used 2025-03-05)\n" +
+		"    bugs (1, last used 2025-03-04)\n"
+	if buf.String() != want {
+		t.Fatalf("tree output:\n%s\nwant:\n%s", buf.String(), want)
+	}
+
+	buf.Reset()
+	printTags(&buf, counts[2:], false)
+	want = "project/noteline (2, last used 2025-03-05)\nproject/noteline/bugs (1, last used 2025-03-04)\n"
+	if buf.String() != want {
+		t.Fatalf("flat output:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestCmdTags(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "store")
+	if err := CmdInit(root, ""); err != nil {
+		t.Fatalf("CmdInit: %v", err)
+	}
+	if err := CmdTags(root, true, false); err != nil {
+		t.Fatalf("CmdTags on empty store: %v", err)
+	}
+	if _, err := CmdCreate(root, "T", "X", []string{"project/noteline/bugs", "go"}, nil); err != nil {
+		t.Fatalf("CmdCreate: %v", err)
+	}
+	for _, tree := range []bool{false, true} {
+		for _, asJSON := range []bool{false, true} {
+			if err := CmdTags(root, tree, asJSON); err != nil {
+				t.Fatalf("CmdTags(tree=%v, json=%v): %v", tree, asJSON, err)
+			}
+		}
+	}
+	if err := CmdList(root, ListOptions{Tags: []string{"project/noteline"}, Color: "never"}); err != nil {
+		t.Fatalf("CmdList by tag prefix: %v", err)
+	}
+}
+
+func TestCmdTagsRenameAndRemove(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "store")
+	if err := CmdInit(root, ""); err != nil {
+		t.Fatalf("CmdInit: %v", err)
+	}
+	id, err := CmdCreate(root, "T", "X", []string{"work/deploy", "job", "todo"}, nil)
+	if err != nil {
+		t.Fatalf("CmdCreate: %v", err)
+	}
+	if err := CmdTagsRename(root, []string{"work", "job"}, "office"); err != nil {
+		t.Fatalf("CmdTagsRename: %v", err)
+	}
+	if err := CmdTagsRemove(root, "todo"); err != nil {
+		t.Fatalf("CmdTagsRemove: %v", err)
+	}
+
+	s, err := store.OpenWith(root, store.Options{Lock: store.LockShared})
+	if err != nil {
+		t.Fatalf("OpenWith: %v", err)
+	}
+	defer s.Close()
+	n, err := s.GetByID(id)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if got := strings.Join(n.Tags, ","); got != "office/deploy,office" {
+		t.Fatalf("tags = %q", got)
+	}
+}
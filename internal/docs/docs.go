@@ -4,34 +4,203 @@ package docs
 const MiniManualRU = `noteline — консольный блокнот
 
 noteline хранит заметки в виде JSON-записей в сегментированных файлах
-<root>/segments/notes-XXXXXXXX.ndjson. Каждая строка — одна заметка.
+<root>/segments/notes-XXXXXXXX.ndjson. Каждая строка — одна заметка,
+перед JSON записаны контрольная сумма CRC32C и длина записи. Строки
+старого формата (просто JSON) тоже читаются.
+
+Надёжность записи настраивается в manifest.json:
+
+  "fsync": "always" | "interval" | "never"
+  "fsync_interval_ms": 1000
+
+При открытии хранилища неполная запись в конце последнего сегмента
+(например, после сбоя питания) отрезается, и об этом выводится
+предупреждение.
+
+Одновременный запуск нескольких noteline защищён файлом блокировки
+<root>/noteline.lock: команды, которые пишут, берут его эксклюзивно,
+read, list, search и history — совместно. Если блокировку держит другой
+процесс, noteline ждёт NOTELINE_LOCK_TIMEOUT (по умолчанию 5s), а затем
+завершается с ошибкой, в которой указан PID держателя.
+
+Команды, которые принимают --id, понимают и уникальный префикс ID (от 4
+символов, как в git), а вместо --id можно указать --title-match
+"Заголовок" — точный заголовок заметки. Если под ссылку подходит
+несколько заметок, команда завершается с ошибкой и перечисляет их.
+list и search печатают кратчайшие уникальные префиксы ID.
 
 Базовые команды:
 
-  noteline init [--root DIR]
-      Создаёт хранилище (по умолчанию ~/.noteline).
+  noteline init [--root DIR] [--lang en|ru]
+      Создаёт хранилище (по умолчанию ~/.noteline). --lang задаёт язык
+      полнотекстового поиска: от него зависят стемминг и стоп-слова.
+      По умолчанию берётся язык интерфейса; у существующего хранилища
+      смена языка перестраивает индекс.
 
-  noteline create --title "..." --text "..." [--tags "a,b,c"]
+  noteline create --title "..." --text "..." [--tags "a,b,c"] [--set KEY=VALUE]...
+  noteline create --edit [--title "..."] [--text "..."] [--tags "a,b,c"] [--set KEY=VALUE]...
       Создаёт заметку. Текст можно передать через --text или stdin.
+      С --edit заметка пишется в редакторе, а флаги заполняют шаблон.
+      --set задаёт поле метаданных (meta): true и false становятся bool,
+      числа — числами, остальное — строкой; "42" в кавычках — строка.
+      Ключ приводится к нижнему регистру; ключи front matter, которые
+      становятся полями заметки (title, tags, id, created, updated, date,
+      lastmod), заняты.
 
   noteline read --id ID [--json]
-      Показывает заметку по ID. В режиме --json выводит JSON-структуру.
+      Показывает заметку по ID вместе с полями метаданных. В режиме
+      --json выводит JSON-структуру.
 
-  noteline update --id ID --title "..." --text "..." [--tags "..."]
+  noteline update --id ID [--if-version N] [--title "..."] [--text "..." | --text -] [--tags "..."]
+                  [--add-tags "..."] [--remove-tags "..."]
+                  [--append-text "..."] [--prepend-text "..."]
+                  [--set KEY=VALUE]... [--unset KEY]...
       Создаёт новую версию заметки с тем же ID (лог-структурное обновление).
+      Меняются только переданные поля: --tags заменяет теги целиком,
+      --add-tags и --remove-tags добавляют и убирают отдельные теги,
+      --append-text и --prepend-text дописывают текст в конец или начало,
+      --set задаёт поля метаданных (как в create), --unset убирает их.
+      --text - читает текст из stdin; без других флагов, кроме --id,
+      текст тоже читается из stdin. С --if-version N заметка меняется,
+      только если её текущая версия равна N; иначе update завершается
+      с кодом 3.
+
+  noteline edit --id ID
+      Открывает заметку в $VISUAL или $EDITOR (по умолчанию vi, в Windows
+      notepad) как markdown-файл с front matter:
+
+        ---
+        title: Заголовок
+        tags: [go, cli]
+        ---
+
+        Текст заметки
+
+      После выхода из редактора title, tags и текст сохраняются новой
+      версией, если что-то изменилось. Пока открыт редактор, хранилище
+      не блокируется; если заметку за это время изменили, правки не
+      записываются (код выхода 3), а файл с ними остаётся на диске.
 
   noteline delete --id ID
       Помечает заметку как удалённую (tombstone).
 
-  noteline list [--tag TAG] [--contains STR] [--limit N] [--json]
-      Выводит список заметок, фильтруя по тегам и подстроке в тексте/заголовке.
-
-  noteline search ...
-      Синоним list, логически отделённая команда "поиск".
+  noteline list [--query QUERY] [--tag TAG]... [--any-tag A,B] [--not-tag TAG]... [--where COND]... [--contains STR] [--since T] [--until T] [--updated-since T] [--limit N] [--color WHEN] [--json] [QUERY...]
+      Выводит список заметок, фильтруя по запросу, тегам, подстроке в
+      тексте/заголовке и времени. Для совпадений печатаются отрывки с
+      подсветкой; --color auto|always|never управляет подсветкой.
+      --tag требует все указанные теги, --any-tag — хотя бы один,
+      --not-tag исключает заметки с любым из них. Флаги повторяются и
+      принимают списки через запятую; тег совпадает и со своими
+      потомками (project находит project/noteline/bugs):
+          noteline list --tag work --tag urgent --not-tag archived
+      --since и --until ограничивают время создания (день в --until
+      включается целиком), --updated-since — время изменения. T — дата
+      (2025-01-02, 2025-01, 2025-01-02 15:04, RFC3339), срок назад (30m,
+      12h, 7d, 2w) или now, today, yesterday:
+          noteline list --since 7d
+          noteline list --since yesterday --until yesterday
+      --where отбирает по полям метаданных: key=value, key!=value,
+      key>N, key>=N, key<N, key<=N или просто key (поле задано).
+      Значения сравниваются без учёта регистра, у списка подходит любой
+      элемент; числа сравниваются как числа, остальное — как строки
+      (даты 2025-01-02 сравниваются верно). Вложенные поля — через точку.
+      Условия повторяются и должны выполняться все:
+          noteline list --where status=open --where "priority>=2"
+
+  noteline search [--query QUERY] [--tag TAG]... [--any-tag A,B] [--not-tag TAG]... [--where COND]... [--contains STR] [--since T] [--until T] [--updated-since T] [--sort ORDER] [--limit N] [--color WHEN] [--json] [QUERY...]
+      Полнотекстовый поиск. По умолчанию результаты упорядочены по
+      релевантности; --sort created|updated|title меняет порядок.
+      Отрывки с совпадениями берутся из индекса. В JSON у каждой заметки
+      есть поля score и fragments (байтовые смещения отрывка и совпадений).
+
+  Язык запросов (list и search):
+      deploy                  слово в заголовке, тексте или тегах
+      "exact phrase"          точная фраза
+      title:deploy            слово в заголовке; text:… — в тексте
+      tag:work                заметка с тегом work или work/...
+      meta.status:open        поле метаданных; операторы > >= < <= =,
+                              meta.due:* — поле задано
+      created:>2025-01-01     дата создания; updated: — изменения;
+                              операторы > >= < <= =, диапазон A..B;
+                              время — как у --since (7d, yesterday, ...)
+      -tag:archived, NOT x    отрицание
+      a OR b                  любое из условий; связывает сильнее пробела
+      a b, a AND b            все условия
+      ( ... )                 группировка
+      Пример: noteline search 'title:deploy tag:work -tag:archived "exact phrase" OR incident'
+      Слова ищутся с учётом языка хранилища и находятся и в значениях
+      полей метаданных; теги, даты и meta. проверяются по метаданным.
+      --contains не разбирается как запрос: все его слова должны найтись.
 
   noteline import --dir PATH [--ext "md,markdown,txt"] [--dry-run] [--verbose]
+                  [--prune [--tag-orphans]]
       Импортирует markdown-файлы с front matter. При повторном запуске
       обновляет существующие заметки и пропускает неизменённые.
+      --prune удаляет заметки, импортированные из этого каталога раньше,
+      файлов которых в нём больше нет; с --tag-orphans такие заметки не
+      удаляются, а получают тег orphaned. С --dry-run только показывает,
+      что было бы удалено.
+
+  noteline export --dir PATH [--tag TAG] [--layout flat|by-tag|by-date]
+      Выгружает живые заметки в markdown-файлы с front matter (id, title,
+      tags, created, updated). Импорт такого каталога даёт те же заметки.
+      --tag ограничивает выгрузку тегом и вложенными в него. Раскладка:
+      flat — все файлы в одном каталоге, by-tag — по каталогам первого
+      тега, by-date — по каталогам ГГГГ/ММ даты создания.
+
+  noteline sync --dir PATH [--ext "md,markdown,txt"] [--dry-run] [--verbose]
+      Синхронизирует хранилище с каталогом в обе стороны. Изменённые
+      файлы импортируются, изменённые в NoteLine заметки записываются в
+      свои файлы, заметки без файла выгружаются в новые файлы. Если с
+      прошлого sync изменились и файл, и заметка, в файл пишется
+      заметка, а правка файла сохраняется копией *.conflict-<время>.md
+      (такие копии не импортируются). Удалённый файл удаляет заметку,
+      удалённая заметка — файл; удаление не применяется, если другая
+      сторона с тех пор менялась. Заметки, импортированные или
+      синхронизированные из другого каталога, остаются за ним и сюда не
+      выгружаются. Состояние хранится в imports.json.
+
+  noteline compact [--json]
+      Сжимает сегменты: оставляет только последнюю живую версию каждой
+      заметки, убирает старые версии и tombstone-записи, печатает, сколько
+      байт освобождено.
+
+  noteline history --id ID [--json]
+      Показывает все версии заметки (включая удаление) с временем изменения
+      и разницей между соседними версиями.
+
+  noteline restore --id ID [--version N | --at TIMESTAMP]
+      Дописывает новую версию, скопированную из версии N (номер из history)
+      или из версии, действовавшей на момент TIMESTAMP (форматы как у
+      --until: неполная дата означает конец дня, месяца или года). Без опций
+      возвращает удалённую заметку к последней версии перед удалением.
+
+  noteline fsck [--repair] [--json]
+      Проверяет хранилище: битые записи в сегментах, манифест, первичный и
+      полнотекстовый индексы, файл LRU-кэша и imports.json. С --repair
+      битые записи переносятся в <root>/quarantine, индексы и манифест
+      исправляются, устаревшие записи импорта удаляются. Оборванную
+      сбоем последнюю запись сегмента отрезает уже открытие хранилища,
+      поэтому и без --repair она выводится как torn_tail (исправлено).
+
+  noteline reindex
+      Удаляет index.bleve и строит полнотекстовый индекс заново по живым
+      заметкам из сегментов. Удалённые заметки в индекс не попадают.
+
+  noteline tags [--tree] [--json]
+      Выводит теги заметок с числом заметок и датой последнего изменения.
+      Теги иерархичны: project/noteline/bugs лежит под project/noteline,
+      а фильтр --tag project/noteline (и tag: в запросе) находит всех
+      потомков; число у тега считается так же. --tree печатает иерархию.
+
+  noteline tags rename OLD NEW
+  noteline tags merge A B... --into C
+  noteline tags rm TAG
+      Переименовывают тег, сливают несколько тегов в один или снимают тег
+      со всех заметок. Потомки переезжают вместе с тегом: после rename
+      work office тег work/deploy становится office/deploy. Изменённые
+      заметки получают новые версии (их видно в history), а
+      полнотекстовый индекс обновляется один раз.
 
   noteline completion SHELL
       Выводит скрипт автодополнения для bash/zsh/fish.
@@ -51,26 +220,49 @@ noteline хранит заметки в виде JSON-записей в сегм
     "tags":        ["go","cli"],
     "created_at":  "...",
     "updated_at":  "...",
-    "deleted":     false
+    "deleted":     false,
+    "version":     3,
+    "meta":        {"status": "open"}
   }
 
+  version растёт на единицу с каждой записью заметки в лог. meta —
+  произвольные поля, например из front matter; пустое поле не пишется.
+
 Импорт Markdown:
 
-  Файл может начинаться с блока front matter:
+  Файл может начинаться с блока front matter в YAML:
 
   ---
-  title: Моя заметка
-  tags: go, cli
+  title: "Моя заметка: черновик"
+  tags:
+    - go
+    - cli
   created: 2025-10-22
   updated: 2025-10-22T12:34:56Z
   id: my-note-id
+  status: open
   ---
   Дальше идёт текст в Markdown.
 
+  или в TOML между строками +++, как у Hugo:
+
+  +++
+  title = "Моя заметка"
+  tags = ["go", "cli"]
+  date = 2025-10-22
+  +++
+
   При импорте noteline:
-    - старается использовать created/updated, если они заданы;
-    - собирает теги из строки tags;
-    - если указан id, использует его для "склеивания" импортов;
+    - старается использовать created/updated (или date/lastmod), если
+      они заданы;
+    - принимает tags и списком, и строкой через запятую;
+    - остальные поля (status выше) сохраняет в meta заметки со своими
+      типами: строки, числа, списки, вложенные таблицы; export и edit
+      пишут их обратно в front matter;
+    - блок, который не разбирается как YAML (например, title: a: b без
+      кавычек), читает по-старому: по строке "ключ: значение";
+    - если указан id, использует его для "склеивания" импортов и как id
+      заметки; файл с id существующей заметки обновляет её;
     - хранит индекс соответствия файлов и заметок в imports.json.
 
 Completion:
@@ -94,13 +286,28 @@ noteline \- консольный блокнот с сегментированн
 .SH ОПИСАНИЕ
 .B noteline
 хранит заметки в файлах сегментов формата NDJSON. Каждая строка сегмента
-представляет собой JSON-структуру заметки. Обновления и удаления
+представляет собой JSON-структуру заметки с префиксом из контрольной суммы
+CRC32C и длины записи. Обновления и удаления
 реализованы лог-структурно: новые версии дописываются в конец.
+.PP
+Команды с опцией \fB\-\-id\fR принимают и уникальный префикс ID (не
+короче 4 символов), а вместо \fB\-\-id\fR \- опцию
+\fB\-\-title\-match\fR \fITITLE\fR с точным заголовком заметки. Если под
+ссылку подходит несколько заметок, команда завершается с ошибкой и
+перечисляет кандидатов. \fBlist\fR и \fBsearch\fR печатают кратчайшие
+уникальные префиксы ID.
 
 .SH КОМАНДЫ
 .TP
 .B init
-Создаёт новое хранилище (по умолчанию \fI~/.noteline\fR).
+Создаёт новое хранилище (по умолчанию \fI~/.noteline\fR). Опции:
+.RS
+.TP
+\fB\-\-lang\fR en|ru
+Язык полнотекстового поиска (анализатор со стеммингом и стоп-словами),
+сохраняется в manifest.json. По умолчанию \- язык интерфейса. Для
+существующего хранилища индекс перестраивается под новый язык.
+.RE
 
 .TP
 .B create
@@ -115,6 +322,17 @@ noteline \- консольный блокнот с сегментированн
 .TP
 \fB\-\-tags\fR "a,b,c"
 Список тегов через запятую.
+.TP
+\fB\-\-edit\fR
+Написать заметку в редакторе (см. \fBedit\fR); \fB\-\-title\fR,
+\fB\-\-text\fR и \fB\-\-tags\fR заполняют шаблон.
+.TP
+\fB\-\-set\fR KEY=VALUE
+Поле метаданных заметки (meta); флаг можно повторять. true и false
+становятся bool, числа \- числами, остальное \- строкой; "42" в
+кавычках \- строка. Ключ приводится к нижнему регистру; ключи front
+matter, которые становятся полями заметки (title, tags, id, created,
+updated, date, lastmod), заняты.
 .RE
 
 .TP
@@ -131,8 +349,40 @@ noteline \- консольный блокнот с сегментированн
 
 .TP
 .B update
-Создаёт новую версию заметки с тем же ID. Опции аналогичны
-команде \fBcreate\fR плюс обязательный \fB\-\-id\fR.
+Создаёт новую версию заметки с тем же ID. Меняются только переданные
+поля, остальные берутся из текущей версии. Опции:
+.RS
+.TP
+\fB\-\-id\fR ID
+Заметка (обязательно).
+.TP
+\fB\-\-if\-version\fR N
+Изменить, только если текущая версия заметки равна N (её показывает
+\fBread \-\-json\fR). Иначе ничего не записывается, код выхода 3.
+.TP
+\fB\-\-title\fR, \fB\-\-text\fR, \fB\-\-tags\fR
+Новый заголовок, текст или список тегов. \fB\-\-text \-\fR читает текст
+из stdin; если кроме \fB\-\-id\fR ничего не передано, текст тоже
+читается из stdin.
+.TP
+\fB\-\-add\-tags\fR, \fB\-\-remove\-tags\fR A,B
+Добавить или убрать отдельные теги. Флаги можно повторять.
+.TP
+\fB\-\-append\-text\fR, \fB\-\-prepend\-text\fR TEXT
+Дописать текст в конец или начало заметки.
+.TP
+\fB\-\-set\fR KEY=VALUE, \fB\-\-unset\fR KEY
+Задать или убрать поле метаданных (см. \fBcreate\fR). Флаги можно
+повторять.
+.RE
+
+.TP
+.B edit
+Открывает заметку \fB\-\-id\fR в \fI$VISUAL\fR или \fI$EDITOR\fR как
+markdown-файл с front matter (title, tags) и после выхода из редактора
+сохраняет новую версию, если что-то изменилось. Если заметку изменили,
+пока был открыт редактор, правки не записываются (код выхода 3), а
+файл с ними остаётся на диске.
 
 .TP
 .B delete
@@ -144,21 +394,66 @@ noteline \- консольный блокнот с сегментированн
 .RS
 .TP
 \fB\-\-tag\fR TAG
-Фильтр по тегу.
+Только заметки со всеми указанными тегами. Флаг можно повторять и
+передавать несколько тегов через запятую. Тег совпадает и со своими
+потомками: \-\-tag project находит project/noteline/bugs.
+.TP
+\fB\-\-any\-tag\fR A,B
+Только заметки хотя бы с одним из тегов.
+.TP
+\fB\-\-not\-tag\fR TAG
+Исключить заметки с любым из тегов.
+.TP
+\fB\-\-where\fR COND
+Условие на поле метаданных: key=value, key!=value, key>N, key>=N,
+key<N, key<=N или key (поле задано). Сравнение без учёта регистра, у
+списка подходит любой элемент, числа сравниваются как числа. Вложенные
+поля \- через точку (params.owner). Флаг можно повторять, выполняться
+должны все условия.
 .TP
 \fB\-\-contains\fR STR
 Фильтр по подстроке в заголовке и тексте.
 .TP
+\fB\-\-query\fR QUERY
+Запрос на языке запросов (см. \fBЯЗЫК ЗАПРОСОВ\fR); позиционные
+аргументы дописываются к нему.
+.TP
+\fB\-\-since\fR T, \fB\-\-until\fR T
+Время создания не раньше и не позже T; день, месяц или год в
+\fB\-\-until\fR включается целиком. T — дата (2025\-01\-02, 2025\-01,
+2025\-01\-02 15:04, RFC3339), срок назад (30m, 12h, 7d, 2w) или now, today,
+yesterday.
+.TP
+\fB\-\-updated\-since\fR T
+Время последнего изменения не раньше T.
+.TP
 \fB\-\-limit\fR N
 Ограничение на количество результатов.
 .TP
+\fB\-\-color\fR auto|always|never
+Подсветка совпадений в отрывках. В режиме auto цвет включается, только
+если вывод идёт в терминал и не задана переменная NO_COLOR.
+.TP
 \fB\-\-json\fR
 Вывод списка в JSON.
 .RE
 
 .TP
 .B search
-Синоним команды \fBlist\fR. Логически отделён как "поиск".
+Полнотекстовый поиск по индексу. Принимает тот же запрос и те же
+\fB\-\-tag\fR, \fB\-\-any\-tag\fR, \fB\-\-not\-tag\fR, \fB\-\-where\fR, \fB\-\-contains\fR, \fB\-\-query\fR, \fB\-\-since\fR,
+\fB\-\-until\fR, \fB\-\-updated\-since\fR, \fB\-\-limit\fR и \fB\-\-json\fR,
+что и \fBlist\fR, и дополнительно:
+.RS
+.TP
+\fB\-\-sort\fR relevance|created|updated|title
+Порядок результатов; по умолчанию relevance.
+.TP
+\fB\-\-json\fR
+У каждой заметки есть поле score с оценкой релевантности и поле
+fragments: для каждого поля (title, text, tags) отрывок, его байтовые
+смещения start/end в поле и смещения совпадений highlights.
+.RE
 
 .TP
 .B import
@@ -176,6 +471,150 @@ noteline \- консольный блокнот с сегментированн
 .TP
 \fB\-\-verbose\fR
 Подробный отчёт по каждому файлу.
+.TP
+\fB\-\-prune\fR
+Удалить заметки, импортированные из этого каталога раньше, файлов
+которых в нём больше нет.
+.TP
+\fB\-\-tag\-orphans\fR
+Вместе с \-\-prune: пометить такие заметки тегом orphaned вместо
+удаления.
+.RE
+
+.TP
+.B export
+Выгружает живые заметки в markdown-файлы с front matter, которые
+import читает обратно без изменений. Опции:
+.RS
+.TP
+\fB\-\-dir\fR PATH
+Каталог назначения (обязателен).
+.TP
+\fB\-\-tag\fR TAG
+Только заметки с тегом TAG или вложенным в него.
+.TP
+\fB\-\-layout\fR flat|by-tag|by-date
+Раскладка файлов: в одном каталоге, по первому тегу или по году и
+месяцу создания.
+.RE
+
+.TP
+.B sync
+Синхронизирует хранилище с каталогом markdown-файлов в обе стороны:
+импортирует изменённые файлы, записывает изменённые заметки в их файлы,
+выгружает новые заметки (кроме пришедших из других каталогов import и
+sync) и переносит удаления. Если изменились обе
+стороны, в файл пишется заметка, а правка файла сохраняется копией
+*.conflict-<время>.md. Опции:
+.RS
+.TP
+\fB\-\-dir\fR PATH
+Каталог с файлами (обязателен).
+.TP
+\fB\-\-ext\fR "md,markdown,txt"
+Список расширений файлов.
+.TP
+\fB\-\-dry\-run\fR
+Показать, что будет сделано, но ничего не изменять.
+.TP
+\fB\-\-verbose\fR
+Подробный отчёт по каждому файлу.
+.RE
+
+.TP
+.B compact
+Переписывает сегменты, оставляя только последнюю живую версию каждой
+заметки. Старые версии и tombstone-записи удаляются, новые сегменты
+подменяют старые атомарно через манифест. Опции:
+.RS
+.TP
+\fB\-\-json\fR
+Вывести отчёт (сегменты, записи, освобождённые байты) в JSON.
+.RE
+
+.TP
+.B history
+Показывает все сохранённые версии заметки по порядку, включая tombstone,
+с временем изменения и построчной разницей между соседними версиями.
+Опции:
+.RS
+.TP
+\fB\-\-id\fR ID
+Идентификатор заметки (обязателен).
+.TP
+\fB\-\-json\fR
+Вывести версии и различия в JSON.
+.RE
+
+.TP
+.B restore
+Дописывает новую версию заметки, скопированную из одной из прошлых версий.
+Без опций восстанавливает удалённую заметку. Опции:
+.RS
+.TP
+\fB\-\-id\fR ID
+Идентификатор заметки (обязателен).
+.TP
+\fB\-\-version\fR N
+Номер версии из вывода \fBhistory\fR.
+.TP
+\fB\-\-at\fR TIMESTAMP
+Взять последнюю версию, записанную не позже указанного момента.
+Форматы те же, что у \fB\-\-until\fR: неполная дата означает конец
+дня, месяца или года, время без зоны берётся местным.
+.RE
+
+.TP
+.B fsck
+Проверяет целостность хранилища: сегменты, манифест, id_index.json,
+index.bleve, lru_cache.json и imports.json. Каждая проблема выводится
+с местом, где она найдена. Оборванную сбоем последнюю запись сегмента
+отрезает уже открытие хранилища, поэтому и без \fB\-\-repair\fR она
+выводится как исправленная проблема torn_tail. Опции:
+.RS
+.TP
+\fB\-\-repair\fR
+Исправить найденное: битые записи переносятся в каталог \fIquarantine\fR,
+недостающие документы индексируются, устаревшие записи удаляются.
+.TP
+\fB\-\-json\fR
+Вывести отчёт в JSON.
+.RE
+
+.TP
+.B reindex
+Перестраивает полнотекстовый индекс \fIindex.bleve\fR с нуля по живым
+заметкам из сегментов. Нужна, если индекс повреждён или содержит
+документы удалённых заметок.
+
+.TP
+.B tags
+Выводит теги живых заметок с числом заметок и датой последнего
+изменения. Теги иерархичны: части разделяются символом /, и фильтр по
+тегу находит также всех его потомков; число заметок у тега считается
+так же. Опции:
+.RS
+.TP
+\fB\-\-tree\fR
+Показать иерархию тегов.
+.TP
+\fB\-\-json\fR
+Вывод в JSON: tag, direct (заметки ровно с этим тегом), total
+(с тегом или потомком) и last_used.
+.RE
+.IP
+Подкоманды меняют теги во всех заметках; потомки переезжают вместе с
+тегом, а изменённые заметки получают новые версии:
+.RS
+.TP
+\fBtags rename\fR \fIOLD NEW\fR
+Переименовать тег.
+.TP
+\fBtags merge\fR \fIA B...\fR \fB\-\-into\fR \fIC\fR
+Слить теги в один.
+.TP
+\fBtags rm\fR \fITAG\fR
+Снять тег со всех заметок.
 .RE
 
 .TP
@@ -196,6 +635,48 @@ noteline \- консольный блокнот с сегментированн
   man noteline
 .fi
 
+.SH ЯЗЫК ЗАПРОСОВ
+Запрос \fBlist\fR и \fBsearch\fR состоит из условий через пробел; все они
+должны выполняться.
+.TP
+.B слово
+Слово в заголовке, тексте, тегах или значениях полей метаданных (с
+учётом языка хранилища).
+.TP
+.B \(dqфраза\(dq
+Точная фраза.
+.TP
+.BR title: "слово, " text: слово
+Слово или фраза только в заголовке или только в тексте.
+.TP
+.B tag:ТЕГ
+Заметка с тегом ТЕГ или любым его потомком ТЕГ/...
+.TP
+.B meta.КЛЮЧ:ЗНАЧЕНИЕ
+Поле метаданных заметки, как у \fB\-\-where\fR: перед значением можно
+указать оператор >, >=, <, <= или =; meta.КЛЮЧ:* \- поле задано.
+.TP
+.BR created: "ДАТА, " updated: ДАТА
+Дата создания или изменения. Перед датой можно указать оператор
+>, >=, <, <= или =; A..B задаёт диапазон. Даты — в тех же форматах, что у
+\fB\-\-since\fR: YYYY, YYYY\-MM, YYYY\-MM\-DD, YYYY\-MM\-DDTHH:MM в местном
+времени, RFC3339, 7d, yesterday.
+.TP
+.BR \-условие ", " NOT " условие"
+Отрицание.
+.TP
+.IB a " OR " b
+Любое из условий; OR связывает сильнее пробела и AND.
+.TP
+.BR ( " ... " )
+Группировка.
+.PP
+Пример:
+.PP
+.nf
+  noteline search 'title:deploy tag:work \-tag:archived created:>2025\-01\-01 "exact phrase" OR incident'
+.fi
+
 .SH ХРАНЕНИЕ
 Каталог хранилища по умолчанию:
 .PP
@@ -204,11 +685,27 @@ noteline \- консольный блокнот с сегментированн
 Внутри:
 .PP
 .nf
-  manifest.json      \- метаданные хранилища
+  manifest.json      \- метаданные хранилища и политика fsync
+                       (always, interval, never)
   segments/notes\-*.ndjson \- сегменты с заметками
-  imports.json       \- индекс соответствия импортируемых файлов и заметок
+  id_index.json      \- первичный индекс: ID \-> сегмент и смещение записи
+  imports.json       \- индекс соответствия импортируемых файлов и заметок, состояние sync
+  index.bleve/       \- полнотекстовый индекс; при смене схемы или языка
+                       перестраивается автоматически
+  quarantine/        \- записи, убранные из сегментов командой fsck \-\-repair
+  noteline.lock      \- файл межпроцессной блокировки; хранит PID процесса,
+                       держащего эксклюзивную блокировку
 .fi
 
+.SH ОКРУЖЕНИЕ
+.TP
+.B NOTELINE_LANG
+Язык интерфейса: en или ru.
+.TP
+.B NOTELINE_LOCK_TIMEOUT
+Сколько ждать блокировку хранилища, занятую другим процессом
+(например, 500ms, 10s; 0 \- не ждать). По умолчанию 5s.
+
 .SH АВТОРЫ
 Проектная работа студентов ПМИ ВШЭ.`
 
@@ -221,29 +718,82 @@ _noteline_completion() {
   prev="${COMP_WORDS[COMP_CWORD-1]}"
 
   if [[ ${COMP_CWORD} -eq 1 ]]; then
-    COMPREPLY=( $(compgen -W "init create read update delete list search import completion manual man help" -- "$cur") )
+    COMPREPLY=( $(compgen -W "init create read update edit delete list search import export sync compact history restore fsck reindex tags completion manual man help" -- "$cur") )
     return
   fi
 
   case "${COMP_WORDS[1]}" in
+    init)
+      if [[ "$prev" == "--lang" ]]; then
+        COMPREPLY=( $(compgen -W "en ru" -- "$cur") )
+        return
+      fi
+      COMPREPLY=( $(compgen -W "--root --lang" -- "$cur") )
+      ;;
     create)
-      COMPREPLY=( $(compgen -W "--root --title --text --tags" -- "$cur") )
+      COMPREPLY=( $(compgen -W "--root --title --text --tags --set --edit" -- "$cur") )
       ;;
     read)
-      COMPREPLY=( $(compgen -W "--root --id --json" -- "$cur") )
+      COMPREPLY=( $(compgen -W "--root --id --title-match --json" -- "$cur") )
       ;;
     update)
-      COMPREPLY=( $(compgen -W "--root --id --title --text --tags" -- "$cur") )
+      COMPREPLY=( $(compgen -W "--root --id --title-match --title --text --tags --add-tags --remove-tags --append-text --prepend-text --set --unset --if-version" -- "$cur") )
+      ;;
+    edit)
+      COMPREPLY=( $(compgen -W "--root --id --title-match" -- "$cur") )
       ;;
     delete)
-      COMPREPLY=( $(compgen -W "--root --id" -- "$cur") )
+      COMPREPLY=( $(compgen -W "--root --id --title-match" -- "$cur") )
+      ;;
+    list)
+      if [[ "$prev" == "--color" ]]; then
+        COMPREPLY=( $(compgen -W "auto always never" -- "$cur") )
+        return
+      fi
+      COMPREPLY=( $(compgen -W "--root --tag --any-tag --not-tag --where --contains --query --since --until --updated-since --limit --color --json" -- "$cur") )
       ;;
-    list|search)
-      COMPREPLY=( $(compgen -W "--root --tag --contains --limit --json" -- "$cur") )
+    search)
+      if [[ "$prev" == "--sort" ]]; then
+        COMPREPLY=( $(compgen -W "relevance created updated title" -- "$cur") )
+        return
+      fi
+      if [[ "$prev" == "--color" ]]; then
+        COMPREPLY=( $(compgen -W "auto always never" -- "$cur") )
+        return
+      fi
+      COMPREPLY=( $(compgen -W "--root --tag --any-tag --not-tag --where --contains --query --since --until --updated-since --sort --limit --color --json" -- "$cur") )
       ;;
     import)
+      COMPREPLY=( $(compgen -W "--root --dir --ext --dry-run --verbose --prune --tag-orphans" -- "$cur") )
+      ;;
+    export)
+      if [[ "$prev" == "--layout" ]]; then
+        COMPREPLY=( $(compgen -W "flat by-tag by-date" -- "$cur") )
+        return
+      fi
+      COMPREPLY=( $(compgen -W "--root --dir --tag --layout" -- "$cur") )
+      ;;
+    sync)
       COMPREPLY=( $(compgen -W "--root --dir --ext --dry-run --verbose" -- "$cur") )
       ;;
+    compact)
+      COMPREPLY=( $(compgen -W "--root --json" -- "$cur") )
+      ;;
+    history)
+      COMPREPLY=( $(compgen -W "--root --id --title-match --json" -- "$cur") )
+      ;;
+    restore)
+      COMPREPLY=( $(compgen -W "--root --id --title-match --version --at" -- "$cur") )
+      ;;
+    fsck)
+      COMPREPLY=( $(compgen -W "--root --repair --json" -- "$cur") )
+      ;;
+    reindex)
+      COMPREPLY=( $(compgen -W "--root" -- "$cur") )
+      ;;
+    tags)
+      COMPREPLY=( $(compgen -W "list rename merge rm --root --tree --json --into" -- "$cur") )
+      ;;
     completion)
       COMPREPLY=( $(compgen -W "bash zsh fish" -- "$cur") )
       ;;
@@ -258,27 +808,60 @@ complete -F _noteline_completion noteline
 const ZshCompletion = `#compdef noteline
 
 _arguments -C \
-  '1:command:(init create read update delete list search import completion manual man help)' \
+  '1:command:(init create read update edit delete list search import export sync compact history restore fsck reindex tags completion manual man help)' \
   '*::arg:->args'
 
 case $words[1] in
+  init)
+    _arguments '--root[Путь к хранилищу]' '--lang[Язык поиска]:lang:(en ru)'
+    ;;
   create)
-    _arguments '--root[Путь к хранилищу]' '--title[Заголовок]' '--text[Текст]' '--tags[Теги через запятую]'
+    _arguments '--root[Путь к хранилищу]' '--title[Заголовок]' '--text[Текст]' '--tags[Теги через запятую]' '--set[Поле метаданных key=value]' '--edit[Написать в редакторе]'
     ;;
   read)
-    _arguments '--root[Путь к хранилищу]' '--id[ID заметки]' '--json[Вывод в JSON]'
+    _arguments '--root[Путь к хранилищу]' '--id[ID заметки]' '--title-match[Точный заголовок]' '--json[Вывод в JSON]'
     ;;
   update)
-    _arguments '--root[Путь к хранилищу]' '--id[ID заметки]' '--title[Новый заголовок]' '--text[Новый текст]' '--tags[Новые теги]'
+    _arguments '--root[Путь к хранилищу]' '--id[ID заметки]' '--title-match[Точный заголовок]' '--title[Новый заголовок]' '--text[Новый текст]' '--tags[Новые теги]' '--add-tags[Добавить теги]' '--remove-tags[Убрать теги]' '--append-text[Дописать в конец]' '--prepend-text[Дописать в начало]' '--set[Поле метаданных key=value]' '--unset[Убрать поле метаданных]' '--if-version[Ожидаемая версия]:version:'
+    ;;
+  edit)
+    _arguments '--root[Путь к хранилищу]' '--id[ID заметки]' '--title-match[Точный заголовок]'
     ;;
   delete)
-    _arguments '--root[Путь к хранилищу]' '--id[ID заметки]'
+    _arguments '--root[Путь к хранилищу]' '--id[ID заметки]' '--title-match[Точный заголовок]'
+    ;;
+  list)
+    _arguments '--root[Путь к хранилищу]' '--tag[Все теги]' '--any-tag[Любой из тегов]' '--not-tag[Без тегов]' '--where[Условие на поле метаданных]' '--contains[Подстрока поиска]' '--query[Запрос]' '--since[Созданные не раньше]' '--until[Созданные не позже]' '--updated-since[Изменённые не раньше]' '--limit[Лимит]' '--color[Подсветка]:when:(auto always never)' '--json[Вывод в JSON]'
     ;;
-  list|search)
-    _arguments '--root[Путь к хранилищу]' '--tag[Фильтр по тегу]' '--contains[Подстрока поиска]' '--limit[Лимит]' '--json[Вывод в JSON]'
+  search)
+    _arguments '--root[Путь к хранилищу]' '--tag[Все теги]' '--any-tag[Любой из тегов]' '--not-tag[Без тегов]' '--where[Условие на поле метаданных]' '--contains[Подстрока поиска]' '--query[Запрос]' '--since[Созданные не раньше]' '--until[Созданные не позже]' '--updated-since[Изменённые не раньше]' '--sort[Порядок]:order:(relevance created updated title)' '--limit[Лимит]' '--color[Подсветка]:when:(auto always never)' '--json[Вывод в JSON]'
     ;;
   import)
-    _arguments '--root[Путь к хранилищу]' '--dir[Каталог импорта]' '--ext[Расширения файлов]' '--dry-run[Без изменений]' '--verbose[Подробный отчёт]'
+    _arguments '--root[Путь к хранилищу]' '--dir[Каталог импорта]' '--ext[Расширения файлов]' '--dry-run[Без изменений]' '--verbose[Подробный отчёт]' '--prune[Удалить заметки пропавших файлов]' '--tag-orphans[Пометить тегом orphaned]'
+    ;;
+  export)
+    _arguments '--root[Путь к хранилищу]' '--dir[Каталог экспорта]' '--tag[Тег]' '--layout[Раскладка]:layout:(flat by-tag by-date)'
+    ;;
+  sync)
+    _arguments '--root[Путь к хранилищу]' '--dir[Каталог синхронизации]' '--ext[Расширения файлов]' '--dry-run[Без изменений]' '--verbose[Подробный отчёт]'
+    ;;
+  compact)
+    _arguments '--root[Путь к хранилищу]' '--json[Вывод в JSON]'
+    ;;
+  history)
+    _arguments '--root[Путь к хранилищу]' '--id[ID заметки]' '--title-match[Точный заголовок]' '--json[Вывод в JSON]'
+    ;;
+  restore)
+    _arguments '--root[Путь к хранилищу]' '--id[ID заметки]' '--title-match[Точный заголовок]' '--version[Номер версии]' '--at[Момент времени]'
+    ;;
+  fsck)
+    _arguments '--root[Путь к хранилищу]' '--repair[Исправить проблемы]' '--json[Вывод в JSON]'
+    ;;
+  reindex)
+    _arguments '--root[Путь к хранилищу]'
+    ;;
+  tags)
+    _arguments '1: :(list rename merge rm)' '--root[Путь к хранилищу]' '--tree[Иерархия тегов]' '--json[Вывод в JSON]' '--into[Итоговый тег для merge]:tag:'
     ;;
   completion)
     _arguments '1: :(bash zsh fish)'
@@ -289,35 +872,98 @@ esac
 // Скрипт автодополнения для fish.
 const FishCompletion = `# fish completion for noteline
 
-complete -c noteline -n "not __fish_seen_subcommand_from init create read update delete list search import completion manual man help" -a "init create read update delete list search import completion manual man help"
+complete -c noteline -n "not __fish_seen_subcommand_from init create read update edit delete list search import export sync compact history restore fsck reindex tags completion manual man help" -a "init create read update edit delete list search import export sync compact history restore fsck reindex tags completion manual man help"
 
+complete -c noteline -n "__fish_seen_subcommand_from init" -l root -d "Путь к хранилищу"
+complete -c noteline -n "__fish_seen_subcommand_from init" -l lang -x -a "en ru" -d "Язык поиска"
 complete -c noteline -n "__fish_seen_subcommand_from create" -s - -l root   -d "Путь к хранилищу"
 complete -c noteline -n "__fish_seen_subcommand_from create" -l title       -d "Заголовок"
 complete -c noteline -n "__fish_seen_subcommand_from create" -l text        -d "Текст"
 complete -c noteline -n "__fish_seen_subcommand_from create" -l tags        -d "Теги"
+complete -c noteline -n "__fish_seen_subcommand_from create" -l edit        -d "Написать в редакторе"
+complete -c noteline -n "__fish_seen_subcommand_from create" -l set         -x -d "Поле метаданных key=value"
 
 complete -c noteline -n "__fish_seen_subcommand_from read" -l root   -d "Путь к хранилищу"
 complete -c noteline -n "__fish_seen_subcommand_from read" -l id     -d "ID заметки"
+complete -c noteline -n "__fish_seen_subcommand_from read" -l title-match -d "Точный заголовок"
 complete -c noteline -n "__fish_seen_subcommand_from read" -l json   -d "Вывод в JSON"
 
-complete -c noteline -n "__fish_seen_subcommand_from update" -l root   -d "Путь к хранилищу"
-complete -c noteline -n "__fish_seen_subcommand_from update" -l id     -d "ID заметки"
-complete -c noteline -n "__fish_seen_subcommand_from update" -l title  -d "Новый заголовок"
-complete -c noteline -n "__fish_seen_subcommand_from update" -l text   -d "Новый текст"
-complete -c noteline -n "__fish_seen_subcommand_from update" -l tags   -d "Новые теги"
-
+complete -c noteline -n "__fish_seen_subcommand_from update" -l root         -d "Путь к хранилищу"
+complete -c noteline -n "__fish_seen_subcommand_from update" -l id           -d "ID заметки"
+complete -c noteline -n "__fish_seen_subcommand_from update" -l title-match -d "Точный заголовок"
+complete -c noteline -n "__fish_seen_subcommand_from update" -l title        -d "Новый заголовок"
+complete -c noteline -n "__fish_seen_subcommand_from update" -l text         -d "Новый текст"
+complete -c noteline -n "__fish_seen_subcommand_from update" -l tags         -d "Новые теги"
+complete -c noteline -n "__fish_seen_subcommand_from update" -l add-tags     -d "Добавить теги"
+complete -c noteline -n "__fish_seen_subcommand_from update" -l remove-tags  -d "Убрать теги"
+complete -c noteline -n "__fish_seen_subcommand_from update" -l append-text  -d "Дописать в конец"
+complete -c noteline -n "__fish_seen_subcommand_from update" -l prepend-text -d "Дописать в начало"
+complete -c noteline -n "__fish_seen_subcommand_from update" -l set          -x -d "Поле метаданных key=value"
+complete -c noteline -n "__fish_seen_subcommand_from update" -l unset        -x -d "Убрать поле метаданных"
+complete -c noteline -n "__fish_seen_subcommand_from update" -l if-version   -x -d "Ожидаемая версия"
+
+complete -c noteline -n "__fish_seen_subcommand_from edit" -l root   -d "Путь к хранилищу"
+complete -c noteline -n "__fish_seen_subcommand_from edit" -l id     -d "ID заметки"
+complete -c noteline -n "__fish_seen_subcommand_from edit" -l title-match -d "Точный заголовок"
 complete -c noteline -n "__fish_seen_subcommand_from delete" -l root   -d "Путь к хранилищу"
 complete -c noteline -n "__fish_seen_subcommand_from delete" -l id     -d "ID заметки"
+complete -c noteline -n "__fish_seen_subcommand_from delete" -l title-match -d "Точный заголовок"
 
 complete -c noteline -n "__fish_seen_subcommand_from list search" -l root     -d "Путь к хранилищу"
-complete -c noteline -n "__fish_seen_subcommand_from list search" -l tag      -d "Фильтр по тегу"
+complete -c noteline -n "__fish_seen_subcommand_from list search" -l tag      -d "Все указанные теги"
+complete -c noteline -n "__fish_seen_subcommand_from list search" -l any-tag  -d "Любой из тегов"
+complete -c noteline -n "__fish_seen_subcommand_from list search" -l not-tag  -d "Без этих тегов"
+complete -c noteline -n "__fish_seen_subcommand_from list search" -l where -x -d "Условие на поле метаданных"
 complete -c noteline -n "__fish_seen_subcommand_from list search" -l contains -d "Подстрока"
+complete -c noteline -n "__fish_seen_subcommand_from list search" -l query    -d "Запрос"
+complete -c noteline -n "__fish_seen_subcommand_from list search" -l since -x -a "today yesterday 7d" -d "Созданные не раньше"
+complete -c noteline -n "__fish_seen_subcommand_from list search" -l until -x -a "today yesterday" -d "Созданные не позже"
+complete -c noteline -n "__fish_seen_subcommand_from list search" -l updated-since -x -a "today yesterday 7d" -d "Изменённые не раньше"
 complete -c noteline -n "__fish_seen_subcommand_from list search" -l limit    -d "Лимит"
 complete -c noteline -n "__fish_seen_subcommand_from list search" -l json     -d "Вывод в JSON"
+complete -c noteline -n "__fish_seen_subcommand_from search" -l sort -x -a "relevance created updated title" -d "Порядок результатов"
+complete -c noteline -n "__fish_seen_subcommand_from list search" -l color -x -a "auto always never" -d "Подсветка совпадений"
 
 complete -c noteline -n "__fish_seen_subcommand_from import" -l root     -d "Путь к хранилищу"
 complete -c noteline -n "__fish_seen_subcommand_from import" -l dir      -d "Каталог импорта"
 complete -c noteline -n "__fish_seen_subcommand_from import" -l ext      -d "Расширения файлов"
 complete -c noteline -n "__fish_seen_subcommand_from import" -l dry-run  -d "Без изменений"
 complete -c noteline -n "__fish_seen_subcommand_from import" -l verbose  -d "Подробный отчёт"
+complete -c noteline -n "__fish_seen_subcommand_from import" -l prune    -d "Удалить заметки пропавших файлов"
+complete -c noteline -n "__fish_seen_subcommand_from import" -l tag-orphans -d "Пометить тегом orphaned"
+complete -c noteline -n "__fish_seen_subcommand_from export" -l root     -d "Путь к хранилищу"
+complete -c noteline -n "__fish_seen_subcommand_from export" -l dir      -d "Каталог экспорта"
+complete -c noteline -n "__fish_seen_subcommand_from export" -l tag      -d "Тег"
+complete -c noteline -n "__fish_seen_subcommand_from export" -l layout   -d "Раскладка" -a "flat by-tag by-date"
+complete -c noteline -n "__fish_seen_subcommand_from sync" -l root     -d "Путь к хранилищу"
+complete -c noteline -n "__fish_seen_subcommand_from sync" -l dir      -d "Каталог синхронизации"
+complete -c noteline -n "__fish_seen_subcommand_from sync" -l ext      -d "Расширения файлов"
+complete -c noteline -n "__fish_seen_subcommand_from sync" -l dry-run  -d "Без изменений"
+complete -c noteline -n "__fish_seen_subcommand_from sync" -l verbose  -d "Подробный отчёт"
+
+complete -c noteline -n "__fish_seen_subcommand_from compact" -l root -d "Путь к хранилищу"
+complete -c noteline -n "__fish_seen_subcommand_from compact" -l json -d "Вывод в JSON"
+
+complete -c noteline -n "__fish_seen_subcommand_from history" -l root -d "Путь к хранилищу"
+complete -c noteline -n "__fish_seen_subcommand_from history" -l id   -d "ID заметки"
+complete -c noteline -n "__fish_seen_subcommand_from history" -l title-match -d "Точный заголовок"
+complete -c noteline -n "__fish_seen_subcommand_from history" -l json -d "Вывод в JSON"
+
+complete -c noteline -n "__fish_seen_subcommand_from restore" -l root    -d "Путь к хранилищу"
+complete -c noteline -n "__fish_seen_subcommand_from restore" -l id      -d "ID заметки"
+complete -c noteline -n "__fish_seen_subcommand_from restore" -l title-match -d "Точный заголовок"
+complete -c noteline -n "__fish_seen_subcommand_from restore" -l version -d "Номер версии"
+complete -c noteline -n "__fish_seen_subcommand_from restore" -l at      -d "Момент времени"
+
+complete -c noteline -n "__fish_seen_subcommand_from fsck" -l root   -d "Путь к хранилищу"
+complete -c noteline -n "__fish_seen_subcommand_from fsck" -l repair -d "Исправить проблемы"
+complete -c noteline -n "__fish_seen_subcommand_from fsck" -l json   -d "Вывод в JSON"
+
+complete -c noteline -n "__fish_seen_subcommand_from reindex" -l root -d "Путь к хранилищу"
+
+complete -c noteline -n "__fish_seen_subcommand_from tags" -l root -d "Путь к хранилищу"
+complete -c noteline -n "__fish_seen_subcommand_from tags" -l tree -d "Иерархия тегов"
+complete -c noteline -n "__fish_seen_subcommand_from tags" -l json -d "Вывод в JSON"
+complete -c noteline -n "__fish_seen_subcommand_from tags" -l into -d "Итоговый тег для merge"
+complete -c noteline -n "__fish_seen_subcommand_from tags" -a "list rename merge rm"
 `
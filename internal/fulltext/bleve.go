@@ -2,6 +2,7 @@ package fulltext
 
 import (
 	"fmt"
+	"os"
 	"path/filepath"
 	"strings"
 	"sync"
@@ -18,26 +19,68 @@ var (
 	searchCache *lru.LRU
 )
 
-func Init(root string) error {
+// Init открывает индекс хранилища для записи. fresh сообщает, что индекс
+// создан пустым — впервые или потому, что схема поменялась, — и вызывающий
+// должен проиндексировать заметки заново.
+func Init(root, lang string) (fresh bool, err error) {
 	mu.Lock()
 	defer mu.Unlock()
 	if idx != nil {
-		return nil
+		return false, nil
 	}
 	path := filepath.Join(root, "index.bleve")
 
 	i, err := bleve.Open(path)
 	if err == nil {
-		idx = i
-		searchCache = lru.New(1024)
-		return nil
+		if err = checkMapping(i, lang); err == nil {
+			idx = i
+			searchCache = lru.New(1024)
+			return false, nil
+		}
+		_ = i.Close()
 	}
 
-	mapping := bleve.NewIndexMapping()
-	i, err = bleve.New(path, mapping)
+	if err := createIndex(path, lang); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// createIndex удаляет старый индекс и создаёт пустой с актуальной схемой.
+func createIndex(path, lang string) error {
+	if err := os.RemoveAll(path); err != nil {
+		return fmt.Errorf("fulltext: remove index: %w", err)
+	}
+	i, err := bleve.New(path, newMapping(lang))
 	if err != nil {
 		return fmt.Errorf("fulltext: create index: %w", err)
 	}
+	if err := i.SetInternal([]byte(mappingKey), mappingStamp(lang)); err != nil {
+		_ = i.Close()
+		return fmt.Errorf("fulltext: create index: %w", err)
+	}
+	idx = i
+	searchCache = lru.New(1024)
+	return nil
+}
+
+// InitReadOnly открывает существующий индекс только для чтения: так его
+// могут одновременно открыть несколько процессов. Если схема индекса
+// устарела, возвращается ErrMappingChanged.
+func InitReadOnly(root, lang string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if idx != nil {
+		return nil
+	}
+	i, err := bleve.OpenUsing(filepath.Join(root, "index.bleve"), map[string]interface{}{"read_only": true})
+	if err != nil {
+		return fmt.Errorf("fulltext: open index read-only: %w", err)
+	}
+	if err := checkMapping(i, lang); err != nil {
+		_ = i.Close()
+		return err
+	}
 	idx = i
 	searchCache = lru.New(1024)
 	return nil
@@ -64,60 +107,260 @@ func IndexNote(n *model.Note) error {
 		return fmt.Errorf("fulltext: index not initialized")
 	}
 
-	doc := struct {
-		Title string
-		Text  string
-		Tags  string
+	if err := idx.Index(n.ID, noteDoc(n)); err != nil {
+		return err
+	}
+
+	if searchCache != nil {
+		searchCache.Clear()
+	}
+	return nil
+
+}
+
+func noteDoc(n *model.Note) interface{} {
+	var paths []string
+	seen := map[string]bool{}
+	for _, t := range n.Tags {
+		for _, p := range model.TagPrefixes(t) {
+			if !seen[p] {
+				seen[p] = true
+				paths = append(paths, p)
+			}
+		}
+	}
+	pairs := model.MetaPairs(n.Meta)
+	values := make([]string, 0, len(pairs))
+	for _, p := range pairs {
+		_, v, _ := strings.Cut(p, "=")
+		values = append(values, v)
+	}
+	return struct {
+		Title     string
+		Text      string
+		Tags      string
+		TagPaths  []string
+		Meta      string
+		MetaPairs []string
 	}{
-		Title: n.Title,
-		Text:  n.Text,
-		Tags:  strings.Join(n.Tags, " "),
+		Title:     n.Title,
+		Text:      n.Text,
+		Tags:      strings.Join(n.Tags, " "),
+		TagPaths:  paths,
+		Meta:      strings.Join(values, " "),
+		MetaPairs: pairs,
+	}
+}
+
+// DeleteNote убирает документ заметки из индекса. Отсутствие документа
+// ошибкой не считается.
+func DeleteNote(id string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if idx == nil {
+		return fmt.Errorf("fulltext: index not initialized")
 	}
-	if err := idx.Index(n.ID, doc); err != nil {
+	if err := idx.Delete(id); err != nil {
 		return err
 	}
+	if searchCache != nil {
+		searchCache.Clear()
+	}
+	return nil
+}
 
+// Rebuild удаляет index.bleve и строит его заново из переданных заметок.
+func Rebuild(root, lang string, notes []model.Note) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if idx != nil {
+		if err := idx.Close(); err != nil {
+			return err
+		}
+		idx = nil
+	}
+	if err := createIndex(filepath.Join(root, "index.bleve"), lang); err != nil {
+		return err
+	}
+	return indexBatch(notes)
+}
+
+// IndexNotes индексирует заметки пачками; используется для заполнения
+// только что созданного индекса.
+func IndexNotes(notes []model.Note) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if idx == nil {
+		return fmt.Errorf("fulltext: index not initialized")
+	}
+	return indexBatch(notes)
+}
+
+// ApplyBatch индексирует notes и удаляет документы deleted одной пачкой.
+func ApplyBatch(notes []model.Note, deleted []string) error {
+	mu.Lock()
+	defer mu.Unlock()
+	if idx == nil {
+		return fmt.Errorf("fulltext: index not initialized")
+	}
+	if len(deleted) > 0 {
+		b := idx.NewBatch()
+		for _, id := range deleted {
+			b.Delete(id)
+		}
+		if err := idx.Batch(b); err != nil {
+			return err
+		}
+	}
+	return indexBatch(notes)
+}
+
+func indexBatch(notes []model.Note) error {
+	const batchSize = 500
+	b := idx.NewBatch()
+	for k := range notes {
+		if err := b.Index(notes[k].ID, noteDoc(&notes[k])); err != nil {
+			return err
+		}
+		if b.Size() >= batchSize {
+			if err := idx.Batch(b); err != nil {
+				return err
+			}
+			b.Reset()
+		}
+	}
+	if b.Size() > 0 {
+		if err := idx.Batch(b); err != nil {
+			return err
+		}
+	}
 	if searchCache != nil {
 		searchCache.Clear()
 	}
 	return nil
+}
 
+// Hit — документ, найденный по запросу, с оценкой релевантности bleve.
+type Hit struct {
+	ID    string
+	Score float64
 }
 
-func Search(q string, size int) ([]string, error) {
+// Search возвращает документы, подходящие под m, в порядке убывания
+// релевантности. При size <= 0 возвращаются все найденные документы.
+func Search(m Match, size int) ([]Hit, error) {
 	mu.Lock()
 	defer mu.Unlock()
 	if idx == nil {
 		return nil, fmt.Errorf("fulltext: index not initialized")
 	}
 	if size <= 0 {
-		size = 1000
+		count, err := idx.DocCount()
+		if err != nil {
+			return nil, err
+		}
+		size = max(int(count), 1)
 	}
-	key := fmt.Sprintf("%s|%d", q, size)
+	qq, err := m.query()
+	if err != nil {
+		return nil, err
+	}
+	key := fmt.Sprintf("%s|%d", m, size)
 	if searchCache != nil {
 		if v, ok := searchCache.Get(key); ok {
-			if ids, ok2 := v.([]string); ok2 {
-				out := make([]string, len(ids))
-				copy(out, ids)
+			if hits, ok2 := v.([]Hit); ok2 {
+				out := make([]Hit, len(hits))
+				copy(out, hits)
 				return out, nil
 			}
 		}
 	}
 
-	qq := bleve.NewQueryStringQuery(q)
 	req := bleve.NewSearchRequestOptions(qq, size, 0, false)
 	res, err := idx.Search(req)
 	if err != nil {
 		return nil, err
 	}
-	out := make([]string, 0, len(res.Hits))
+	out := make([]Hit, 0, len(res.Hits))
 	for _, h := range res.Hits {
-		out = append(out, h.ID)
+		out = append(out, Hit{ID: h.ID, Score: h.Score})
 	}
 	if searchCache != nil {
-		cp := make([]string, len(out))
+		cp := make([]Hit, len(out))
 		copy(cp, out)
 		searchCache.Add(key, cp)
 	}
 	return out, nil
 }
+
+// TagDocs возвращает документы с тегом tag или любым его потомком.
+func TagDocs(tag string) ([]string, error) {
+	tag = model.CleanTag(tag)
+	return termDocs("TagPaths", tag, "tag|"+tag)
+}
+
+// MetaDocs возвращает документы, у которых поле Meta key равно value
+// (без учёта регистра) или, для списка, содержит такой элемент.
+func MetaDocs(key, value string) ([]string, error) {
+	pair := strings.ToLower(key + "=" + value)
+	return termDocs("MetaPairs", pair, "meta|"+pair)
+}
+
+// termDocs возвращает все документы с точным термом в поле field.
+func termDocs(field, term, key string) ([]string, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if idx == nil {
+		return nil, fmt.Errorf("fulltext: index not initialized")
+	}
+	if searchCache != nil {
+		if v, ok := searchCache.Get(key); ok {
+			if ids, ok2 := v.([]string); ok2 {
+				return append([]string(nil), ids...), nil
+			}
+		}
+	}
+	count, err := idx.DocCount()
+	if err != nil {
+		return nil, err
+	}
+	q := bleve.NewTermQuery(term)
+	q.SetField(field)
+	res, err := idx.Search(bleve.NewSearchRequestOptions(q, max(int(count), 1), 0, false))
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, 0, len(res.Hits))
+	for _, h := range res.Hits {
+		out = append(out, h.ID)
+	}
+	if searchCache != nil {
+		searchCache.Add(key, append([]string(nil), out...))
+	}
+	return out, nil
+}
+
+func DocIDs() ([]string, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if idx == nil {
+		return nil, fmt.Errorf("fulltext: index not initialized")
+	}
+	count, err := idx.DocCount()
+	if err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, nil
+	}
+	req := bleve.NewSearchRequestOptions(bleve.NewMatchAllQuery(), int(count), 0, false)
+	res, err := idx.Search(req)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]string, 0, len(res.Hits))
+	for _, h := range res.Hits {
+		out = append(out, h.ID)
+	}
+	return out, nil
+}
@@ -1,6 +1,8 @@
 package fulltext
 
 import (
+	"sort"
+	"strings"
 	"testing"
 
 	"github.com/Victor3563/NoteLine/cli-notebook/internal/model"
@@ -9,7 +11,7 @@ import (
 func TestInitIndexAndSearch(t *testing.T) {
 	root := t.TempDir()
 
-	if err := Init(root); err != nil {
+	if _, err := Init(root, LangEnglish); err != nil {
 		t.Fatalf("Init: %v", err)
 	}
 	defer func() {
@@ -28,26 +30,136 @@ func TestInitIndexAndSearch(t *testing.T) {
 		t.Fatalf("IndexNote(n2): %v", err)
 	}
 
-	ids, err := Search("Hello", 10)
+	hits, err := Search(Match{Text: "Hello"}, 10)
 	if err != nil {
 		t.Fatalf("Search: %v", err)
 	}
-	if len(ids) == 0 {
+	if len(hits) == 0 {
 		t.Fatalf("Search returned no hits, want at least 1")
 	}
 
 	found := false
-	for _, id := range ids {
-		if id == n1.ID {
+	for _, h := range hits {
+		if h.ID == n1.ID {
 			found = true
 			break
 		}
 	}
 	if !found {
-		t.Fatalf("expected to find note ID %q in search results, got %v", n1.ID, ids)
+		t.Fatalf("expected to find note ID %q in search results, got %v", n1.ID, hits)
 	}
 
-	if _, err := Search("Hello", 10); err != nil {
+	if _, err := Search(Match{Text: "Hello"}, 10); err != nil {
 		t.Fatalf("Search (cached) failed: %v", err)
 	}
+
+	docs, err := DocIDs()
+	if err != nil {
+		t.Fatalf("DocIDs: %v", err)
+	}
+	if len(docs) != 2 {
+		t.Fatalf("DocIDs returned %v, want 2 hits", docs)
+	}
+
+	if err := DeleteNote(n1.ID); err != nil {
+		t.Fatalf("DeleteNote: %v", err)
+	}
+	hits, err = Search(Match{Text: "Hello"}, 10)
+	if err != nil {
+		t.Fatalf("Search after delete: %v", err)
+	}
+	if len(hits) != 0 {
+		t.Fatalf("Search after delete returned %v, want no hits", hits)
+	}
+
+	if err := Rebuild(root, LangEnglish, []model.Note{*n1}); err != nil {
+		t.Fatalf("Rebuild: %v", err)
+	}
+	docs, err = DocIDs()
+	if err != nil {
+		t.Fatalf("DocIDs after rebuild: %v", err)
+	}
+	if len(docs) != 1 || docs[0] != n1.ID {
+		t.Fatalf("DocIDs after rebuild = %v, want [%s]", docs, n1.ID)
+	}
+}
+
+func TestTagDocsMatchesHierarchy(t *testing.T) {
+	root := t.TempDir()
+	if _, err := Init(root, LangEnglish); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	defer Close()
+
+	notes := []*model.Note{
+		{ID: "a", Tags: []string{"project/noteline/bugs"}},
+		{ID: "b", Tags: []string{"project/notes", "go"}},
+		{ID: "c", Tags: []string{"Project"}},
+	}
+	for _, n := range notes {
+		if err := IndexNote(n); err != nil {
+			t.Fatalf("IndexNote: %v", err)
+		}
+	}
+
+	cases := map[string]string{
+		"project":               "a,b",
+		"project/noteline":      "a",
+		"project/noteline/bugs": "a",
+		"project/note":          "",
+		"Project":               "c",
+		"go/":                   "b",
+	}
+	for tag, want := range cases {
+		ids, err := TagDocs(tag)
+		if err != nil {
+			t.Fatalf("TagDocs(%q): %v", tag, err)
+		}
+		sort.Strings(ids)
+		if got := strings.Join(ids, ","); got != want {
+			t.Fatalf("TagDocs(%q) = %q, want %q", tag, got, want)
+		}
+	}
+}
+
+func TestMetaIndexed(t *testing.T) {
+	root := t.TempDir()
+	if _, err := Init(root, LangEnglish); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	defer Close()
+
+	notes := []*model.Note{
+		{ID: "a", Title: "one", Meta: map[string]any{"status": "Open", "owner": "alice"}},
+		{ID: "b", Title: "two", Meta: map[string]any{"status": "closed", "labels": []any{"x", "open"}}},
+		{ID: "c", Title: "three"},
+	}
+	for _, n := range notes {
+		if err := IndexNote(n); err != nil {
+			t.Fatalf("IndexNote: %v", err)
+		}
+	}
+
+	cases := map[[2]string]string{
+		{"status", "open"}:    "a",
+		{"Status", "OPEN"}:    "a",
+		{"labels", "open"}:    "b",
+		{"status", "missing"}: "",
+	}
+	for kv, want := range cases {
+		ids, err := MetaDocs(kv[0], kv[1])
+		if err != nil {
+			t.Fatalf("MetaDocs(%v): %v", kv, err)
+		}
+		sort.Strings(ids)
+		if got := strings.Join(ids, ","); got != want {
+			t.Fatalf("MetaDocs(%v) = %q, want %q", kv, got, want)
+		}
+	}
+
+	// Значения метаданных находит и обычный поиск.
+	hits, err := Search(Match{Text: "alice"}, 10)
+	if err != nil || len(hits) != 1 || hits[0].ID != "a" {
+		t.Fatalf("Search(alice) = %v, %v", hits, err)
+	}
 }
@@ -0,0 +1,171 @@
+package fulltext
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/registry"
+	"github.com/blevesearch/bleve/v2/search/highlight"
+	simpleFragmenter "github.com/blevesearch/bleve/v2/search/highlight/fragmenter/simple"
+	simpleHighlighter "github.com/blevesearch/bleve/v2/search/highlight/highlighter/simple"
+)
+
+const (
+	highlighterName = "noteline"
+	fragmentRunes   = 120
+
+	// Служебные байты, которыми форматтер размечает фрагмент: заголовок
+	// с границами фрагмента в поле и начало/конец совпадения.
+	markHeader = "\x01"
+	markOpen   = "\x02"
+	markClose  = "\x03"
+)
+
+// Span — совпадение внутри поля; смещения байтовые, в UTF-8 строке поля.
+type Span struct {
+	Start int `json:"start"`
+	End   int `json:"end"`
+}
+
+// Fragment — отрывок поля заметки с подсвеченными совпадениями.
+// Start и End — байтовые смещения отрывка в поле, Highlights — смещения
+// совпадений в том же поле.
+type Fragment struct {
+	Field      string `json:"field"`
+	Text       string `json:"text"`
+	Start      int    `json:"start"`
+	End        int    `json:"end"`
+	Highlights []Span `json:"highlights"`
+}
+
+// Поля документа в индексе и их имена во фрагментах, в порядке вывода.
+var fragmentFields = []struct{ doc, name string }{
+	{"Title", "title"},
+	{"Text", "text"},
+	{"Tags", "tags"},
+}
+
+// markFormatter не раскрашивает совпадения, а размечает их служебными
+// байтами, чтобы потом восстановить точные смещения.
+type markFormatter struct{}
+
+func (markFormatter) Format(f *highlight.Fragment, locs highlight.TermLocations) string {
+	var b strings.Builder
+	b.WriteString(markHeader + strconv.Itoa(f.Start) + ":" + strconv.Itoa(f.End) + markHeader)
+	curr := f.Start
+	for _, loc := range locs {
+		if loc == nil || !loc.ArrayPositions.Equals(f.ArrayPositions) || loc.Start < curr {
+			continue
+		}
+		if loc.End > f.End {
+			break
+		}
+		b.Write(f.Orig[curr:loc.Start])
+		b.WriteString(markOpen)
+		b.Write(f.Orig[loc.Start:loc.End])
+		b.WriteString(markClose)
+		curr = loc.End
+	}
+	b.Write(f.Orig[curr:f.End])
+	return b.String()
+}
+
+func init() {
+	err := registry.RegisterHighlighter(highlighterName, func(map[string]interface{}, *registry.Cache) (highlight.Highlighter, error) {
+		return simpleHighlighter.NewHighlighter(
+			simpleFragmenter.NewFragmenter(fragmentRunes),
+			markFormatter{},
+			simpleHighlighter.DefaultSeparator,
+		), nil
+	})
+	if err != nil {
+		panic(err)
+	}
+}
+
+// parseFragment разбирает строку, собранную markFormatter. Многоточия,
+// которые highlighter добавляет вокруг обрезанного отрывка, отбрасываются:
+// длина отрывка известна из заголовка.
+func parseFragment(field, s string) (Fragment, bool) {
+	i := strings.Index(s, markHeader)
+	if i < 0 {
+		return Fragment{}, false
+	}
+	head, body, ok := strings.Cut(s[i+len(markHeader):], markHeader)
+	if !ok {
+		return Fragment{}, false
+	}
+	a, b, ok := strings.Cut(head, ":")
+	if !ok {
+		return Fragment{}, false
+	}
+	start, err1 := strconv.Atoi(a)
+	end, err2 := strconv.Atoi(b)
+	if err1 != nil || err2 != nil {
+		return Fragment{}, false
+	}
+
+	fr := Fragment{Field: field, Start: start, End: end, Highlights: []Span{}}
+	var text strings.Builder
+	open := -1
+	for i := 0; i < len(body); i++ {
+		switch body[i] {
+		case markOpen[0]:
+			open = start + text.Len()
+		case markClose[0]:
+			if open >= 0 {
+				fr.Highlights = append(fr.Highlights, Span{Start: open, End: start + text.Len()})
+				open = -1
+			}
+		default:
+			if text.Len() < end-start {
+				text.WriteByte(body[i])
+			}
+		}
+	}
+	fr.Text = text.String()
+	return fr, true
+}
+
+// Fragments возвращает по одному лучшему отрывку на каждое поле, в котором
+// нашлось одно из условий ms, для документов ids.
+func Fragments(ms []Match, ids []string) (map[string][]Fragment, error) {
+	mu.Lock()
+	defer mu.Unlock()
+	if idx == nil {
+		return nil, fmt.Errorf("fulltext: index not initialized")
+	}
+	if len(ids) == 0 || len(ms) == 0 {
+		return map[string][]Fragment{}, nil
+	}
+
+	anyOf := bleve.NewDisjunctionQuery()
+	for _, m := range ms {
+		q, err := m.query()
+		if err != nil {
+			return nil, err
+		}
+		anyOf.AddQuery(q)
+	}
+	qq := bleve.NewConjunctionQuery(anyOf, bleve.NewDocIDQuery(ids))
+	req := bleve.NewSearchRequestOptions(qq, len(ids), 0, false)
+	req.Highlight = bleve.NewHighlightWithStyle(highlighterName)
+	res, err := idx.Search(req)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(map[string][]Fragment, len(res.Hits))
+	for _, h := range res.Hits {
+		for _, f := range fragmentFields {
+			for _, raw := range h.Fragments[f.doc] {
+				if fr, ok := parseFragment(f.name, raw); ok && len(fr.Highlights) > 0 {
+					out[h.ID] = append(out[h.ID], fr)
+				}
+			}
+		}
+	}
+	return out, nil
+}
@@ -0,0 +1,61 @@
+package fulltext
+
+import (
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/model"
+)
+
+func TestFragmentsCarryOffsets(t *testing.T) {
+	root := t.TempDir()
+	if _, err := Init(root, LangEnglish); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	defer Close()
+
+	text := strings.Repeat("Ёжики и ёлки. ", 20) + "Здесь спрятан kiwi, " + strings.Repeat("дальше снова ёлки. ", 20)
+	n := &model.Note{ID: "a", Title: "Про kiwi", Text: text, Tags: []string{"fruit"}}
+	other := &model.Note{ID: "b", Title: "Kiwi", Text: "kiwi"}
+	for _, x := range []*model.Note{n, other} {
+		if err := IndexNote(x); err != nil {
+			t.Fatalf("IndexNote: %v", err)
+		}
+	}
+
+	got, err := Fragments([]Match{{Text: "kiwi"}}, []string{"a"})
+	if err != nil {
+		t.Fatalf("Fragments: %v", err)
+	}
+	if _, ok := got["b"]; ok {
+		t.Fatalf("Fragments returned a document that was not requested")
+	}
+	frs := got["a"]
+	if len(frs) != 2 || frs[0].Field != "title" || frs[1].Field != "text" {
+		t.Fatalf("Fragments = %+v, want title and text fragments", frs)
+	}
+
+	for _, fr := range frs {
+		field := n.Title
+		if fr.Field == "text" {
+			field = n.Text
+		}
+		if !utf8.ValidString(fr.Text) {
+			t.Fatalf("fragment %q is not valid UTF-8", fr.Text)
+		}
+		if field[fr.Start:fr.End] != fr.Text {
+			t.Fatalf("fragment offsets %d:%d do not match its text", fr.Start, fr.End)
+		}
+		if len(fr.Highlights) != 1 {
+			t.Fatalf("fragment %q has %d highlights, want 1", fr.Text, len(fr.Highlights))
+		}
+		h := fr.Highlights[0]
+		if field[h.Start:h.End] != "kiwi" {
+			t.Fatalf("highlight %d:%d = %q, want %q", h.Start, h.End, field[h.Start:h.End], "kiwi")
+		}
+	}
+	if frs[1].Start == 0 || frs[1].End == len(n.Text) {
+		t.Fatalf("text fragment %d:%d is not cut around the match", frs[1].Start, frs[1].End)
+	}
+}
@@ -0,0 +1,94 @@
+package fulltext
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/keyword"
+	"github.com/blevesearch/bleve/v2/analysis/analyzer/standard"
+	"github.com/blevesearch/bleve/v2/analysis/lang/en"
+	"github.com/blevesearch/bleve/v2/analysis/lang/ru"
+	"github.com/blevesearch/bleve/v2/mapping"
+)
+
+const (
+	LangEnglish = "en"
+	LangRussian = "ru"
+
+	// mappingVersion увеличивается при любом изменении схемы индекса:
+	// индекс со старой версией перестраивается при открытии.
+	mappingVersion = 3
+	mappingKey     = "noteline:mapping"
+)
+
+var ErrMappingChanged = errors.New("fulltext: index mapping changed, rebuild required")
+
+// ValidLanguage сообщает, есть ли для языка анализатор.
+func ValidLanguage(lang string) bool {
+	return lang == LangEnglish || lang == LangRussian
+}
+
+func analyzerFor(lang string) string {
+	if lang == LangRussian {
+		return ru.AnalyzerName
+	}
+	return en.AnalyzerName
+}
+
+// mappingStamp — отметка схемы, которая хранится во внутренних данных
+// индекса и сверяется при открытии.
+func mappingStamp(lang string) []byte {
+	return []byte(fmt.Sprintf("%d/%s", mappingVersion, analyzerFor(lang)))
+}
+
+// newMapping описывает документ заметки явно: заголовок и текст
+// разбираются анализатором языка хранилища (стемминг и стоп-слова),
+// теги — стандартным анализатором без стемминга. TagPaths хранит каждый
+// тег и всех его предков целиком, чтобы по префиксу иерархии искать
+// точным термом. Значения Meta ищутся как текст вместе с остальными
+// полями, а MetaPairs хранит пары "ключ=значение" для точных фильтров.
+func newMapping(lang string) mapping.IndexMapping {
+	analyzer := analyzerFor(lang)
+
+	text := bleve.NewTextFieldMapping()
+	text.Analyzer = analyzer
+	text.Store = true
+	text.IncludeTermVectors = true
+
+	tags := bleve.NewTextFieldMapping()
+	tags.Analyzer = standard.Name
+	tags.Store = true
+	tags.IncludeTermVectors = true
+
+	paths := bleve.NewTextFieldMapping()
+	paths.Analyzer = keyword.Name
+	paths.IncludeInAll = false
+
+	doc := bleve.NewDocumentStaticMapping()
+	doc.AddFieldMappingsAt("Title", text)
+	doc.AddFieldMappingsAt("Text", text)
+	doc.AddFieldMappingsAt("Tags", tags)
+	doc.AddFieldMappingsAt("TagPaths", paths)
+
+	meta := bleve.NewTextFieldMapping()
+	meta.Analyzer = analyzer
+	doc.AddFieldMappingsAt("Meta", meta)
+	doc.AddFieldMappingsAt("MetaPairs", paths)
+
+	m := bleve.NewIndexMapping()
+	m.DefaultMapping = doc
+	m.DefaultAnalyzer = analyzer
+	return m
+}
+
+func checkMapping(i bleve.Index, lang string) error {
+	got, err := i.GetInternal([]byte(mappingKey))
+	if err != nil {
+		return err
+	}
+	if string(got) != string(mappingStamp(lang)) {
+		return ErrMappingChanged
+	}
+	return nil
+}
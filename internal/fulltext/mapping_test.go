@@ -0,0 +1,78 @@
+package fulltext
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/model"
+)
+
+func TestRussianStemming(t *testing.T) {
+	root := t.TempDir()
+	fresh, err := Init(root, LangRussian)
+	if err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	defer Close()
+	if !fresh {
+		t.Fatalf("new index is not reported as fresh")
+	}
+
+	n := &model.Note{ID: "1", Title: "Заметки", Text: "Мы обсуждали развёртывание сервисов и базы данных"}
+	if err := IndexNote(n); err != nil {
+		t.Fatalf("IndexNote: %v", err)
+	}
+	for _, q := range []string{"сервис", "заметка", "обсуждать", "базе"} {
+		hits, err := Search(Match{Text: q}, 10)
+		if err != nil {
+			t.Fatalf("Search(%q): %v", q, err)
+		}
+		if len(hits) != 1 {
+			t.Fatalf("Search(%q) returned %d hits, want 1", q, len(hits))
+		}
+	}
+	// Стоп-слова не находят ничего.
+	if hits, _ := Search(Match{Text: "и"}, 10); len(hits) != 0 {
+		t.Fatalf("stop word matched %d documents", len(hits))
+	}
+}
+
+func TestMappingChangeRecreatesIndex(t *testing.T) {
+	root := t.TempDir()
+	if _, err := Init(root, LangEnglish); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	if err := IndexNote(&model.Note{ID: "1", Title: "running"}); err != nil {
+		t.Fatalf("IndexNote: %v", err)
+	}
+	if err := Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// Та же схема открывается как есть.
+	fresh, err := Init(root, LangEnglish)
+	if err != nil || fresh {
+		t.Fatalf("reopen: fresh=%v err=%v, want existing index", fresh, err)
+	}
+	_ = Close()
+
+	if err := InitReadOnly(root, LangRussian); !errors.Is(err, ErrMappingChanged) {
+		t.Fatalf("InitReadOnly with another language: %v, want ErrMappingChanged", err)
+	}
+
+	fresh, err = Init(root, LangRussian)
+	if err != nil {
+		t.Fatalf("Init ru: %v", err)
+	}
+	defer Close()
+	if !fresh {
+		t.Fatalf("index with another language was not recreated")
+	}
+	docs, err := DocIDs()
+	if err != nil {
+		t.Fatalf("DocIDs: %v", err)
+	}
+	if len(docs) != 0 {
+		t.Fatalf("recreated index has documents: %v", docs)
+	}
+}
@@ -0,0 +1,65 @@
+package fulltext
+
+import (
+	"fmt"
+
+	"github.com/blevesearch/bleve/v2"
+	"github.com/blevesearch/bleve/v2/search/query"
+)
+
+// Поля заметки, по которым можно ограничить Match.
+const (
+	FieldTitle = "title"
+	FieldText  = "text"
+	FieldTags  = "tags"
+)
+
+// Match — одно текстовое условие запроса: слова или точная фраза в
+// заданном поле. Пустое Field означает заголовок, текст, теги и значения
+// метаданных сразу.
+// Текст анализируется тем же анализатором, что и при индексации, поэтому
+// синтаксис bleve в нём не интерпретируется.
+type Match struct {
+	Field  string
+	Text   string
+	Phrase bool
+}
+
+func (m Match) String() string {
+	return fmt.Sprintf("%s|%s|%t", m.Field, m.Text, m.Phrase)
+}
+
+func (m Match) query() (query.Query, error) {
+	var fields []string
+	switch m.Field {
+	case "":
+		fields = []string{"Title", "Text", "Tags", "Meta"}
+	case FieldTitle:
+		fields = []string{"Title"}
+	case FieldText:
+		fields = []string{"Text"}
+	case FieldTags:
+		fields = []string{"Tags"}
+	default:
+		return nil, fmt.Errorf("fulltext: unknown field %q", m.Field)
+	}
+
+	qs := make([]query.Query, 0, len(fields))
+	for _, f := range fields {
+		if m.Phrase {
+			q := bleve.NewMatchPhraseQuery(m.Text)
+			q.SetField(f)
+			qs = append(qs, q)
+		} else {
+			// Все слова условия должны встретиться в поле.
+			q := bleve.NewMatchQuery(m.Text)
+			q.SetField(f)
+			q.SetOperator(query.MatchQueryOperatorAnd)
+			qs = append(qs, q)
+		}
+	}
+	if len(qs) == 1 {
+		return qs[0], nil
+	}
+	return bleve.NewDisjunctionQuery(qs...), nil
+}
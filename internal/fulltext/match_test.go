@@ -0,0 +1,62 @@
+package fulltext
+
+import (
+	"testing"
+
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/model"
+)
+
+func TestMatchFieldsAndPhrases(t *testing.T) {
+	root := t.TempDir()
+	if _, err := Init(root, LangEnglish); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	defer Close()
+
+	notes := []*model.Note{
+		{ID: "a", Title: "Deploy checklist", Text: "rollback plan for the release", Tags: []string{"work"}},
+		{ID: "b", Title: "Release notes", Text: "we deploy on friday, plan rollback", Tags: []string{"ops"}},
+	}
+	for _, n := range notes {
+		if err := IndexNote(n); err != nil {
+			t.Fatalf("IndexNote: %v", err)
+		}
+	}
+
+	cases := []struct {
+		m    Match
+		want []string
+	}{
+		{Match{Text: "deploy"}, []string{"a", "b"}},
+		{Match{Field: FieldTitle, Text: "deploy"}, []string{"a"}},
+		{Match{Field: FieldText, Text: "deploy"}, []string{"b"}},
+		{Match{Field: FieldTags, Text: "ops"}, []string{"b"}},
+		{Match{Text: "rollback plan", Phrase: true}, []string{"a"}},
+		{Match{Text: "rollback plan"}, []string{"a", "b"}},
+		{Match{Text: "deploy friday"}, []string{"b"}},
+		// Синтаксис query string bleve больше не интерпретируется.
+		{Match{Text: "deploy -friday"}, []string{"b"}},
+	}
+	for _, c := range cases {
+		hits, err := Search(c.m, 0)
+		if err != nil {
+			t.Fatalf("Search(%v): %v", c.m, err)
+		}
+		got := map[string]bool{}
+		for _, h := range hits {
+			got[h.ID] = true
+		}
+		if len(got) != len(c.want) {
+			t.Fatalf("Search(%v) = %v, want %v", c.m, hits, c.want)
+		}
+		for _, id := range c.want {
+			if !got[id] {
+				t.Fatalf("Search(%v) = %v, want %v", c.m, hits, c.want)
+			}
+		}
+	}
+
+	if _, err := Search(Match{Field: "body", Text: "x"}, 0); err == nil {
+		t.Fatalf("Search with unknown field succeeded")
+	}
+}
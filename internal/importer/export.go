@@ -0,0 +1,145 @@
+package importer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/model"
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/store"
+)
+
+// Раскладка файлов при экспорте.
+const (
+	LayoutFlat   = "flat"
+	LayoutByTag  = "by-tag"
+	LayoutByDate = "by-date"
+)
+
+type ExportReport struct {
+	Root     string   `json:"root"`
+	Dir      string   `json:"dir"`
+	Exported int      `json:"exported"`
+	Files    []string `json:"files,omitempty"`
+}
+
+// ExportDir записывает живые заметки (с тегом tag, если он задан) в dir
+// markdown-файлами в формате FormatMarkdown, так что ImportDir по этому
+// каталогу восстанавливает те же заметки.
+func ExportDir(root, dir, tag, layout string) (*ExportReport, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("пустой каталог экспорта")
+	}
+	if layout == "" {
+		layout = LayoutFlat
+	}
+	switch layout {
+	case LayoutFlat, LayoutByTag, LayoutByDate:
+	default:
+		return nil, fmt.Errorf("неизвестная раскладка %q (flat, by-tag, by-date)", layout)
+	}
+
+	s, err := store.OpenWith(root, store.Options{Lock: store.LockShared})
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+
+	filter := store.Filter{Sort: store.SortCreated}
+	if tag = model.CleanTag(tag); tag != "" {
+		filter.Tags = []string{tag}
+	}
+	hits, err := s.Search(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	rep := &ExportReport{Root: root, Dir: dir}
+	used := make(map[string]bool)
+	for i := range hits {
+		n := &hits[i].Note
+		rel := exportPath(n, tag, layout, false)
+		if used[rel] {
+			rel = exportPath(n, tag, layout, true)
+		}
+		used[rel] = true
+
+		path := filepath.Join(dir, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return rep, err
+		}
+		if err := os.WriteFile(path, []byte(FormatMarkdown(n)), 0o644); err != nil {
+			return rep, err
+		}
+		// Время файла — время последнего изменения заметки.
+		_ = os.Chtimes(path, n.UpdatedAt, n.UpdatedAt)
+
+		rep.Exported++
+		rep.Files = append(rep.Files, filepath.ToSlash(rel))
+	}
+	return rep, nil
+}
+
+// exportPath строит путь файла заметки относительно каталога экспорта.
+// Имя — заголовок и начало id; fullID нужен, когда такое имя уже занято.
+func exportPath(n *model.Note, tag, layout string, fullID bool) string {
+	id := slugify(n.ID, 0)
+	if !fullID && len([]rune(id)) > 8 {
+		id = string([]rune(id)[:8])
+	}
+	name := id + ".md"
+	if slug := slugify(n.Title, 60); slug != "" {
+		name = slug + "-" + name
+	}
+
+	switch layout {
+	case LayoutByTag:
+		// Каталог — первый тег заметки, а при фильтре по тегу — первый
+		// тег под ним; заметки без тегов лежат в корне.
+		var dirTag string
+		for _, t := range n.Tags {
+			if tag == "" || model.TagUnder(t, tag) {
+				dirTag = t
+				break
+			}
+		}
+		var parts []string
+		for _, p := range strings.Split(dirTag, model.TagSeparator) {
+			if p = slugify(p, 60); p != "" {
+				parts = append(parts, p)
+			}
+		}
+		return filepath.Join(append(parts, name)...)
+	case LayoutByDate:
+		created := n.CreatedAt.UTC()
+		return filepath.Join(created.Format("2006"), created.Format("01"), name)
+	}
+	return name
+}
+
+// slugify оставляет от s буквы и цифры, заменяя остальное дефисами, и
+// обрезает результат до max рун (0 — без ограничения).
+func slugify(s string, max int) string {
+	var b strings.Builder
+	dash := false
+	count := 0
+	for _, r := range strings.ToLower(s) {
+		if max > 0 && count >= max {
+			break
+		}
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+				count++
+			}
+			b.WriteRune(r)
+			count++
+			dash = false
+			continue
+		}
+		dash = true
+	}
+	return strings.Trim(b.String(), "-")
+}
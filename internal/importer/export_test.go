@@ -0,0 +1,92 @@
+package importer
+
+import (
+	"path/filepath"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/model"
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/store"
+)
+
+func TestExportImportRoundTrip(t *testing.T) {
+	src := t.TempDir()
+	s, err := store.Open(src)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	a := model.NewNote("Заметка: первая", "\nline 1\n\nline 2\n", []string{"project/noteline", "go"})
+	a.CreatedAt = time.Date(2024, 11, 3, 10, 20, 30, 123456789, time.UTC)
+	a.UpdatedAt = a.CreatedAt.Add(time.Hour)
+	a.Meta = map[string]any{"status": "open", "due": "2024-12-01", "params": map[string]any{"n": float64(1)}}
+	b := model.NewNote("Untagged", "text", nil)
+	gone := model.NewNote("Gone", "text", []string{"go"})
+	for _, n := range []*model.Note{a, b, gone} {
+		if err := s.Append(n); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+	del := *gone
+	del.Deleted = true
+	if err := s.Append(&del); err != nil {
+		t.Fatalf("Append deleted: %v", err)
+	}
+	s.Close()
+
+	want := map[string]string{
+		LayoutFlat:   "заметка-первая-" + a.ID[:8] + ".md",
+		LayoutByTag:  filepath.Join("project", "noteline", "заметка-первая-"+a.ID[:8]+".md"),
+		LayoutByDate: filepath.Join("2024", "11", "заметка-первая-"+a.ID[:8]+".md"),
+	}
+	for layout, path := range want {
+		dir := t.TempDir()
+		rep, err := ExportDir(src, dir, "", layout)
+		if err != nil {
+			t.Fatalf("ExportDir(%s): %v", layout, err)
+		}
+		if rep.Exported != 2 {
+			t.Fatalf("%s: Exported=%d, want 2", layout, rep.Exported)
+		}
+		if !slices.Contains(rep.Files, filepath.ToSlash(path)) {
+			t.Fatalf("%s: Files=%v, want %s", layout, rep.Files, path)
+		}
+
+		dst := t.TempDir()
+		irep, err := ImportDir(dst, dir, ImportOptions{})
+		if err != nil || irep.Created != 2 || irep.Errors != 0 {
+			t.Fatalf("%s: ImportDir = %+v, %v", layout, irep, err)
+		}
+		ds, err := store.OpenWith(dst, store.Options{Lock: store.LockShared})
+		if err != nil {
+			t.Fatalf("Open dst: %v", err)
+		}
+		for _, orig := range []*model.Note{a, b} {
+			got, err := ds.GetByID(orig.ID)
+			if err != nil {
+				t.Fatalf("%s: GetByID: %v", layout, err)
+			}
+			if got.Title != orig.Title || got.Text != orig.Text || !reflect.DeepEqual(got.Tags, orig.Tags) || !reflect.DeepEqual(got.Meta, orig.Meta) ||
+				!got.CreatedAt.Equal(orig.CreatedAt) || !got.UpdatedAt.Equal(orig.UpdatedAt) {
+				t.Fatalf("%s: round trip\n got %+v\nwant %+v", layout, got, orig)
+			}
+		}
+		ds.Close()
+
+		// Повторный импорт выгрузки в исходное хранилище ничего не меняет.
+		irep, err = ImportDir(src, dir, ImportOptions{})
+		if err != nil || irep.Skipped != 2 {
+			t.Fatalf("%s: reimport = %+v, %v", layout, irep, err)
+		}
+	}
+
+	dir := t.TempDir()
+	rep, err := ExportDir(src, dir, "project", LayoutByTag)
+	if err != nil || rep.Exported != 1 {
+		t.Fatalf("ExportDir(tag) = %+v, %v", rep, err)
+	}
+	if _, err := ExportDir(src, dir, "", "tree"); err == nil {
+		t.Fatal("ExportDir with unknown layout: want error")
+	}
+}
@@ -0,0 +1,195 @@
+package importer
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/model"
+)
+
+// frontMatter — разобранный блок front matter. Ключи приведены к нижнему
+// регистру, значения типизированы: строки, числа, bool, время, []any и
+// map[string]any.
+type frontMatter map[string]any
+
+// str возвращает значение ключа строкой; время — в RFC3339.
+func (fm frontMatter) str(key string) string {
+	switch v := fm[key].(type) {
+	case nil:
+		return ""
+	case string:
+		return strings.TrimSpace(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	default:
+		return strings.TrimSpace(fmt.Sprint(v))
+	}
+}
+
+// timeOf разбирает значение ключа как время; время без зоны — UTC.
+func (fm frontMatter) timeOf(key string) (time.Time, bool) {
+	switch v := fm[key].(type) {
+	case time.Time:
+		// Локальные дата и время TOML не несут зоны.
+		if name := v.Location().String(); strings.HasSuffix(name, "-local") {
+			v = time.Date(v.Year(), v.Month(), v.Day(), v.Hour(), v.Minute(), v.Second(), v.Nanosecond(), time.UTC)
+		}
+		return v.UTC(), true
+	case string:
+		if t, err := ParseTimeFlexible(strings.TrimSpace(v)); err == nil {
+			return t.UTC(), true
+		}
+	}
+	return time.Time{}, false
+}
+
+// created и updated — времена заметки, в том числе под именами Hugo.
+func (fm frontMatter) created() (time.Time, bool) {
+	if t, ok := fm.timeOf("created"); ok {
+		return t, true
+	}
+	return fm.timeOf("date")
+}
+
+func (fm frontMatter) updated() (time.Time, bool) {
+	if t, ok := fm.timeOf("updated"); ok {
+		return t, true
+	}
+	return fm.timeOf("lastmod")
+}
+
+// tags принимает и список, и строку через запятую.
+func (fm frontMatter) tags() []string {
+	list, ok := fm["tags"].([]any)
+	if !ok {
+		return parseTags(fm.str("tags"))
+	}
+	var tags []string
+	for _, v := range list {
+		if v == nil {
+			continue
+		}
+		if t := strings.TrimSpace(fmt.Sprint(v)); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+// extra возвращает ключи, которые не стали полями заметки
+// (model.ReservedMetaKey); они попадают в Note.Meta.
+func (fm frontMatter) extra() map[string]any {
+	var out map[string]any
+	for k, v := range fm {
+		if model.ReservedMetaKey(k) {
+			continue
+		}
+		if out == nil {
+			out = make(map[string]any)
+		}
+		out[k] = v
+	}
+	return out
+}
+
+// splitFrontMatter отделяет front matter от тела. Блок между строками
+// "---" разбирается как YAML, между "+++" — как TOML (как у Hugo). Если
+// YAML не разбирается (например, title: a: b), блок читается по-старому:
+// строка "ключ: значение", значения — строки. Без закрывающей строки или
+// с некорректным TOML весь текст считается телом.
+func splitFrontMatter(content string) (frontMatter, string) {
+	lines := strings.Split(content, "\n")
+	delim := strings.TrimSpace(lines[0])
+	if delim != "---" && delim != "+++" {
+		return frontMatter{}, content
+	}
+
+	end := -1
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) == delim {
+			end = i
+			break
+		}
+	}
+	if end == -1 {
+		return frontMatter{}, content
+	}
+
+	block := strings.Join(lines[1:end], "\n")
+	body := ""
+	if end+1 < len(lines) {
+		body = strings.Join(lines[end+1:], "\n")
+	}
+
+	if delim == "+++" {
+		var raw map[string]any
+		if _, err := toml.Decode(block, &raw); err != nil {
+			return frontMatter{}, content
+		}
+		fm := make(frontMatter, len(raw))
+		for k, v := range raw {
+			fm[strings.ToLower(k)] = v
+		}
+		return fm, body
+	}
+
+	if fm, err := parseYAMLFrontMatter(block); err == nil {
+		return fm, body
+	}
+	return parseLegacyFrontMatter(lines[1:end]), body
+}
+
+func parseYAMLFrontMatter(block string) (frontMatter, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal([]byte(block), &doc); err != nil {
+		return nil, err
+	}
+	fm := frontMatter{}
+	if len(doc.Content) == 0 {
+		return fm, nil
+	}
+	m := doc.Content[0]
+	if m.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("front matter is not a mapping")
+	}
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		key := strings.ToLower(m.Content[i].Value)
+		val := m.Content[i+1]
+		// title и id берутся как написаны: 0123 не должен стать числом.
+		if (key == "title" || key == "id") && val.Kind == yaml.ScalarNode {
+			if val.Tag != "!!null" {
+				fm[key] = val.Value
+			}
+			continue
+		}
+		var v any
+		if err := val.Decode(&v); err != nil {
+			return nil, err
+		}
+		fm[key] = v
+	}
+	return fm, nil
+}
+
+// parseLegacyFrontMatter — прежний построчный разбор для блоков, которые
+// не являются корректным YAML.
+func parseLegacyFrontMatter(lines []string) frontMatter {
+	fm := frontMatter{}
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, ":", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		key := strings.ToLower(strings.TrimSpace(parts[0]))
+		fm[key] = strings.TrimSpace(parts[1])
+	}
+	return fm
+}
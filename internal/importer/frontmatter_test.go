@@ -0,0 +1,94 @@
+package importer
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestSplitFrontMatterYAML(t *testing.T) {
+	content := `---
+title: "Deploy: checklist"
+tags:
+  - ops
+  - project/noteline
+created: 2025-01-02
+Status: open
+priority: 2
+summary: |
+  first line
+  second line
+params:
+  owner: ann
+  links: [a, b]
+---
+Body`
+	meta, body := splitFrontMatter(content)
+	if body != "Body" {
+		t.Fatalf("body = %q", body)
+	}
+	n, err := buildNoteFromMarkdown(meta, body, "note.md", nil)
+	if err != nil {
+		t.Fatalf("buildNoteFromMarkdown: %v", err)
+	}
+	if n.Title != "Deploy: checklist" || !reflect.DeepEqual(n.Tags, []string{"ops", "project/noteline"}) {
+		t.Fatalf("note = %+v", n)
+	}
+	if want := time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC); !n.CreatedAt.Equal(want) || !n.UpdatedAt.Equal(want) {
+		t.Fatalf("times = %v %v", n.CreatedAt, n.UpdatedAt)
+	}
+	wantMeta := map[string]any{
+		"status":   "open",
+		"priority": float64(2),
+		"summary":  "first line\nsecond line\n",
+		"params":   map[string]any{"owner": "ann", "links": []any{"a", "b"}},
+	}
+	if !reflect.DeepEqual(n.Meta, wantMeta) {
+		t.Fatalf("Meta = %#v", n.Meta)
+	}
+}
+
+func TestSplitFrontMatterTOML(t *testing.T) {
+	content := `+++
+title = "Hugo post"
+tags = ["go", "blog"]
+date = 2025-01-02T03:04:05Z
+lastmod = 2025-01-03
+draft = true
+version = 7
++++
+Body`
+	meta, body := splitFrontMatter(content)
+	n, err := buildNoteFromMarkdown(meta, body, "post.md", nil)
+	if err != nil {
+		t.Fatalf("buildNoteFromMarkdown: %v", err)
+	}
+	if n.Title != "Hugo post" || !reflect.DeepEqual(n.Tags, []string{"go", "blog"}) || n.Text != "Body" {
+		t.Fatalf("note = %+v", n)
+	}
+	if !n.CreatedAt.Equal(time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)) || !n.UpdatedAt.Equal(time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("times = %v %v", n.CreatedAt, n.UpdatedAt)
+	}
+	// Ключи, которые не пишутся в front matter заметки, остаются в Meta.
+	if !reflect.DeepEqual(n.Meta, map[string]any{"draft": true, "version": float64(7)}) {
+		t.Fatalf("Meta = %#v", n.Meta)
+	}
+
+	// Некорректный TOML — не front matter.
+	if meta, body := splitFrontMatter("+++\ntitle = \n+++\nBody"); len(meta) != 0 || body != "+++\ntitle = \n+++\nBody" {
+		t.Fatalf("broken TOML = %v, %q", meta, body)
+	}
+}
+
+func TestSplitFrontMatterLegacy(t *testing.T) {
+	// Не YAML: значение с двоеточием без кавычек читается по-старому.
+	meta, body := splitFrontMatter("---\ntitle: Title: with colon\ntags: [a, b]\nid: 0123\n---\nBody")
+	if body != "Body" || meta.str("title") != "Title: with colon" || !reflect.DeepEqual(meta.tags(), []string{"a", "b"}) || meta.str("id") != "0123" {
+		t.Fatalf("legacy = %v, %q", meta, body)
+	}
+
+	meta, _ = splitFrontMatter("---\nid: 0123\ntitle: 2024\n---\n")
+	if meta.str("id") != "0123" || meta.str("title") != "2024" {
+		t.Fatalf("scalar id/title = %v", meta)
+	}
+}
@@ -9,6 +9,8 @@ import (
 	"io/fs"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 	"time"
 
@@ -30,6 +32,8 @@ type Report struct {
 	Created    int          `json:"created"`
 	Updated    int          `json:"updated"`
 	Skipped    int          `json:"skipped"`
+	Deleted    int          `json:"deleted"`
+	Orphaned   int          `json:"orphaned"`
 	Errors     int          `json:"errors"`
 	Results    []FileResult `json:"results,omitempty"`
 }
@@ -39,6 +43,9 @@ type sourceInfo struct {
 	Path        string `json:"path"`
 	ContentHash string `json:"content_hash"`
 	ModTimeUnix int64  `json:"mod_time_unix"`
+	// Dir — абсолютный путь каталога, из которого пришёл файл; по нему
+	// sync отличает свои записи от записей других каталогов.
+	Dir string `json:"dir,omitempty"`
 }
 
 type importIndex struct {
@@ -46,27 +53,30 @@ type importIndex struct {
 	Sources map[string]sourceInfo `json:"sources"`
 }
 
-func ImportDir(root, dir string, exts []string, dryRun bool) (*Report, error) {
+// OrphanedTag получают заметки, файлы которых пропали из каталога, при
+// импорте с Prune и TagOrphans.
+const OrphanedTag = "orphaned"
+
+type ImportOptions struct {
+	// Exts — расширения файлов; пусто — md, markdown и txt.
+	Exts   []string
+	DryRun bool
+	// Prune удаляет заметки, импортированные из каталога раньше, файлов
+	// которых в нём больше нет; с TagOrphans такие заметки вместо
+	// удаления получают тег OrphanedTag.
+	Prune, TagOrphans bool
+}
+
+func ImportDir(root, dir string, opts ImportOptions) (*Report, error) {
 	if dir == "" {
 		return nil, fmt.Errorf("пустой каталог импорта")
 	}
 
-	extSet := make(map[string]bool)
-	for _, e := range exts {
-		e = strings.TrimSpace(strings.ToLower(e))
-		if e == "" {
-			continue
-		}
-		if !strings.HasPrefix(e, ".") {
-			e = "." + e
-		}
-		extSet[e] = true
-	}
-
-	if len(extSet) == 0 {
-		extSet[".md"] = true
-		extSet[".markdown"] = true
-		extSet[".txt"] = true
+	dryRun := opts.DryRun
+	extSet := makeExtSet(opts.Exts)
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
 	}
 
 	s, err := store.Open(root)
@@ -84,6 +94,7 @@ func ImportDir(root, dir string, exts []string, dryRun bool) (*Report, error) {
 		Root:      root,
 		SourceDir: dir,
 	}
+	seen := make(map[string]bool)
 
 	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, walkErr error) error {
 		if walkErr != nil {
@@ -98,12 +109,7 @@ func ImportDir(root, dir string, exts []string, dryRun bool) (*Report, error) {
 		if d.IsDir() {
 			return nil
 		}
-		name := d.Name()
-		if strings.HasPrefix(name, ".") {
-			return nil
-		}
-		ext := strings.ToLower(filepath.Ext(name))
-		if !extSet[ext] {
+		if !wantFile(d.Name(), extSet) {
 			return nil
 		}
 
@@ -137,13 +143,30 @@ func ImportDir(root, dir string, exts []string, dryRun bool) (*Report, error) {
 		}
 
 		meta, body := splitFrontMatter(string(data))
-		note := buildNoteFromMarkdown(meta, body, rel, info)
-		sourceKey := sourceKeyFor(meta, rel)
+		note, err := buildNoteFromMarkdown(meta, body, rel, info)
+		if err != nil {
+			rep.Errors++
+			rep.Results = append(rep.Results, FileResult{
+				Path:   rel,
+				Action: "error",
+				Error:  err.Error(),
+			})
+			return nil
+		}
+		sourceKey, entry, existed := idx.lookup(absDir, meta, rel)
+		seen[sourceKey] = true
 		rep.Parsed++
 
 		contentHash := hashNoteContent(note)
 
-		entry, existed := idx.Sources[sourceKey]
+		if !existed && note.ID != "" {
+			// Файл с id заметки, которая уже есть в хранилище (например,
+			// выгруженный export), считается её источником.
+			if old, err := s.GetByID(note.ID); err == nil {
+				entry = sourceInfo{NoteID: old.ID, ContentHash: hashNoteContent(old)}
+				existed = true
+			}
+		}
 
 		if existed && entry.ContentHash == contentHash {
 
@@ -156,6 +179,7 @@ func ImportDir(root, dir string, exts []string, dryRun bool) (*Report, error) {
 			if !dryRun {
 				entry.Path = rel
 				entry.ModTimeUnix = info.ModTime().UTC().Unix()
+				entry.Dir = absDir
 				idx.Sources[sourceKey] = entry
 			}
 			return nil
@@ -183,6 +207,10 @@ func ImportDir(root, dir string, exts []string, dryRun bool) (*Report, error) {
 					note.CreatedAt = old.CreatedAt
 				}
 			}
+			if note.ID == "" {
+				// Заметку записи удалили, а id в файле нет.
+				note.ID = model.NewNote(note.Title, note.Text, note.Tags).ID
+			}
 			if note.CreatedAt.IsZero() {
 				note.CreatedAt = now
 			}
@@ -205,6 +233,7 @@ func ImportDir(root, dir string, exts []string, dryRun bool) (*Report, error) {
 					Path:        rel,
 					ContentHash: contentHash,
 					ModTimeUnix: info.ModTime().UTC().Unix(),
+					Dir:         absDir,
 				}
 			}
 
@@ -242,6 +271,7 @@ func ImportDir(root, dir string, exts []string, dryRun bool) (*Report, error) {
 				Path:        rel,
 				ContentHash: contentHash,
 				ModTimeUnix: info.ModTime().UTC().Unix(),
+				Dir:         absDir,
 			}
 		}
 
@@ -257,6 +287,10 @@ func ImportDir(root, dir string, exts []string, dryRun bool) (*Report, error) {
 		return nil, err
 	}
 
+	if opts.Prune {
+		pruneSources(s, idx, absDir, seen, opts, rep)
+	}
+
 	if !dryRun {
 		if err := saveIndex(root, idx); err != nil {
 			return nil, err
@@ -266,6 +300,107 @@ func ImportDir(root, dir string, exts []string, dryRun bool) (*Report, error) {
 	return rep, nil
 }
 
+// pruneSources обрабатывает записи каталога dir, которые не встретились
+// при импорте и файлов которых нет на месте: заметка удаляется или
+// помечается OrphanedTag, а запись убирается из индекса. Записи старого
+// формата без Dir не трогаются: lookup переносит в dir только те, чей
+// файл нашёлся, а про остальные неизвестно, из какого они каталога.
+func pruneSources(s *store.Store, idx *importIndex, dir string, seen map[string]bool, opts ImportOptions, rep *Report) {
+	var keys []string
+	for key, e := range idx.Sources {
+		if e.Dir == dir && !seen[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		entry := idx.Sources[key]
+		if _, err := os.Stat(filepath.Join(dir, entry.Path)); err == nil {
+			// Файл на месте, но не прошёл отбор по расширению.
+			continue
+		}
+
+		note, err := s.GetByID(entry.NoteID)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			rep.Errors++
+			rep.Results = append(rep.Results, FileResult{Path: entry.Path, Action: "error", Error: err.Error()})
+			continue
+		}
+
+		action := ""
+		switch {
+		case note == nil:
+		case opts.TagOrphans:
+			action = "orphaned"
+			if !opts.DryRun {
+				_, err = s.Patch(note.ID, func(n *model.Note) error {
+					if !slices.Contains(n.Tags, OrphanedTag) {
+						n.Tags = append(n.Tags, OrphanedTag)
+					}
+					return nil
+				})
+			}
+		default:
+			action = "deleted"
+			if !opts.DryRun {
+				tomb := *note
+				tomb.UpdatedAt = time.Now().UTC()
+				tomb.Deleted = true
+				err = s.Append(&tomb)
+			}
+		}
+		if err != nil {
+			rep.Errors++
+			rep.Results = append(rep.Results, FileResult{Path: entry.Path, Action: "error", Error: err.Error()})
+			continue
+		}
+
+		switch action {
+		case "orphaned":
+			rep.Orphaned++
+		case "deleted":
+			rep.Deleted++
+		}
+		if action != "" {
+			rep.Results = append(rep.Results, FileResult{Path: entry.Path, Action: action})
+		}
+		if !opts.DryRun {
+			delete(idx.Sources, key)
+		}
+	}
+}
+
+func makeExtSet(exts []string) map[string]bool {
+	extSet := make(map[string]bool)
+	for _, e := range exts {
+		e = strings.TrimSpace(strings.ToLower(e))
+		if e == "" {
+			continue
+		}
+		if !strings.HasPrefix(e, ".") {
+			e = "." + e
+		}
+		extSet[e] = true
+	}
+
+	if len(extSet) == 0 {
+		extSet[".md"] = true
+		extSet[".markdown"] = true
+		extSet[".txt"] = true
+	}
+	return extSet
+}
+
+// wantFile отбирает файлы для импорта: по расширению, без скрытых файлов
+// и без копий конфликтов, которые пишет sync.
+func wantFile(name string, extSet map[string]bool) bool {
+	if strings.HasPrefix(name, ".") || isConflictCopy(name) {
+		return false
+	}
+	return extSet[strings.ToLower(filepath.Ext(name))]
+}
+
 func loadIndex(root string) (*importIndex, error) {
 	path := filepath.Join(root, "imports.json")
 	b, err := os.ReadFile(path)
@@ -301,71 +436,74 @@ func saveIndex(root string, idx *importIndex) error {
 	return os.WriteFile(path, b, 0o644)
 }
 
-func splitFrontMatter(content string) (map[string]string, string) {
-	lines := strings.Split(content, "\n")
-	if len(lines) == 0 {
-		return map[string]string{}, ""
-	}
-
-	if strings.TrimSpace(lines[0]) != "---" {
-
-		return map[string]string{}, content
-	}
-
-	end := -1
-	for i := 1; i < len(lines); i++ {
-		if strings.TrimSpace(lines[i]) == "---" {
-			end = i
-			break
+// CheckIndex ищет в imports.json записи, указывающие на несуществующие
+// заметки; при repair такие записи удаляются.
+func CheckIndex(root string, exists func(id string) bool, repair bool) ([]store.FsckIssue, error) {
+	const loc = "imports.json"
+	b, err := os.ReadFile(filepath.Join(root, loc))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil, nil
 		}
+		return nil, err
 	}
-	if end == -1 {
 
-		return map[string]string{}, content
+	var issues []store.FsckIssue
+	var idx importIndex
+	if err := json.Unmarshal(b, &idx); err != nil {
+		issues = append(issues, store.FsckIssue{
+			Kind:     "import_index",
+			Location: loc,
+			Detail:   err.Error(),
+			Repaired: repair,
+		})
+		if repair {
+			return issues, saveIndex(root, &importIndex{Version: 1, Sources: make(map[string]sourceInfo)})
+		}
+		return issues, nil
 	}
 
-	metaLines := lines[1:end]
-	bodyLines := []string{}
-	if end+1 < len(lines) {
-		bodyLines = lines[end+1:]
+	keys := make([]string, 0, len(idx.Sources))
+	for k := range idx.Sources {
+		keys = append(keys, k)
 	}
-	body := strings.Join(bodyLines, "\n")
+	sort.Strings(keys)
 
-	meta := make(map[string]string)
-	for _, line := range metaLines {
-		line = strings.TrimSpace(line)
-		if line == "" || strings.HasPrefix(line, "#") {
+	for _, k := range keys {
+		src := idx.Sources[k]
+		if exists(src.NoteID) {
 			continue
 		}
-		parts := strings.SplitN(line, ":", 2)
-		if len(parts) != 2 {
-			continue
+		issues = append(issues, store.FsckIssue{
+			Kind:     "import_stale",
+			Location: loc + "#" + k,
+			Detail:   fmt.Sprintf("note %s does not exist", src.NoteID),
+			Repaired: repair,
+		})
+		if repair {
+			delete(idx.Sources, k)
 		}
-		key := strings.ToLower(strings.TrimSpace(parts[0]))
-		value := strings.TrimSpace(parts[1])
-		meta[key] = value
 	}
 
-	return meta, body
-}
-
-func buildNoteFromMarkdown(meta map[string]string, body, relpath string, info fs.FileInfo) *model.Note {
-	title := strings.TrimSpace(meta["title"])
-	tags := parseTags(meta["tags"])
-
-	var created time.Time
-	if v := strings.TrimSpace(meta["created"]); v != "" {
-		if t, err := parseTimeFlexible(v); err == nil {
-			created = t.UTC()
+	if repair && len(issues) > 0 {
+		if err := saveIndex(root, &idx); err != nil {
+			return nil, err
 		}
 	}
-	var updated time.Time
-	if v := strings.TrimSpace(meta["updated"]); v != "" {
-		if t, err := parseTimeFlexible(v); err == nil {
-			updated = t.UTC()
-		}
+	return issues, nil
+}
+
+// buildNoteFromMarkdown собирает заметку из front matter и тела; ключи,
+// которые не стали полями заметки, попадают в Meta.
+func buildNoteFromMarkdown(meta frontMatter, body, relpath string, info fs.FileInfo) (*model.Note, error) {
+	extra, err := model.NormalizeMeta(meta.extra())
+	if err != nil {
+		return nil, err
 	}
 
+	created, _ := meta.created()
+	updated, _ := meta.updated()
+
 	if created.IsZero() && info != nil {
 		created = info.ModTime().UTC()
 	}
@@ -377,25 +515,61 @@ func buildNoteFromMarkdown(meta map[string]string, body, relpath string, info fs
 	}
 
 	return &model.Note{
-
-		Title:     title,
+		ID:        meta.str("id"),
+		Title:     meta.str("title"),
 		Text:      body,
-		Tags:      tags,
+		Tags:      meta.tags(),
 		CreatedAt: created,
 		UpdatedAt: updated,
 		Deleted:   false,
-	}
+		Meta:      extra,
+	}, nil
+}
+
+// sourceKeyFor — ключ записи imports.json: каталог источника и id из
+// front matter или путь файла в каталоге. Каталог входит в ключ, чтобы
+// одинаковые пути и id в разных каталогах не делили одну запись.
+func sourceKeyFor(dir string, meta frontMatter, relpath string) string {
+	return filepath.ToSlash(dir) + "|" + legacySourceKey(meta, relpath)
 }
 
-func sourceKeyFor(meta map[string]string, relpath string) string {
-	if id := strings.TrimSpace(meta["id"]); id != "" {
+// legacySourceKey — ключ записей, сделанных до того, как в него вошёл
+// каталог.
+func legacySourceKey(meta frontMatter, relpath string) string {
+	if id := meta.str("id"); id != "" {
 		return "id:" + id
 	}
 	return "path:" + filepath.ToSlash(relpath)
 }
 
+// lookup ищет запись файла relpath каталога dir. Запись старого формата
+// без каталога или с тем же Dir переносится на новый ключ; записи
+// других каталогов не возвращаются и не меняются.
+func (idx *importIndex) lookup(dir string, meta frontMatter, relpath string) (string, sourceInfo, bool) {
+	key := sourceKeyFor(dir, meta, relpath)
+	if e, ok := idx.Sources[key]; ok {
+		return key, e, true
+	}
+	legacy := legacySourceKey(meta, relpath)
+	e, ok := idx.Sources[legacy]
+	if !ok || (e.Dir != "" && e.Dir != dir) {
+		return key, sourceInfo{}, false
+	}
+	delete(idx.Sources, legacy)
+	e.Dir = dir
+	idx.Sources[key] = e
+	return key, e, true
+}
+
+// hashNoteContent — хэш содержимого заметки. Meta входит в него, только
+// если не пуста, чтобы хэши заметок без метаданных не менялись.
 func hashNoteContent(n *model.Note) string {
-	h := sha1.Sum([]byte(n.Title + "\n" + strings.Join(n.Tags, ",") + "\n" + n.Text))
+	content := n.Title + "\n" + strings.Join(n.Tags, ",") + "\n" + n.Text
+	if len(n.Meta) > 0 {
+		b, _ := json.Marshal(n.Meta)
+		content += "\n" + string(b)
+	}
+	h := sha1.Sum([]byte(content))
 	return hex.EncodeToString(h[:])
 }
 
@@ -420,14 +594,10 @@ func parseTags(raw string) []string {
 	return tags
 }
 
-func parseTimeFlexible(s string) (time.Time, error) {
-	layouts := []string{
-		time.RFC3339,
-		"2006-01-02",
-		"2006-01-02 15:04",
-		"2006-01-02 15:04:05",
-	}
-	for _, l := range layouts {
+// ParseTimeFlexible разбирает абсолютное время в одном из
+// store.TimeLayouts; время без зоны считается UTC.
+func ParseTimeFlexible(s string) (time.Time, error) {
+	for _, l := range store.TimeLayouts {
 		if t, err := time.Parse(l, s); err == nil {
 			return t, nil
 		}
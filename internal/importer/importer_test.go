@@ -1,12 +1,15 @@
 package importer
 
 import (
+	"errors"
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
 	"time"
 
 	"github.com/Victor3563/NoteLine/cli-notebook/internal/model"
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/store"
 )
 
 func TestSplitFrontMatterNone(t *testing.T) {
@@ -33,13 +36,13 @@ text
 	meta, body := splitFrontMatter(content)
 
 	if meta["title"] != "My note" {
-		t.Fatalf("title=%q, want %q", meta["title"], "My note")
+		t.Fatalf("title=%v, want %q", meta["title"], "My note")
 	}
 	if meta["tags"] != "go, cli" {
-		t.Fatalf("tags=%q, want %q", meta["tags"], "go, cli")
+		t.Fatalf("tags=%v, want %q", meta["tags"], "go, cli")
 	}
-	if meta["created"] != "2025-01-02" {
-		t.Fatalf("created=%q, want %q", meta["created"], "2025-01-02")
+	if want := time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC); meta["created"] != want {
+		t.Fatalf("created=%v, want %v", meta["created"], want)
 	}
 	wantBody := "Body\ntext\n"
 	if body != wantBody {
@@ -82,12 +85,12 @@ func TestParseTimeFlexible(t *testing.T) {
 
 	for _, l := range layouts {
 		s := now.Format(l)
-		got, err := parseTimeFlexible(s)
+		got, err := ParseTimeFlexible(s)
 		if err != nil {
-			t.Fatalf("parseTimeFlexible(%q) error: %v", s, err)
+			t.Fatalf("ParseTimeFlexible(%q) error: %v", s, err)
 		}
 		if got.IsZero() {
-			t.Fatalf("parseTimeFlexible(%q) returned zero time", s)
+			t.Fatalf("ParseTimeFlexible(%q) returned zero time", s)
 		}
 	}
 }
@@ -110,14 +113,32 @@ func TestHashNoteContentChanges(t *testing.T) {
 }
 
 func TestSourceKeyFor(t *testing.T) {
-	meta := map[string]string{"id": "my-id"}
-	if got := sourceKeyFor(meta, "path/to/file.md"); got != "id:my-id" {
-		t.Fatalf("sourceKeyFor with id = %q, want %q", got, "id:my-id")
+	meta := frontMatter{"id": "my-id"}
+	if got := sourceKeyFor("/notes", meta, "path/to/file.md"); got != "/notes|id:my-id" {
+		t.Fatalf("sourceKeyFor with id = %q, want %q", got, "/notes|id:my-id")
 	}
 
-	meta2 := map[string]string{}
-	if got := sourceKeyFor(meta2, "path/to/file.md"); got != "path:path/to/file.md" {
-		t.Fatalf("sourceKeyFor path = %q, want %q", got, "path:path/to/file.md")
+	meta2 := frontMatter{}
+	if got := sourceKeyFor("/notes", meta2, "path/to/file.md"); got != "/notes|path:path/to/file.md" {
+		t.Fatalf("sourceKeyFor path = %q, want %q", got, "/notes|path:path/to/file.md")
+	}
+}
+
+func TestIndexLookupLegacy(t *testing.T) {
+	idx := &importIndex{Sources: map[string]sourceInfo{
+		"path:a.md": {NoteID: "a", Path: "a.md"},
+		"path:b.md": {NoteID: "b", Path: "b.md", Dir: "/other"},
+	}}
+	key, e, ok := idx.lookup("/notes", frontMatter{}, "a.md")
+	if !ok || e.NoteID != "a" || key != "/notes|path:a.md" || idx.Sources[key].Dir != "/notes" {
+		t.Fatalf("lookup(legacy) = %q, %+v, %v", key, e, ok)
+	}
+	if _, ok := idx.Sources["path:a.md"]; ok {
+		t.Fatalf("legacy key not migrated: %v", idx.Sources)
+	}
+	// Запись другого каталога не берётся и не переносится.
+	if _, _, ok := idx.lookup("/notes", frontMatter{}, "b.md"); ok || idx.Sources["path:b.md"].NoteID != "b" {
+		t.Fatalf("lookup took entry of another dir: %v", idx.Sources)
 	}
 }
 
@@ -133,17 +154,21 @@ func (f fakeInfo) IsDir() bool        { return false }
 func (f fakeInfo) Sys() any           { return nil }
 
 func TestBuildNoteFromMarkdown(t *testing.T) {
-	meta := map[string]string{
+	meta := frontMatter{
 		"title":   "Title",
 		"tags":    "a, b",
 		"created": "2025-01-02",
 		"updated": "2025-01-03T10:00:00Z",
+		"status":  "open",
 	}
 	body := "Body"
 	mod := time.Date(2025, 1, 4, 0, 0, 0, 0, time.UTC)
 	info := fakeInfo{mod: mod}
 
-	n := buildNoteFromMarkdown(meta, body, "note.md", info)
+	n, err := buildNoteFromMarkdown(meta, body, "note.md", info)
+	if err != nil {
+		t.Fatalf("buildNoteFromMarkdown: %v", err)
+	}
 
 	if n.Title != "Title" {
 		t.Fatalf("Title=%q, want %q", n.Title, "Title")
@@ -157,6 +182,9 @@ func TestBuildNoteFromMarkdown(t *testing.T) {
 	if n.CreatedAt.IsZero() || n.UpdatedAt.IsZero() {
 		t.Fatalf("CreatedAt/UpdatedAt should not be zero")
 	}
+	if len(n.Meta) != 1 || n.Meta["status"] != "open" {
+		t.Fatalf("Meta=%v, want map[status:open]", n.Meta)
+	}
 }
 
 func TestImportDirCreatesAndSkips(t *testing.T) {
@@ -177,7 +205,7 @@ Body
 		t.Fatalf("WriteFile: %v", err)
 	}
 
-	rep, err := ImportDir(root, src, []string{".md"}, false)
+	rep, err := ImportDir(root, src, ImportOptions{Exts: []string{".md"}})
 	if err != nil {
 		t.Fatalf("ImportDir: %v", err)
 	}
@@ -185,7 +213,7 @@ Body
 		t.Fatalf("first import: Created=%d TotalFiles=%d, want 1/1", rep.Created, rep.TotalFiles)
 	}
 
-	rep2, err := ImportDir(root, src, []string{".md"}, false)
+	rep2, err := ImportDir(root, src, ImportOptions{Exts: []string{".md"}})
 	if err != nil {
 		t.Fatalf("ImportDir second: %v", err)
 	}
@@ -193,3 +221,220 @@ Body
 		t.Fatalf("second import: Skipped=%d, want 1", rep2.Skipped)
 	}
 }
+
+func TestCheckIndexPrunesStaleEntries(t *testing.T) {
+	root := t.TempDir()
+	idx := &importIndex{
+		Version: 1,
+		Sources: map[string]sourceInfo{
+			"path:live.md": {NoteID: "live", Path: "live.md"},
+			"path:gone.md": {NoteID: "gone", Path: "gone.md"},
+		},
+	}
+	if err := saveIndex(root, idx); err != nil {
+		t.Fatalf("saveIndex: %v", err)
+	}
+	exists := func(id string) bool { return id == "live" }
+
+	issues, err := CheckIndex(root, exists, false)
+	if err != nil {
+		t.Fatalf("CheckIndex: %v", err)
+	}
+	if len(issues) != 1 || issues[0].Kind != "import_stale" || issues[0].Repaired {
+		t.Fatalf("CheckIndex issues = %+v, want one unrepaired import_stale", issues)
+	}
+
+	if _, err := CheckIndex(root, exists, true); err != nil {
+		t.Fatalf("CheckIndex repair: %v", err)
+	}
+	got, err := loadIndex(root)
+	if err != nil {
+		t.Fatalf("loadIndex: %v", err)
+	}
+	if _, ok := got.Sources["path:gone.md"]; ok || len(got.Sources) != 1 {
+		t.Fatalf("stale entry not pruned: %+v", got.Sources)
+	}
+}
+
+func TestImportDirPrune(t *testing.T) {
+	for _, tagOrphans := range []bool{false, true} {
+		root := t.TempDir()
+		src := t.TempDir()
+		for _, name := range []string{"keep.md", "gone.md"} {
+			if err := os.WriteFile(filepath.Join(src, name), []byte("---\ntitle: "+name+"\n---\nbody"), 0o644); err != nil {
+				t.Fatalf("WriteFile: %v", err)
+			}
+		}
+		if _, err := ImportDir(root, src, ImportOptions{}); err != nil {
+			t.Fatalf("ImportDir: %v", err)
+		}
+		idx, _ := loadIndex(root)
+		goneID := idx.Sources[sourceKeyFor(src, frontMatter{}, "gone.md")].NoteID
+		if err := os.Remove(filepath.Join(src, "gone.md")); err != nil {
+			t.Fatalf("Remove: %v", err)
+		}
+
+		// Без --prune и в dry-run заметка остаётся.
+		opts := ImportOptions{Prune: true, TagOrphans: tagOrphans, DryRun: true}
+		for _, o := range []ImportOptions{{}, opts} {
+			rep, err := ImportDir(root, src, o)
+			if err != nil {
+				t.Fatalf("ImportDir(%+v): %v", o, err)
+			}
+			if want := boolToInt(o.Prune); rep.Deleted+rep.Orphaned != want {
+				t.Fatalf("ImportDir(%+v) = %+v", o, rep)
+			}
+		}
+
+		opts.DryRun = false
+		rep, err := ImportDir(root, src, opts)
+		if err != nil || rep.Skipped != 1 || rep.Deleted != boolToInt(!tagOrphans) || rep.Orphaned != boolToInt(tagOrphans) {
+			t.Fatalf("ImportDir(prune) = %+v, %v", rep, err)
+		}
+		if idx, _ := loadIndex(root); len(idx.Sources) != 1 {
+			t.Fatalf("index after prune = %v", idx.Sources)
+		}
+
+		s, err := store.OpenWith(root, store.Options{Lock: store.LockShared})
+		if err != nil {
+			t.Fatalf("Open: %v", err)
+		}
+		n, err := s.GetByID(goneID)
+		s.Close()
+		if tagOrphans {
+			if err != nil || !slices.Contains(n.Tags, OrphanedTag) {
+				t.Fatalf("orphaned note = %+v, %v", n, err)
+			}
+		} else if !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("pruned note err = %v", err)
+		}
+	}
+}
+
+func TestImportDirPruneLegacyEntry(t *testing.T) {
+	root := t.TempDir()
+	dirA, dirB := t.TempDir(), t.TempDir()
+	if err := os.WriteFile(filepath.Join(dirA, "a.md"), []byte("---\ntitle: a\n---\nbody a"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dirB, "b.md"), []byte("---\ntitle: b\n---\nbody b"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	// Записи imports.json без Dir, как до появления sync: a.md
+	// импортирован из A, b.md — из B.
+	a := model.NewNote("a", "body a", nil)
+	b := model.NewNote("b", "body b", nil)
+	s, err := store.Open(root)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	for _, n := range []*model.Note{a, b} {
+		if err := s.Append(n); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+	s.Close()
+	legacy := &importIndex{Version: 1, Sources: map[string]sourceInfo{
+		"path:a.md": {NoteID: a.ID, Path: "a.md", ContentHash: hashNoteContent(a)},
+		"path:b.md": {NoteID: b.ID, Path: "b.md", ContentHash: hashNoteContent(b)},
+	}}
+	if err := saveIndex(root, legacy); err != nil {
+		t.Fatalf("saveIndex: %v", err)
+	}
+
+	// Запись b.md не принадлежит A и не должна удаляться при prune A.
+	rep, err := ImportDir(root, dirA, ImportOptions{Prune: true})
+	if err != nil || rep.Deleted != 0 || rep.Orphaned != 0 {
+		t.Fatalf("ImportDir(A, prune) = %+v, %v", rep, err)
+	}
+	idx, _ := loadIndex(root)
+	if _, ok := idx.Sources["path:b.md"]; !ok {
+		t.Fatalf("legacy entry of B was dropped: %v", idx.Sources)
+	}
+	if e, ok := idx.Sources[sourceKeyFor(dirA, frontMatter{}, "a.md")]; !ok || e.NoteID != a.ID {
+		t.Fatalf("legacy entry of A was not migrated: %v", idx.Sources)
+	}
+
+	// Перенесённая запись A теперь удаляется, когда пропадает файл.
+	if err := os.Remove(filepath.Join(dirA, "a.md")); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	rep, err = ImportDir(root, dirA, ImportOptions{Prune: true})
+	if err != nil || rep.Deleted != 1 {
+		t.Fatalf("ImportDir(A, prune) after removal = %+v, %v", rep, err)
+	}
+
+	s, err = store.OpenWith(root, store.Options{Lock: store.LockShared})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer s.Close()
+	if _, err := s.GetByID(a.ID); !errors.Is(err, store.ErrNotFound) {
+		t.Fatalf("pruned note of A err = %v", err)
+	}
+	if _, err := s.GetByID(b.ID); err != nil {
+		t.Fatalf("note of B after prune A: %v", err)
+	}
+}
+
+func TestImportDirRecreatesDeletedNote(t *testing.T) {
+	root := t.TempDir()
+	src := t.TempDir()
+	path := filepath.Join(src, "note.md")
+	if err := os.WriteFile(path, []byte("---\ntitle: note\n---\nbody"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	if _, err := ImportDir(root, src, ImportOptions{}); err != nil {
+		t.Fatalf("ImportDir: %v", err)
+	}
+	idx, _ := loadIndex(root)
+	oldID := idx.Sources[sourceKeyFor(src, frontMatter{}, "note.md")].NoteID
+
+	s, err := store.Open(root)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	old, err := s.GetByID(oldID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	old.Deleted = true
+	if err := s.Append(old); err != nil {
+		t.Fatalf("Append tombstone: %v", err)
+	}
+	s.Close()
+
+	// Файл без id изменился, а заметку его записи уже удалили.
+	if err := os.WriteFile(path, []byte("---\ntitle: note\n---\nnew body"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	rep, err := ImportDir(root, src, ImportOptions{})
+	if err != nil || rep.Errors != 0 || rep.Updated != 1 {
+		t.Fatalf("ImportDir after delete = %+v, %v", rep, err)
+	}
+	idx, _ = loadIndex(root)
+	newID := idx.Sources[sourceKeyFor(src, frontMatter{}, "note.md")].NoteID
+	if newID == "" || newID == oldID {
+		t.Fatalf("note id after re-import = %q (old %q)", newID, oldID)
+	}
+
+	s, err = store.OpenWith(root, store.Options{Lock: store.LockShared})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer s.Close()
+	if n, err := s.GetByID(newID); err != nil || n.Text != "new body" {
+		t.Fatalf("re-imported note = %+v, %v", n, err)
+	}
+	if frep, err := s.Fsck(false); err != nil || len(frep.Issues) != 0 {
+		t.Fatalf("Fsck after re-import = %+v, %v", frep, err)
+	}
+}
+
+func boolToInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
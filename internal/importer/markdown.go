@@ -0,0 +1,81 @@
+package importer
+
+import (
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/model"
+)
+
+// FormatMarkdown записывает заметку в markdown с YAML front matter в том
+// же формате, который читает импорт: title, tags, для сохранённых
+// заметок ещё id и времена с наносекундами, затем поля Meta по
+// алфавиту. Текст пишется как есть, так что импорт файла даёт ту же
+// заметку.
+func FormatMarkdown(n *model.Note) string {
+	doc := &yaml.Node{Kind: yaml.MappingNode}
+	add := func(key string, v any) *yaml.Node {
+		k := &yaml.Node{}
+		k.SetString(key)
+		val := &yaml.Node{}
+		_ = val.Encode(v)
+		doc.Content = append(doc.Content, k, val)
+		return val
+	}
+
+	add("title", n.Title)
+	tags := n.Tags
+	if tags == nil {
+		tags = []string{}
+	}
+	add("tags", tags).Style = yaml.FlowStyle
+	if n.ID != "" {
+		add("id", n.ID)
+	}
+	if !n.CreatedAt.IsZero() {
+		add("created", n.CreatedAt.UTC())
+	}
+	if !n.UpdatedAt.IsZero() {
+		add("updated", n.UpdatedAt.UTC())
+	}
+	keys := make([]string, 0, len(n.Meta))
+	for k := range n.Meta {
+		if !model.ReservedMetaKey(k) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		add(k, n.Meta[k])
+	}
+
+	var b strings.Builder
+	b.WriteString("---\n")
+	enc := yaml.NewEncoder(&b)
+	enc.SetIndent(2)
+	_ = enc.Encode(doc)
+	_ = enc.Close()
+	b.WriteString("---\n")
+	b.WriteString(n.Text)
+	return b.String()
+}
+
+// ParseMarkdown разбирает markdown с front matter в заметку: заголовок,
+// теги и Meta из блока, текст — остаток без пустых строк по краям.
+// Переводы строк CRLF приводятся к LF.
+func ParseMarkdown(content string) (*model.Note, error) {
+	content = strings.ReplaceAll(content, "\r\n", "\n")
+	meta, body := splitFrontMatter(content)
+	extra, err := model.NormalizeMeta(meta.extra())
+	if err != nil {
+		return nil, err
+	}
+	return &model.Note{
+		Title: meta.str("title"),
+		Tags:  meta.tags(),
+		Text:  strings.TrimSpace(body),
+		Meta:  extra,
+	}, nil
+}
@@ -0,0 +1,37 @@
+package importer
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/model"
+)
+
+func TestFormatParseMarkdownRoundTrip(t *testing.T) {
+	n := model.NewNote("Title: with colon", "line 1\n\nline 2", []string{"go", "project/noteline"})
+	n.CreatedAt = time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	n.Meta = map[string]any{"status": "open", "priority": float64(2), "links": []any{"a", "b"}}
+
+	md := FormatMarkdown(n)
+	if !strings.HasPrefix(md, "---\ntitle: 'Title: with colon'\ntags: [go, project/noteline]\nid: "+n.ID+"\ncreated: 2025-01-02T03:04:05Z\n") {
+		t.Fatalf("FormatMarkdown =\n%s", md)
+	}
+	if !strings.Contains(md, "\nlinks:\n  - a\n  - b\npriority: 2\nstatus: open\n---\n") {
+		t.Fatalf("FormatMarkdown meta =\n%s", md)
+	}
+
+	got, err := ParseMarkdown(strings.ReplaceAll(md, "\n", "\r\n"))
+	if err != nil {
+		t.Fatalf("ParseMarkdown: %v", err)
+	}
+	if got.Title != n.Title || !reflect.DeepEqual(got.Tags, n.Tags) || got.Text != n.Text || !reflect.DeepEqual(got.Meta, n.Meta) {
+		t.Fatalf("ParseMarkdown = %+v", got)
+	}
+
+	got, err = ParseMarkdown(FormatMarkdown(&model.Note{}))
+	if err != nil || got.Title != "" || got.Tags != nil || got.Text != "" || got.Meta != nil {
+		t.Fatalf("ParseMarkdown(empty) = %+v, %v", got, err)
+	}
+}
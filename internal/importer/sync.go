@@ -0,0 +1,391 @@
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/model"
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/store"
+)
+
+// conflictMark отмечает копии файлов, которые sync сохраняет при
+// конфликте; импорт и sync такие файлы не читают.
+const conflictMark = ".conflict-"
+
+type SyncReport struct {
+	Root         string       `json:"root"`
+	Dir          string       `json:"dir"`
+	Imported     int          `json:"imported"`
+	Exported     int          `json:"exported"`
+	DeletedNotes int          `json:"deleted_notes"`
+	DeletedFiles int          `json:"deleted_files"`
+	Conflicts    int          `json:"conflicts"`
+	Unchanged    int          `json:"unchanged"`
+	Errors       int          `json:"errors"`
+	Results      []FileResult `json:"results,omitempty"`
+}
+
+func (r *SyncReport) add(path, action string) {
+	r.Results = append(r.Results, FileResult{Path: path, Action: action})
+}
+
+func (r *SyncReport) fail(path string, err error) {
+	r.Errors++
+	r.Results = append(r.Results, FileResult{Path: path, Action: "error", Error: err.Error()})
+}
+
+// syncFile — файл каталога, разобранный в заметку.
+type syncFile struct {
+	rel  string
+	key  string // ключ записи индекса, заполняет syncFile
+	meta frontMatter
+	note *model.Note
+	hash string
+	data []byte
+	info fs.FileInfo
+}
+
+// syncer держит состояние одного запуска SyncDir.
+type syncer struct {
+	s      *store.Store
+	idx    *importIndex
+	dir    string
+	dryRun bool
+	now    time.Time
+	rep    *SyncReport
+}
+
+// SyncDir синхронизирует хранилище с каталогом markdown-файлов в обе
+// стороны. Состояние последней синхронизации — записи imports.json с
+// Dir, равным каталогу: ContentHash в них — общее содержимое файла и
+// заметки на тот момент. Сравнение с ним показывает, какая сторона
+// изменилась:
+//   - изменился файл — он импортируется;
+//   - изменилась заметка — она записывается в файл;
+//   - изменились обе по-разному — конфликт: файл сохраняется копией
+//     *.conflict-<время>.md, а на его место пишется заметка;
+//   - удалён файл неизменённой заметки — заметка удаляется, удалена
+//     заметка неизменённого файла — удаляется файл;
+//   - заметки, которых нет в каталоге, выгружаются в новые файлы, кроме
+//     заметок из других каталогов импорта и sync.
+func SyncDir(root, dir string, exts []string, dryRun bool) (*SyncReport, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("пустой каталог синхронизации")
+	}
+	absDir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	s, err := store.Open(root)
+	if err != nil {
+		return nil, err
+	}
+	defer s.Close()
+
+	idx, err := loadIndex(root)
+	if err != nil {
+		return nil, err
+	}
+
+	y := &syncer{
+		s:      s,
+		idx:    idx,
+		dir:    absDir,
+		dryRun: dryRun,
+		now:    time.Now().UTC(),
+		rep:    &SyncReport{Root: root, Dir: dir},
+	}
+
+	files, err := y.scan(makeExtSet(exts))
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	for _, f := range files {
+		y.syncFile(f)
+		seen[f.key] = true
+	}
+
+	var keys []string
+	for key, e := range idx.Sources {
+		if e.Dir == absDir && !seen[key] {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		y.fileGone(key)
+	}
+
+	if err := y.exportNew(); err != nil {
+		return nil, err
+	}
+
+	if !dryRun {
+		if err := saveIndex(root, idx); err != nil {
+			return nil, err
+		}
+	}
+	return y.rep, nil
+}
+
+func (y *syncer) scan(extSet map[string]bool) ([]*syncFile, error) {
+	var files []*syncFile
+	err := filepath.WalkDir(y.dir, func(path string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			y.rep.fail(path, walkErr)
+			return nil
+		}
+		if d.IsDir() || !wantFile(d.Name(), extSet) {
+			return nil
+		}
+		rel, err := filepath.Rel(y.dir, path)
+		if err != nil {
+			rel = path
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			y.rep.fail(rel, err)
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			y.rep.fail(rel, err)
+			return nil
+		}
+		meta, body := splitFrontMatter(string(data))
+		note, err := buildNoteFromMarkdown(meta, body, rel, info)
+		if err != nil {
+			y.rep.fail(rel, err)
+			return nil
+		}
+		files = append(files, &syncFile{
+			rel:  rel,
+			meta: meta,
+			note: note,
+			hash: hashNoteContent(note),
+			data: data,
+			info: info,
+		})
+		return nil
+	})
+	return files, err
+}
+
+// syncFile сверяет файл каталога с его заметкой.
+func (y *syncer) syncFile(f *syncFile) {
+	var entry sourceInfo
+	var known bool
+	f.key, entry, known = y.idx.lookup(y.dir, f.meta, f.rel)
+
+	var note *model.Note
+	if known {
+		n, err := y.s.GetByID(entry.NoteID)
+		if err != nil && !errors.Is(err, store.ErrNotFound) {
+			y.rep.fail(f.rel, err)
+			return
+		}
+		note = n
+	} else if f.note.ID != "" {
+		// Файл с id заметки из хранилища, которого sync ещё не видел:
+		// считаем, что раньше они совпадали.
+		if n, err := y.s.GetByID(f.note.ID); err == nil {
+			note = n
+			entry = sourceInfo{NoteID: n.ID, ContentHash: hashNoteContent(n)}
+			known = true
+		}
+	}
+
+	if known && f.note.ID == "" {
+		f.note.ID = entry.NoteID
+	}
+
+	fileChanged := !known || f.hash != entry.ContentHash
+	noteChanged := known && note != nil && hashNoteContent(note) != entry.ContentHash
+
+	switch {
+	case known && note == nil && !fileChanged:
+		// Заметку удалили в хранилище.
+		if !y.dryRun {
+			if err := os.Remove(filepath.Join(y.dir, f.rel)); err != nil {
+				y.rep.fail(f.rel, err)
+				return
+			}
+		}
+		delete(y.idx.Sources, f.key)
+		y.rep.DeletedFiles++
+		y.rep.add(f.rel, "deleted-file")
+	case !fileChanged && !noteChanged,
+		fileChanged && noteChanged && f.hash == hashNoteContent(note):
+		y.record(f.key, note.ID, f.rel, f.hash, f.info.ModTime())
+		y.rep.Unchanged++
+	case fileChanged && noteChanged:
+		copyRel := conflictPath(f.rel, y.now)
+		if !y.dryRun {
+			if err := os.WriteFile(filepath.Join(y.dir, copyRel), f.data, 0o644); err != nil {
+				y.rep.fail(f.rel, err)
+				return
+			}
+		}
+		if y.writeNote(f.key, note, f.rel) {
+			y.rep.Conflicts++
+			y.rep.add(copyRel, "conflict")
+		}
+	case fileChanged:
+		y.importFile(f, note)
+	default:
+		if y.writeNote(f.key, note, f.rel) {
+			y.rep.Exported++
+			y.rep.add(f.rel, "exported")
+		}
+	}
+}
+
+// importFile записывает изменённый файл в хранилище новой версией
+// заметки old или новой заметкой, если old нет.
+func (y *syncer) importFile(f *syncFile, old *model.Note) {
+	n := f.note
+	if old != nil {
+		n.ID = old.ID
+		if _, ok := f.meta.created(); !ok {
+			n.CreatedAt = old.CreatedAt
+		}
+	}
+	if n.ID == "" {
+		n.ID = model.NewNote(n.Title, n.Text, n.Tags).ID
+	}
+	// Правка тела файла не трогает поле updated, поэтому берём время
+	// изменения файла, если оно позже.
+	if mod := f.info.ModTime().UTC(); mod.After(n.UpdatedAt) {
+		n.UpdatedAt = mod
+	}
+	if !y.dryRun {
+		if err := y.s.Append(n); err != nil {
+			y.rep.fail(f.rel, err)
+			return
+		}
+	}
+	y.record(f.key, n.ID, f.rel, f.hash, f.info.ModTime())
+	y.rep.Imported++
+	y.rep.add(f.rel, "imported")
+}
+
+// fileGone обрабатывает запись, файл которой пропал из каталога.
+func (y *syncer) fileGone(key string) {
+	entry := y.idx.Sources[key]
+	if _, err := os.Stat(filepath.Join(y.dir, entry.Path)); err == nil {
+		// Файл на месте, но не прошёл отбор (например, другой --ext).
+		return
+	}
+	note, err := y.s.GetByID(entry.NoteID)
+	if errors.Is(err, store.ErrNotFound) {
+		delete(y.idx.Sources, key)
+		return
+	}
+	if err != nil {
+		y.rep.fail(entry.Path, err)
+		return
+	}
+
+	if hashNoteContent(note) != entry.ContentHash {
+		// Заметку меняли после удаления файла: правка важнее удаления.
+		if y.writeNote(key, note, entry.Path) {
+			y.rep.Exported++
+			y.rep.add(entry.Path, "exported")
+		}
+		return
+	}
+
+	if !y.dryRun {
+		tomb := *note
+		tomb.UpdatedAt = y.now
+		tomb.Deleted = true
+		if err := y.s.Append(&tomb); err != nil {
+			y.rep.fail(entry.Path, err)
+			return
+		}
+	}
+	delete(y.idx.Sources, key)
+	y.rep.DeletedNotes++
+	y.rep.add(entry.Path, "deleted-note")
+}
+
+// exportNew выгружает заметки, у которых ещё нет файла в каталоге.
+// Заметки, пришедшие из других каталогов (import или sync), остаются за
+// ними и сюда не выгружаются.
+func (y *syncer) exportNew() error {
+	skip := make(map[string]bool)
+	for _, e := range y.idx.Sources {
+		skip[e.NoteID] = true
+	}
+	notes, err := y.s.List(store.Filter{})
+	if err != nil {
+		return err
+	}
+	for i := range notes {
+		n := &notes[i]
+		if skip[n.ID] {
+			continue
+		}
+		rel := exportPath(n, "", LayoutFlat, false)
+		if _, err := os.Stat(filepath.Join(y.dir, rel)); err == nil {
+			rel = exportPath(n, "", LayoutFlat, true)
+		}
+		if y.writeNote("", n, rel) {
+			y.rep.Exported++
+			y.rep.add(rel, "exported")
+		}
+	}
+	return nil
+}
+
+// writeNote записывает заметку в файл rel и переносит запись индекса
+// со старого ключа oldKey на ключ по id, который теперь есть в файле.
+func (y *syncer) writeNote(oldKey string, n *model.Note, rel string) bool {
+	path := filepath.Join(y.dir, rel)
+	mod := n.UpdatedAt
+	if !y.dryRun {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			y.rep.fail(rel, err)
+			return false
+		}
+		if err := os.WriteFile(path, []byte(FormatMarkdown(n)), 0o644); err != nil {
+			y.rep.fail(rel, err)
+			return false
+		}
+		_ = os.Chtimes(path, mod, mod)
+	}
+	key := sourceKeyFor(y.dir, frontMatter{"id": n.ID}, rel)
+	if oldKey != "" && oldKey != key {
+		delete(y.idx.Sources, oldKey)
+	}
+	y.record(key, n.ID, rel, hashNoteContent(n), mod)
+	return true
+}
+
+func (y *syncer) record(key, id, rel, hash string, mod time.Time) {
+	y.idx.Sources[key] = sourceInfo{
+		NoteID:      id,
+		Path:        rel,
+		ContentHash: hash,
+		ModTimeUnix: mod.UTC().Unix(),
+		Dir:         y.dir,
+	}
+}
+
+// conflictPath возвращает имя копии файла rel для конфликта в момент t.
+func conflictPath(rel string, t time.Time) string {
+	ext := filepath.Ext(rel)
+	return strings.TrimSuffix(rel, ext) + conflictMark + t.Format("20060102T150405Z") + ext
+}
+
+func isConflictCopy(name string) bool {
+	return strings.Contains(name, conflictMark)
+}
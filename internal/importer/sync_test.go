@@ -0,0 +1,192 @@
+package importer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/model"
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/store"
+)
+
+func TestSyncDir(t *testing.T) {
+	root := t.TempDir()
+	dir := t.TempDir()
+
+	withStore := func(f func(s *store.Store)) {
+		t.Helper()
+		s, err := store.Open(root)
+		if err != nil {
+			t.Fatalf("Open: %v", err)
+		}
+		defer s.Close()
+		f(s)
+	}
+	sync := func(want SyncReport) {
+		t.Helper()
+		rep, err := SyncDir(root, dir, nil, false)
+		if err != nil {
+			t.Fatalf("SyncDir: %v", err)
+		}
+		want.Root, want.Dir, want.Results = rep.Root, rep.Dir, rep.Results
+		if !reflect.DeepEqual(*rep, want) {
+			t.Fatalf("SyncDir = %+v\nwant %+v", *rep, want)
+		}
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+	read := func(name string) string {
+		t.Helper()
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("ReadFile: %v", err)
+		}
+		return string(b)
+	}
+
+	a := model.NewNote("Alpha", "alpha", []string{"go"})
+	withStore(func(s *store.Store) {
+		if err := s.Append(a); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	})
+	write("file.md", "---\ntitle: File\n---\nfile body")
+
+	sync(SyncReport{Imported: 1, Exported: 1})
+	aFile := "alpha-" + a.ID[:8] + ".md"
+	if !strings.Contains(read(aFile), "id: "+a.ID) {
+		t.Fatalf("exported file:\n%s", read(aFile))
+	}
+	sync(SyncReport{Unchanged: 2})
+
+	var fileID string
+	withStore(func(s *store.Store) {
+		notes, _ := s.List(store.Filter{Contains: "file body"})
+		if len(notes) != 1 {
+			t.Fatalf("imported notes = %v", notes)
+		}
+		fileID = notes[0].ID
+	})
+
+	// Правка файла попадает в заметку, правка заметки — в файл.
+	write("file.md", "---\ntitle: File\n---\nfile body v2")
+	withStore(func(s *store.Store) {
+		if _, err := s.Patch(a.ID, func(n *model.Note) error { n.Text = "alpha v2"; return nil }); err != nil {
+			t.Fatalf("Patch: %v", err)
+		}
+	})
+	sync(SyncReport{Imported: 1, Exported: 1})
+	withStore(func(s *store.Store) {
+		if n, err := s.GetByID(fileID); err != nil || n.Text != "file body v2" {
+			t.Fatalf("file note = %+v, %v", n, err)
+		}
+	})
+	if !strings.HasSuffix(read(aFile), "---\nalpha v2") {
+		t.Fatalf("file after note edit:\n%s", read(aFile))
+	}
+
+	// Обе стороны изменились: в файле заметка, правка файла — в копии.
+	write(aFile, strings.Replace(read(aFile), "alpha v2", "alpha from file", 1))
+	withStore(func(s *store.Store) {
+		if _, err := s.Patch(a.ID, func(n *model.Note) error { n.Text = "alpha from store"; return nil }); err != nil {
+			t.Fatalf("Patch: %v", err)
+		}
+	})
+	sync(SyncReport{Unchanged: 1, Conflicts: 1})
+	if !strings.HasSuffix(read(aFile), "---\nalpha from store") {
+		t.Fatalf("file after conflict:\n%s", read(aFile))
+	}
+	copies, _ := filepath.Glob(filepath.Join(dir, "alpha-*"+conflictMark+"*.md"))
+	if len(copies) != 1 {
+		t.Fatalf("conflict copies = %v", copies)
+	}
+	if b, _ := os.ReadFile(copies[0]); !strings.HasSuffix(string(b), "alpha from file") {
+		t.Fatalf("conflict copy:\n%s", b)
+	}
+	sync(SyncReport{Unchanged: 2})
+
+	// Удаления идут в обе стороны.
+	if err := os.Remove(filepath.Join(dir, aFile)); err != nil {
+		t.Fatalf("Remove: %v", err)
+	}
+	withStore(func(s *store.Store) {
+		n, _ := s.GetByID(fileID)
+		n.Deleted = true
+		if err := s.Append(n); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	})
+	sync(SyncReport{DeletedNotes: 1, DeletedFiles: 1})
+	withStore(func(s *store.Store) {
+		if _, err := s.GetByID(a.ID); !errors.Is(err, store.ErrNotFound) {
+			t.Fatalf("GetByID(deleted) err = %v", err)
+		}
+	})
+	if _, err := os.Stat(filepath.Join(dir, "file.md")); !os.IsNotExist(err) {
+		t.Fatalf("file.md still exists: %v", err)
+	}
+	sync(SyncReport{})
+}
+
+func TestSyncDirDryRun(t *testing.T) {
+	root := t.TempDir()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "note.md"), []byte("body"), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	rep, err := SyncDir(root, dir, nil, true)
+	if err != nil || rep.Imported != 1 {
+		t.Fatalf("SyncDir dry-run = %+v, %v", rep, err)
+	}
+	rep, err = SyncDir(root, dir, nil, true)
+	if err != nil || rep.Imported != 1 {
+		t.Fatalf("SyncDir dry-run again = %+v, %v", rep, err)
+	}
+}
+
+func TestSyncDirKeepsOtherDirs(t *testing.T) {
+	root := t.TempDir()
+	dirA, dirB := t.TempDir(), t.TempDir()
+	for dir, title := range map[string]string{dirA: "From A", dirB: "From B"} {
+		if err := os.WriteFile(filepath.Join(dir, "x.md"), []byte("---\ntitle: "+title+"\n---\nbody"), 0o644); err != nil {
+			t.Fatalf("WriteFile: %v", err)
+		}
+	}
+
+	if _, err := ImportDir(root, dirA, ImportOptions{}); err != nil {
+		t.Fatalf("ImportDir(A): %v", err)
+	}
+	rep, err := SyncDir(root, dirB, nil, false)
+	if err != nil || rep.Imported != 1 || rep.Exported != 0 {
+		t.Fatalf("SyncDir(B) = %+v, %v", rep, err)
+	}
+	// Заметка из A не выгружается в B.
+	if entries, _ := os.ReadDir(dirB); len(entries) != 1 {
+		t.Fatalf("dir B = %v", entries)
+	}
+
+	irep, err := ImportDir(root, dirA, ImportOptions{})
+	if err != nil || irep.Skipped != 1 || irep.Updated != 0 || irep.Created != 0 {
+		t.Fatalf("ImportDir(A) again = %+v, %v", irep, err)
+	}
+	if rep, err := SyncDir(root, dirB, nil, false); err != nil || rep.Unchanged != 1 || rep.Imported+rep.Exported != 0 {
+		t.Fatalf("SyncDir(B) again = %+v, %v", rep, err)
+	}
+
+	s, err := store.OpenWith(root, store.Options{Lock: store.LockShared})
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer s.Close()
+	notes, err := s.List(store.Filter{Sort: store.SortTitle})
+	if err != nil || len(notes) != 2 || notes[0].Title != "From A" || notes[1].Title != "From B" {
+		t.Fatalf("notes = %+v, %v", notes, err)
+	}
+}
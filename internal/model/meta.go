@@ -0,0 +1,197 @@
+package model
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MetaSeparator разделяет уровни вложенного ключа: params.owner.
+const MetaSeparator = "."
+
+// reservedMeta — ключи front matter, которые становятся полями заметки
+// (date и lastmod — имена created и updated у Hugo). В Meta их быть не
+// может: markdown пишет Meta рядом с ними.
+var reservedMeta = map[string]bool{
+	"title": true, "tags": true, "id": true,
+	"created": true, "updated": true, "date": true, "lastmod": true,
+}
+
+// ReservedMetaKey сообщает, занят ли ключ полем заметки.
+func ReservedMetaKey(key string) bool {
+	return reservedMeta[key]
+}
+
+// CleanMetaKey приводит ключ к нижнему регистру и проверяет его: ключ
+// непустой, без пробелов и знаков сравнения и не занят полем заметки
+// (см. ReservedMetaKey).
+func CleanMetaKey(key string) (string, error) {
+	key = strings.ToLower(strings.TrimSpace(key))
+	switch {
+	case key == "":
+		return "", fmt.Errorf("пустой ключ")
+	case strings.ContainsAny(key, " \t=<>!:\"'"):
+		return "", fmt.Errorf("недопустимый ключ %q", key)
+	case reservedMeta[key]:
+		return "", fmt.Errorf("ключ %q занят полем заметки", key)
+	}
+	return key, nil
+}
+
+// ParseMetaValue разбирает значение из командной строки: true и false —
+// bool, числа — float64, остальное — строка. Значение в двойных кавычках
+// всегда строка: "42".
+func ParseMetaValue(s string) any {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		return s[1 : len(s)-1]
+	}
+	switch s {
+	case "true":
+		return true
+	case "false":
+		return false
+	}
+	if f, ok := ParseMetaNumber(s); ok {
+		return f
+	}
+	return s
+}
+
+// ParseMetaNumber разбирает десятичное число метаданных. Шестнадцатеричная
+// запись, подчёркивания, NaN и бесконечности числом не считаются: их
+// нельзя сохранить в JSON, и они остаются строками.
+func ParseMetaNumber(s string) (float64, bool) {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil || strings.ContainsAny(s, "xXpP_") || math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0, false
+	}
+	return f, true
+}
+
+// MetaString — значение метаданных строкой для вывода и сравнения:
+// числа без лишних нулей, списки и таблицы — в JSON.
+func MetaString(v any) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	}
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(b)
+}
+
+// MetaLookup ищет значение по ключу; ключ через точку спускается во
+// вложенные таблицы: params.owner.
+func MetaLookup(meta map[string]any, key string) (any, bool) {
+	if v, ok := meta[key]; ok {
+		return v, true
+	}
+	head, rest, ok := strings.Cut(key, MetaSeparator)
+	for ok {
+		if sub, isMap := meta[head].(map[string]any); isMap {
+			if v, found := MetaLookup(sub, rest); found {
+				return v, true
+			}
+		}
+		var next string
+		next, rest, ok = strings.Cut(rest, MetaSeparator)
+		head += MetaSeparator + next
+	}
+	return nil, false
+}
+
+// MetaPairs возвращает пары "ключ=значение" в нижнем регистре для
+// индекса: вложенные ключи — через точку, каждый элемент списка —
+// отдельной парой.
+func MetaPairs(meta map[string]any) []string {
+	var out []string
+	var walk func(prefix string, v any)
+	walk = func(prefix string, v any) {
+		switch v := v.(type) {
+		case map[string]any:
+			for k, x := range v {
+				walk(prefix+MetaSeparator+strings.ToLower(k), x)
+			}
+		case []any:
+			for _, x := range v {
+				walk(prefix, x)
+			}
+		case nil:
+		default:
+			out = append(out, prefix+"="+strings.ToLower(MetaString(v)))
+		}
+	}
+	for k, v := range meta {
+		walk(strings.ToLower(k), v)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// NormalizeMeta приводит значения метаданных к виду, в котором они
+// читаются из лога: числа — float64, списки — []any, таблицы —
+// map[string]any. Время становится строкой: дата без времени — 2006-01-02,
+// иначе RFC3339 в UTC. Так заметка до и после записи сравнивается
+// без ложных отличий. Пустая карта превращается в nil.
+func NormalizeMeta(meta map[string]any) (map[string]any, error) {
+	if len(meta) == 0 {
+		return nil, nil
+	}
+	b, err := json.Marshal(plainValue(meta))
+	if err != nil {
+		return nil, fmt.Errorf("meta: %w", err)
+	}
+	var out map[string]any
+	if err := json.Unmarshal(b, &out); err != nil {
+		return nil, fmt.Errorf("meta: %w", err)
+	}
+	return out, nil
+}
+
+// plainValue заменяет строками время, NaN, бесконечности и ключи-не-строки,
+// чтобы значение кодировалось в JSON.
+func plainValue(v any) any {
+	switch v := v.(type) {
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return MetaString(v)
+		}
+	case time.Time:
+		if h, m, s := v.Clock(); h == 0 && m == 0 && s == 0 && v.Nanosecond() == 0 {
+			return v.Format(time.DateOnly)
+		}
+		return v.UTC().Format(time.RFC3339Nano)
+	case map[string]any:
+		out := make(map[string]any, len(v))
+		for k, x := range v {
+			out[k] = plainValue(x)
+		}
+		return out
+	case map[any]any:
+		out := make(map[string]any, len(v))
+		for k, x := range v {
+			out[fmt.Sprint(k)] = plainValue(x)
+		}
+		return out
+	case []any:
+		out := make([]any, len(v))
+		for i, x := range v {
+			out[i] = plainValue(x)
+		}
+		return out
+	}
+	return v
+}
@@ -0,0 +1,104 @@
+package model
+
+import (
+	"math"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestNormalizeMeta(t *testing.T) {
+	got, err := NormalizeMeta(map[string]any{
+		"priority": 3,
+		"due":      time.Date(2025, 1, 2, 0, 0, 0, 0, time.UTC),
+		"seen":     time.Date(2025, 1, 2, 3, 4, 5, 6, time.FixedZone("X", 3600)),
+		"links":    []any{"a", 1},
+		"params":   map[any]any{1: true},
+		"level":    math.Inf(1),
+		"ratio":    []any{math.NaN()},
+	})
+	if err != nil {
+		t.Fatalf("NormalizeMeta: %v", err)
+	}
+	want := map[string]any{
+		"priority": float64(3),
+		"due":      "2025-01-02",
+		"seen":     "2025-01-02T02:04:05.000000006Z",
+		"links":    []any{"a", float64(1)},
+		"params":   map[string]any{"1": true},
+		"level":    "+Inf",
+		"ratio":    []any{"NaN"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("NormalizeMeta = %#v, want %#v", got, want)
+	}
+
+	if got, err := NormalizeMeta(map[string]any{}); got != nil || err != nil {
+		t.Fatalf("NormalizeMeta(empty) = %v, %v", got, err)
+	}
+}
+
+func TestCleanMetaKey(t *testing.T) {
+	for in, want := range map[string]string{" Status ": "status", "Version": "version", "deleted": "deleted"} {
+		if k, err := CleanMetaKey(in); k != want || err != nil {
+			t.Fatalf("CleanMetaKey(%q) = %q, %v", in, k, err)
+		}
+	}
+	for _, key := range []string{"", "a b", "a=b", "title", "Created", "lastmod"} {
+		if _, err := CleanMetaKey(key); err == nil {
+			t.Fatalf("CleanMetaKey(%q): want error", key)
+		}
+	}
+}
+
+func TestParseMetaValue(t *testing.T) {
+	cases := map[string]any{
+		"open":     "open",
+		"2":        float64(2),
+		"-1.5":     -1.5,
+		"true":     true,
+		"false":    false,
+		`"42"`:     "42",
+		"0x10":     "0x10",
+		"2025-1":   "2025-1",
+		"Nan":      "Nan",
+		"NaN":      "NaN",
+		"Inf":      "Inf",
+		"+Inf":     "+Inf",
+		"-inf":     "-inf",
+		"1e400":    "1e400",
+		"Infinity": "Infinity",
+	}
+	for in, want := range cases {
+		if got := ParseMetaValue(in); got != want {
+			t.Fatalf("ParseMetaValue(%q) = %#v, want %#v", in, got, want)
+		}
+	}
+}
+
+func TestMetaLookupAndPairs(t *testing.T) {
+	meta := map[string]any{
+		"status":  "Open",
+		"points":  float64(3),
+		"params":  map[string]any{"owner": "ann", "links": []any{"a", "b"}},
+		"a.b":     true,
+		"ignored": nil,
+	}
+	if v, ok := MetaLookup(meta, "params.owner"); !ok || v != "ann" {
+		t.Fatalf("MetaLookup(params.owner) = %v, %v", v, ok)
+	}
+	if v, ok := MetaLookup(meta, "a.b"); !ok || v != true {
+		t.Fatalf("MetaLookup(a.b) = %v, %v", v, ok)
+	}
+	if _, ok := MetaLookup(meta, "params.missing"); ok {
+		t.Fatalf("MetaLookup(params.missing) found")
+	}
+
+	want := []string{"a.b=true", "params.links=a", "params.links=b", "params.owner=ann", "points=3", "status=open"}
+	if got := MetaPairs(meta); !reflect.DeepEqual(got, want) {
+		t.Fatalf("MetaPairs = %v, want %v", got, want)
+	}
+	if got := MetaString(meta["params"]); got != `{"links":["a","b"],"owner":"ann"}` {
+		t.Fatalf("MetaString = %s", got)
+	}
+}
@@ -16,6 +16,12 @@ type Note struct {
 	CreatedAt time.Time `json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
 	Deleted   bool      `json:"deleted"`
+	// Version — номер версии, растёт на единицу с каждой записью заметки
+	// в лог (включая удаление). Назначается хранилищем при записи.
+	Version int `json:"version,omitempty"`
+	// Meta — произвольные поля заметки (например, из front matter) в
+	// виде, который даёт NormalizeMeta.
+	Meta map[string]any `json:"meta,omitempty"`
 }
 
 func NewNote(title, text string, tags []string) *Note {
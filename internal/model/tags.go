@@ -0,0 +1,37 @@
+package model
+
+import "strings"
+
+// TagSeparator разделяет уровни иерархического тега: project/noteline/bugs.
+const TagSeparator = "/"
+
+// CleanTag убирает пробелы и разделители по краям тега.
+func CleanTag(tag string) string {
+	return strings.Trim(strings.TrimSpace(tag), TagSeparator)
+}
+
+// TagPrefixes возвращает всех предков тега и его самого, начиная с
+// корня: project, project/noteline, project/noteline/bugs.
+func TagPrefixes(tag string) []string {
+	tag = CleanTag(tag)
+	if tag == "" {
+		return nil
+	}
+	var out []string
+	for i := 0; i < len(tag); i++ {
+		if strings.HasPrefix(tag[i:], TagSeparator) {
+			out = append(out, tag[:i])
+		}
+	}
+	return append(out, tag)
+}
+
+// TagUnder сообщает, совпадает ли tag с prefix или лежит под ним:
+// project/noteline/bugs лежит под project/noteline, а project/notes — нет.
+func TagUnder(tag, prefix string) bool {
+	tag, prefix = CleanTag(tag), CleanTag(prefix)
+	if prefix == "" {
+		return false
+	}
+	return tag == prefix || strings.HasPrefix(tag, prefix+TagSeparator)
+}
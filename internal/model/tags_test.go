@@ -0,0 +1,39 @@
+package model
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestTagPrefixes(t *testing.T) {
+	cases := map[string][]string{
+		"go":                    {"go"},
+		"project/noteline/bugs": {"project", "project/noteline", "project/noteline/bugs"},
+		" /a/b/ ":               {"a", "a/b"},
+		"":                      nil,
+	}
+	for in, want := range cases {
+		if got := TagPrefixes(in); !reflect.DeepEqual(got, want) {
+			t.Fatalf("TagPrefixes(%q) = %v, want %v", in, got, want)
+		}
+	}
+}
+
+func TestTagUnder(t *testing.T) {
+	cases := []struct {
+		tag, prefix string
+		want        bool
+	}{
+		{"project/noteline/bugs", "project/noteline", true},
+		{"project/noteline", "project/noteline", true},
+		{"project/noteline", "project/noteline/", true},
+		{"project/notes", "project/note", false},
+		{"project", "project/noteline", false},
+		{"go", "", false},
+	}
+	for _, c := range cases {
+		if got := TagUnder(c.tag, c.prefix); got != c.want {
+			t.Fatalf("TagUnder(%q, %q) = %v, want %v", c.tag, c.prefix, got, c.want)
+		}
+	}
+}
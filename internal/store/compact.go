@@ -0,0 +1,230 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/model"
+)
+
+const dirCompactTmp = ".compact"
+
+type pendingCompaction struct {
+	Replaces []int `json:"replaces"`
+	Outputs  []int `json:"outputs"`
+}
+
+type CompactReport struct {
+	SegmentsBefore int   `json:"segments_before"`
+	SegmentsAfter  int   `json:"segments_after"`
+	RecordsBefore  int   `json:"records_before"`
+	RecordsAfter   int   `json:"records_after"`
+	BytesBefore    int64 `json:"bytes_before"`
+	BytesAfter     int64 `json:"bytes_after"`
+	BytesReclaimed int64 `json:"bytes_reclaimed"`
+}
+
+// Compact переписывает закрытые сегменты так, чтобы в них осталась только
+// последняя живая версия каждой заметки. Активный сегмент, если он не пуст,
+// сначала закрывается ротацией, поэтому после Compact в логе нет ни
+// устаревших версий, ни tombstone-записей.
+func (s *Store) Compact() (*CompactReport, error) {
+	if s.readOnly {
+		return nil, ErrReadOnly
+	}
+	if s.active != nil {
+		if st, err := s.active.Stat(); err == nil && st.Size() > 0 {
+			if err := s.rotate(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var sealed []int
+	for _, path := range s.segmentFiles() {
+		no, err := parseSeqFromName(filepath.Base(path))
+		if err != nil || no >= s.activeNo {
+			continue
+		}
+		sealed = append(sealed, no)
+	}
+
+	rep := &CompactReport{}
+	if len(sealed) == 0 {
+		return rep, nil
+	}
+
+	type live struct {
+		pos  int
+		note model.Note
+	}
+	latest := make(map[string]live)
+	seen := make(map[string]int)
+	pos := 0
+	for _, no := range sealed {
+		path := s.segmentPath(no)
+		if st, err := os.Stat(path); err == nil {
+			rep.BytesBefore += st.Size()
+		}
+		err := scanSegment(path, func(_ int64, _ int, n model.Note) error {
+			rep.RecordsBefore++
+			pos++
+			// Записям без номера версии он назначается здесь, иначе после
+			// сжатия нумерация началась бы заново.
+			seen[n.ID]++
+			if n.Version == 0 {
+				n.Version = seen[n.ID]
+			}
+			if n.Deleted {
+				delete(latest, n.ID)
+				return nil
+			}
+			latest[n.ID] = live{pos: pos, note: n}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	rep.SegmentsBefore = len(sealed)
+
+	ordered := make([]live, 0, len(latest))
+	for _, l := range latest {
+		ordered = append(ordered, l)
+	}
+	sort.Slice(ordered, func(i, j int) bool {
+		return ordered[i].pos < ordered[j].pos
+	})
+
+	tmpDir := filepath.Join(s.root, dirSegments, dirCompactTmp)
+	if err := os.RemoveAll(tmpDir); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(tmpDir, 0o755); err != nil {
+		return nil, err
+	}
+
+	var outputs []int
+	var out *os.File
+	var outSize int64
+	closeOut := func() error {
+		if out == nil {
+			return nil
+		}
+		err := out.Sync()
+		if cerr := out.Close(); err == nil {
+			err = cerr
+		}
+		out = nil
+		return err
+	}
+	openOut := func() error {
+		if err := closeOut(); err != nil {
+			return err
+		}
+		no := sealed[len(outputs)]
+		f, err := os.Create(filepath.Join(tmpDir, filepath.Base(s.segmentPath(no))))
+		if err != nil {
+			return err
+		}
+		out = f
+		outSize = 0
+		outputs = append(outputs, no)
+		return nil
+	}
+
+	for _, l := range ordered {
+		b, err := encodeRecord(&l.note)
+		if err != nil {
+			_ = closeOut()
+			return nil, err
+		}
+		full := outSize > 0 && outSize+int64(len(b)) > int64(s.man.SegmentSizeBytes)
+		if out == nil || (full && len(outputs) < len(sealed)) {
+			if err := openOut(); err != nil {
+				return nil, err
+			}
+		}
+		if _, err := out.Write(b); err != nil {
+			_ = closeOut()
+			return nil, err
+		}
+		outSize += int64(len(b))
+		rep.BytesAfter += int64(len(b))
+		rep.RecordsAfter++
+	}
+	if err := closeOut(); err != nil {
+		return nil, err
+	}
+	syncDir(tmpDir)
+
+	s.man.PendingCompaction = &pendingCompaction{
+		Replaces: sealed,
+		Outputs:  outputs,
+	}
+	if err := s.saveManifest(); err != nil {
+		return nil, err
+	}
+	if err := s.finishCompaction(); err != nil {
+		return nil, err
+	}
+	s.closeReaders()
+	if err := s.rebuildIDIndex(); err != nil {
+		return nil, err
+	}
+
+	rep.SegmentsAfter = len(outputs)
+	rep.BytesReclaimed = rep.BytesBefore - rep.BytesAfter
+	return rep, nil
+}
+
+// finishCompaction подменяет старые сегменты результатом сжатия. Операция
+// идемпотентна: если процесс упал посередине, Open повторит её по записи
+// pending_compaction в манифесте.
+func (s *Store) finishCompaction() error {
+	pc := s.man.PendingCompaction
+	tmpDir := filepath.Join(s.root, dirSegments, dirCompactTmp)
+
+	isOutput := make(map[int]bool, len(pc.Outputs))
+	for _, no := range pc.Outputs {
+		isOutput[no] = true
+	}
+
+	for _, no := range pc.Replaces {
+		if isOutput[no] {
+			continue
+		}
+		if err := os.Remove(s.segmentPath(no)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+	}
+	for _, no := range pc.Outputs {
+		dst := s.segmentPath(no)
+		src := filepath.Join(tmpDir, filepath.Base(dst))
+		if _, err := os.Stat(src); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err := os.Rename(src, dst); err != nil {
+			return err
+		}
+	}
+	syncDir(filepath.Join(s.root, dirSegments))
+
+	if err := os.RemoveAll(tmpDir); err != nil {
+		return err
+	}
+
+	s.man.PendingCompaction = nil
+	return s.saveManifest()
+}
+
+func syncDir(path string) {
+	d, err := os.Open(path)
+	if err != nil {
+		return
+	}
+	_ = d.Sync()
+	_ = d.Close()
+}
@@ -0,0 +1,166 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/model"
+)
+
+func countRecords(t *testing.T, s *Store) int {
+	t.Helper()
+	total := 0
+	for _, path := range s.segmentFiles() {
+		if err := scanSegment(path, func(int64, int, model.Note) error {
+			total++
+			return nil
+		}); err != nil {
+			t.Fatalf("scanSegment(%s): %v", path, err)
+		}
+	}
+	return total
+}
+
+func TestCompactDropsOldVersionsAndTombstones(t *testing.T) {
+	root := t.TempDir()
+	s, err := Open(root)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	s.man.SegmentSizeBytes = 256
+
+	keep := model.NewNote("Keep", strings.Repeat("k", 50), nil)
+	gone := model.NewNote("Gone", strings.Repeat("g", 50), nil)
+	for _, n := range []*model.Note{keep, gone} {
+		if err := s.Append(n); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+
+	upd := *keep
+	upd.Text = "updated"
+	upd.UpdatedAt = time.Now().UTC()
+	if err := s.Append(&upd); err != nil {
+		t.Fatalf("Append update: %v", err)
+	}
+
+	tomb := *gone
+	tomb.Deleted = true
+	if err := s.Append(&tomb); err != nil {
+		t.Fatalf("Append tombstone: %v", err)
+	}
+
+	rep, err := s.Compact()
+	if err != nil {
+		t.Fatalf("Compact: %v", err)
+	}
+	if rep.RecordsBefore != 4 || rep.RecordsAfter != 1 {
+		t.Fatalf("records %d -> %d, want 4 -> 1", rep.RecordsBefore, rep.RecordsAfter)
+	}
+	if rep.BytesReclaimed <= 0 {
+		t.Fatalf("BytesReclaimed = %d, want > 0", rep.BytesReclaimed)
+	}
+	if got := countRecords(t, s); got != 1 {
+		t.Fatalf("records on disk after compaction = %d, want 1", got)
+	}
+	if s.man.PendingCompaction != nil {
+		t.Fatalf("pending compaction left in manifest: %+v", s.man.PendingCompaction)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	s2, err := Open(root)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer s2.Close()
+	noteCache.Clear()
+
+	got, err := s2.GetByID(keep.ID)
+	if err != nil {
+		t.Fatalf("GetByID(keep): %v", err)
+	}
+	if got.Text != "updated" {
+		t.Fatalf("Text = %q, want %q", got.Text, "updated")
+	}
+	if _, err := s2.GetByID(gone.ID); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("GetByID(gone) = %v, want ErrNotFound", err)
+	}
+
+	n := model.NewNote("After", "compaction", nil)
+	if err := s2.Append(n); err != nil {
+		t.Fatalf("Append after compaction: %v", err)
+	}
+	list, err := s2.List(Filter{})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("List after compaction returned %d notes, want 2", len(list))
+	}
+}
+
+func TestOpenFinishesPendingCompaction(t *testing.T) {
+	root := t.TempDir()
+	s, err := Open(root)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	n := model.NewNote("Title", "Body", nil)
+	if err := s.Append(n); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if err := s.rotate(); err != nil {
+		t.Fatalf("rotate: %v", err)
+	}
+
+	// Имитируем сбой после записи манифеста: результат сжатия лежит во
+	// временном каталоге, а старый сегмент ещё не подменён.
+	tmpDir := filepath.Join(root, dirSegments, dirCompactTmp)
+	if err := os.MkdirAll(tmpDir, 0o755); err != nil {
+		t.Fatalf("MkdirAll: %v", err)
+	}
+	b, err := encodeRecord(n)
+	if err != nil {
+		t.Fatalf("encodeRecord: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(tmpDir, filepath.Base(s.segmentPath(1))), b, 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	garbage := append(append([]byte{}, b...), b...)
+	if err := os.WriteFile(s.segmentPath(1), garbage, 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+	s.man.PendingCompaction = &pendingCompaction{Replaces: []int{1}, Outputs: []int{1}}
+	if err := s.saveManifest(); err != nil {
+		t.Fatalf("saveManifest: %v", err)
+	}
+	_ = s.active.Close()
+	s.active = nil
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	s2, err := Open(root)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer s2.Close()
+
+	if s2.man.PendingCompaction != nil {
+		t.Fatalf("pending compaction not finished on Open")
+	}
+	if _, err := os.Stat(tmpDir); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("compaction temp dir still exists: %v", err)
+	}
+	if got := countRecords(t, s2); got != 1 {
+		t.Fatalf("records on disk = %d, want 1", got)
+	}
+}
@@ -0,0 +1,327 @@
+package store
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+
+	fts "github.com/Victor3563/NoteLine/cli-notebook/internal/fulltext"
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/model"
+)
+
+const dirQuarantine = "quarantine"
+
+type FsckIssue struct {
+	Kind     string `json:"kind"`
+	Location string `json:"location"`
+	Detail   string `json:"detail"`
+	Repaired bool   `json:"repaired"`
+}
+
+type FsckReport struct {
+	Segments  int         `json:"segments"`
+	Records   int         `json:"records"`
+	LiveNotes int         `json:"live_notes"`
+	Issues    []FsckIssue `json:"issues"`
+}
+
+func (r *FsckReport) add(kind, location, detail string, repaired bool) {
+	r.Issues = append(r.Issues, FsckIssue{
+		Kind:     kind,
+		Location: location,
+		Detail:   detail,
+		Repaired: repaired,
+	})
+}
+
+// Unrepaired возвращает число проблем, оставшихся неисправленными.
+func (r *FsckReport) Unrepaired() int {
+	n := 0
+	for _, is := range r.Issues {
+		if !is.Repaired {
+			n++
+		}
+	}
+	return n
+}
+
+// Fsck проверяет сегменты, манифест, первичный индекс, полнотекстовый
+// индекс и файл LRU-кэша. При repair битые записи переносятся в каталог
+// quarantine, манифест и индексы исправляются.
+func (s *Store) Fsck(repair bool) (*FsckReport, error) {
+	if repair && s.readOnly {
+		return nil, ErrReadOnly
+	}
+	rep := &FsckReport{}
+	// Оборванный хвост сегмента Open отрезает всегда, и без --repair
+	// тоже: fsck только сообщает о нём как об исправленной проблеме.
+	if r := s.recovery; r != nil {
+		rep.add("torn_tail", fmt.Sprintf("%s (offset %d)", r.Segment, r.Offset),
+			fmt.Sprintf("opening the store discarded %d bytes of an unfinished record (torn tails are always truncated on open)", r.DiscardedBytes), true)
+	}
+
+	expected := newIDIndex()
+	maxSeq := 0
+	rewritten := false
+
+	for _, path := range s.segmentFiles() {
+		name := filepath.Base(path)
+		no, err := parseSeqFromName(name)
+		if err != nil {
+			rep.add("segment_name", name, "cannot parse segment number", false)
+			continue
+		}
+		rep.Segments++
+		if no > maxSeq {
+			maxSeq = no
+		}
+
+		var bad []rawRecord
+		line := 0
+		err = readSegment(path, 0, func(rec rawRecord) error {
+			line++
+			if rec.Err != nil {
+				bad = append(bad, rec)
+				rep.add("bad_record", fmt.Sprintf("%s:%d (offset %d)", name, line, rec.Off), rec.Err.Error(), repair)
+				return nil
+			}
+			if !rec.Complete {
+				bad = append(bad, rec)
+				rep.add("bad_record", fmt.Sprintf("%s:%d (offset %d)", name, line, rec.Off), "record is not terminated by newline", repair)
+				return nil
+			}
+			rep.Records++
+			expected.apply(no, rec.Off, rec.Size, rec.Note)
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		if repair && len(bad) > 0 {
+			if err := s.quarantineRecords(no, bad); err != nil {
+				return nil, err
+			}
+			rewritten = true
+		}
+	}
+
+	if rewritten {
+		s.closeReaders()
+		if err := s.rebuildIDIndex(); err != nil {
+			return nil, err
+		}
+	} else {
+		s.checkIDIndex(rep, expected, repair)
+	}
+
+	s.checkManifest(rep, maxSeq, repair)
+
+	live := make(map[string]model.Note)
+	for id, e := range s.idx.Entries {
+		if e.Deleted {
+			continue
+		}
+		n, err := s.readEntry(e)
+		if err != nil {
+			rep.add("id_index", id, err.Error(), false)
+			continue
+		}
+		live[id] = n
+	}
+	rep.LiveNotes = len(live)
+
+	s.checkFulltext(rep, live, repair)
+	s.checkCacheFile(rep, live, repair)
+
+	return rep, nil
+}
+
+func (s *Store) quarantineRecords(no int, bad []rawRecord) error {
+	path := s.segmentPath(no)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	var kept, dropped bytes.Buffer
+	var prev int64
+	for _, r := range bad {
+		kept.Write(data[prev:r.Off])
+		dropped.Write(data[r.Off : r.Off+int64(r.Size)])
+		if !r.Complete {
+			dropped.WriteByte('\n')
+		}
+		prev = r.Off + int64(r.Size)
+	}
+	kept.Write(data[prev:])
+
+	qdir := filepath.Join(s.root, dirQuarantine)
+	if err := os.MkdirAll(qdir, 0o755); err != nil {
+		return err
+	}
+	qpath := filepath.Join(qdir, fmt.Sprintf("%s.%d.bad", filepath.Base(path), time.Now().UTC().Unix()))
+	if err := os.WriteFile(qpath, dropped.Bytes(), 0o644); err != nil {
+		return err
+	}
+
+	isActive := s.active != nil && no == s.activeNo
+	if isActive {
+		_ = s.active.Close()
+		s.active = nil
+	}
+	if err := writeFileAtomic(path, kept.Bytes()); err != nil {
+		return err
+	}
+	if isActive {
+		return s.openActiveSegmentRW()
+	}
+	return nil
+}
+
+func (s *Store) checkIDIndex(rep *FsckReport, expected *idIndex, repair bool) {
+	mismatch := 0
+	for id, want := range expected.Entries {
+		got, ok := s.idx.Entries[id]
+		if !ok || got.Segment != want.Segment || got.Offset != want.Offset || got.Deleted != want.Deleted ||
+			got.Created != want.Created || got.Updated != want.Updated {
+			mismatch++
+		}
+	}
+	for id := range s.idx.Entries {
+		if _, ok := expected.Entries[id]; !ok {
+			mismatch++
+		}
+	}
+	if mismatch == 0 {
+		return
+	}
+	rep.add("id_index", filenameIDIndex, fmt.Sprintf("%d entries do not match segments", mismatch), repair)
+	if repair {
+		s.idx = expected
+		s.idxDirty = true
+	}
+}
+
+func (s *Store) checkManifest(rep *FsckReport, maxSeq int, repair bool) {
+	changed := false
+	if s.man.NextSegmentSeq <= maxSeq {
+		rep.add("manifest", filenameManifest,
+			fmt.Sprintf("next_segment_seq=%d, but segment %d exists", s.man.NextSegmentSeq, maxSeq), repair)
+		if repair {
+			s.man.NextSegmentSeq = maxSeq + 1
+			changed = true
+		}
+	}
+	if s.man.SegmentSizeBytes <= 0 {
+		rep.add("manifest", filenameManifest,
+			fmt.Sprintf("segment_size_bytes=%d", s.man.SegmentSizeBytes), repair)
+		if repair {
+			s.man.SegmentSizeBytes = defaultSegSize
+			changed = true
+		}
+	}
+	switch s.man.Fsync {
+	case "", fsyncAlways, fsyncInterval, fsyncNever:
+	default:
+		rep.add("manifest", filenameManifest, fmt.Sprintf("unknown fsync policy %q", s.man.Fsync), repair)
+		if repair {
+			s.man.Fsync = fsyncAlways
+			changed = true
+		}
+	}
+	if s.man.Language != "" && !fts.ValidLanguage(s.man.Language) {
+		rep.add("manifest", filenameManifest, fmt.Sprintf("unknown language %q", s.man.Language), repair)
+		if repair {
+			s.man.Language = fts.LangEnglish
+			changed = true
+		}
+	}
+	if changed {
+		if err := s.saveManifest(); err != nil {
+			rep.add("manifest", filenameManifest, err.Error(), false)
+		}
+	}
+}
+
+func (s *Store) checkFulltext(rep *FsckReport, live map[string]model.Note, repair bool) {
+	const loc = "index.bleve"
+	docs, err := fts.DocIDs()
+	if err != nil {
+		rep.add("fulltext", loc, err.Error(), false)
+		return
+	}
+
+	indexed := make(map[string]bool, len(docs))
+	for _, id := range docs {
+		indexed[id] = true
+		if _, ok := live[id]; !ok {
+			repaired := false
+			if repair {
+				repaired = fts.DeleteNote(id) == nil
+			}
+			rep.add("fulltext_stale", loc+"#"+id, "document for a missing or deleted note", repaired)
+		}
+	}
+	for id, n := range live {
+		if indexed[id] {
+			continue
+		}
+		repaired := false
+		if repair {
+			n := n
+			repaired = fts.IndexNote(&n) == nil
+		}
+		rep.add("fulltext_missing", loc+"#"+id, "note is not indexed", repaired)
+	}
+}
+
+func (s *Store) checkCacheFile(rep *FsckReport, live map[string]model.Note, repair bool) {
+	b, err := os.ReadFile(s.cacheFile)
+	if err != nil {
+		return
+	}
+	loc := filepath.Base(s.cacheFile)
+
+	var cached []model.Note
+	if err := json.Unmarshal(b, &cached); err != nil {
+		rep.add("cache", loc, err.Error(), repair)
+		if repair && noteCache != nil {
+			noteCache.Clear()
+		}
+		return
+	}
+
+	for _, c := range cached {
+		cur, ok := live[c.ID]
+		switch {
+		case !ok:
+			rep.add("cache_stale", loc+"#"+c.ID, "cached note is missing or deleted", repair)
+		case !sameNote(&c, &cur):
+			rep.add("cache_stale", loc+"#"+c.ID, "cached copy differs from the latest version", repair)
+		default:
+			continue
+		}
+		if repair && noteCache != nil {
+			noteCache.Remove(c.ID)
+		}
+	}
+}
+
+func sameNote(a, b *model.Note) bool {
+	if a.Title != b.Title || a.Text != b.Text || a.Deleted != b.Deleted || a.Version != b.Version ||
+		!a.CreatedAt.Equal(b.CreatedAt) || !a.UpdatedAt.Equal(b.UpdatedAt) || len(a.Tags) != len(b.Tags) ||
+		!reflect.DeepEqual(a.Meta, b.Meta) {
+		return false
+	}
+	for i := range a.Tags {
+		if a.Tags[i] != b.Tags[i] {
+			return false
+		}
+	}
+	return true
+}
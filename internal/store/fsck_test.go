@@ -0,0 +1,193 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/model"
+)
+
+func issueKinds(rep *FsckReport) map[string]int {
+	out := make(map[string]int)
+	for _, is := range rep.Issues {
+		out[is.Kind]++
+	}
+	return out
+}
+
+func TestFsckReportsAndRepairs(t *testing.T) {
+	root := t.TempDir()
+	s, err := Open(root)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	n1 := model.NewNote("One", "first", nil)
+	n2 := model.NewNote("Two", "second", nil)
+	if err := s.Append(n1); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	seg := s.segmentPath(s.activeNo)
+	f, err := os.OpenFile(seg, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	if _, err := f.Write([]byte("garbage line\n")); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	_ = f.Close()
+	if err := s.Append(n2); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+
+	s.man.NextSegmentSeq = 1
+	if err := s.saveManifest(); err != nil {
+		t.Fatalf("saveManifest: %v", err)
+	}
+
+	stale := *n1
+	stale.Text = "stale copy"
+	noteCache.Add(n1.ID, &stale)
+	s.saveCacheToDisk()
+
+	lost := s.idx.Entries[n2.ID]
+	delete(s.idx.Entries, n2.ID)
+
+	rep, err := s.Fsck(false)
+	if err != nil {
+		t.Fatalf("Fsck: %v", err)
+	}
+	kinds := issueKinds(rep)
+	for _, k := range []string{"bad_record", "manifest", "cache_stale", "id_index"} {
+		if kinds[k] == 0 {
+			t.Fatalf("Fsck did not report %q, issues: %+v", k, rep.Issues)
+		}
+	}
+	if rep.Unrepaired() != len(rep.Issues) {
+		t.Fatalf("dry Fsck must not repair anything: %+v", rep.Issues)
+	}
+	s.idx.Entries[n2.ID] = lost
+
+	rep, err = s.Fsck(true)
+	if err != nil {
+		t.Fatalf("Fsck repair: %v", err)
+	}
+	if rep.Unrepaired() != 0 {
+		t.Fatalf("unrepaired issues left: %+v", rep.Issues)
+	}
+
+	q, _ := filepath.Glob(filepath.Join(root, dirQuarantine, "*.bad"))
+	if len(q) != 1 {
+		t.Fatalf("expected 1 quarantine file, got %v", q)
+	}
+	if s.man.NextSegmentSeq <= s.activeNo {
+		t.Fatalf("manifest next_segment_seq not repaired: %d", s.man.NextSegmentSeq)
+	}
+
+	for _, n := range []*model.Note{n1, n2} {
+		got, err := s.GetByID(n.ID)
+		if err != nil {
+			t.Fatalf("GetByID(%s) after repair: %v", n.Title, err)
+		}
+		if got.Text != n.Text {
+			t.Fatalf("GetByID(%s) text = %q, want %q", n.Title, got.Text, n.Text)
+		}
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	s2, err := Open(root)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer s2.Close()
+	rep, err = s2.Fsck(false)
+	if err != nil {
+		t.Fatalf("Fsck after repair: %v", err)
+	}
+	if len(rep.Issues) != 0 {
+		t.Fatalf("issues after repair: %+v", rep.Issues)
+	}
+	if rep.LiveNotes != 2 {
+		t.Fatalf("LiveNotes = %d, want 2", rep.LiveNotes)
+	}
+}
+
+func TestFsckCacheStaleMeta(t *testing.T) {
+	s, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer s.Close()
+
+	n := model.NewNote("One", "first", nil)
+	n.Meta = map[string]any{"status": "done"}
+	if err := s.Append(n); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	cur, err := s.GetByID(n.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+
+	for _, stale := range []model.Note{
+		func() model.Note { c := *cur; c.Meta = map[string]any{"status": "open"}; return c }(),
+		func() model.Note { c := *cur; c.Version++; return c }(),
+	} {
+		noteCache.Add(n.ID, &stale)
+		s.saveCacheToDisk()
+		rep, err := s.Fsck(false)
+		if err != nil {
+			t.Fatalf("Fsck: %v", err)
+		}
+		if issueKinds(rep)["cache_stale"] != 1 {
+			t.Fatalf("stale copy %+v not reported: %+v", stale, rep.Issues)
+		}
+	}
+}
+
+func TestFsckReportsTornTail(t *testing.T) {
+	root := t.TempDir()
+	s, err := Open(root)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if err := s.Append(model.NewNote("Title", "Body", nil)); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	seg := s.segmentPath(s.activeNo)
+	_ = s.Close()
+	torn, err := encodeRecord(model.NewNote("Torn", "never finished", nil))
+	if err != nil {
+		t.Fatalf("encodeRecord: %v", err)
+	}
+	f, err := os.OpenFile(seg, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	if _, err := f.Write(torn[:len(torn)/2]); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	_ = f.Close()
+
+	// Хвост отрезает уже Open, поэтому и проверка без repair сообщает
+	// о нём как об исправленной проблеме, и больше ничего не находит.
+	s, err = Open(root)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer s.Close()
+	rep, err := s.Fsck(false)
+	if err != nil {
+		t.Fatalf("Fsck: %v", err)
+	}
+	if len(rep.Issues) != 1 || rep.Issues[0].Kind != "torn_tail" || !rep.Issues[0].Repaired {
+		t.Fatalf("Fsck issues = %+v, want one repaired torn_tail", rep.Issues)
+	}
+	if !strings.Contains(rep.Issues[0].Detail, "truncated on open") || rep.Unrepaired() != 0 {
+		t.Fatalf("torn_tail issue = %+v", rep.Issues[0])
+	}
+}
@@ -0,0 +1,116 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/model"
+)
+
+var (
+	ErrNoSuchVersion = errors.New("no such version")
+	ErrNotDeleted    = errors.New("note is not deleted, specify a version")
+)
+
+// History возвращает все версии заметки в порядке записи в лог, включая
+// tombstone. Версии, убранные командой compact, уже недоступны.
+func (s *Store) History(id string) ([]model.Note, error) {
+	e, ok := s.idx.Entries[id]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	var out []model.Note
+	for _, path := range s.segmentFiles() {
+		no, err := parseSeqFromName(filepath.Base(path))
+		if err != nil {
+			continue
+		}
+		if no > e.Segment {
+			break
+		}
+		err = scanSegment(path, func(_ int64, _ int, n model.Note) error {
+			if n.ID == id {
+				out = append(out, n)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(out) == 0 {
+		return nil, ErrNotFound
+	}
+	return out, nil
+}
+
+// VersionNumber возвращает номер i-й версии из History: Version записи,
+// а у записей без номера — её позицию, считая с 1.
+func VersionNumber(hist []model.Note, i int) int {
+	if hist[i].Version > 0 {
+		return hist[i].Version
+	}
+	return i + 1
+}
+
+// Restore дописывает новую версию заметки, скопированную из версии с
+// номером version (см. VersionNumber). При version == 0 берётся последняя
+// неудалённая версия — так удалённую заметку можно вернуть.
+func (s *Store) Restore(id string, version int) (*model.Note, error) {
+	hist, err := s.History(id)
+	if err != nil {
+		return nil, err
+	}
+
+	if version == 0 {
+		if !hist[len(hist)-1].Deleted {
+			return nil, ErrNotDeleted
+		}
+		for i := len(hist) - 1; i >= 0; i-- {
+			if !hist[i].Deleted {
+				return s.restoreFrom(hist[i])
+			}
+		}
+		return nil, ErrNoSuchVersion
+	}
+
+	for i := range hist {
+		if VersionNumber(hist, i) != version {
+			continue
+		}
+		if hist[i].Deleted {
+			return nil, fmt.Errorf("%w: version %d is a deletion", ErrNoSuchVersion, version)
+		}
+		return s.restoreFrom(hist[i])
+	}
+	return nil, fmt.Errorf("%w: %d (have %d..%d)", ErrNoSuchVersion, version, VersionNumber(hist, 0), VersionNumber(hist, len(hist)-1))
+}
+
+// RestoreAt восстанавливает последнюю неудалённую версию, записанную не
+// позже момента at.
+func (s *Store) RestoreAt(id string, at time.Time) (*model.Note, error) {
+	hist, err := s.History(id)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(hist) - 1; i >= 0; i-- {
+		if !hist[i].Deleted && !hist[i].UpdatedAt.After(at) {
+			return s.restoreFrom(hist[i])
+		}
+	}
+	return nil, fmt.Errorf("%w: nothing before %s", ErrNoSuchVersion, at.Format(time.RFC3339))
+}
+
+func (s *Store) restoreFrom(src model.Note) (*model.Note, error) {
+	n := src
+	n.Tags = append([]string(nil), src.Tags...)
+	n.Deleted = false
+	n.UpdatedAt = time.Now().UTC()
+	if err := s.Append(&n); err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
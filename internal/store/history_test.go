@@ -0,0 +1,120 @@
+package store
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/model"
+)
+
+func TestHistoryReturnsAllVersionsInOrder(t *testing.T) {
+	root := t.TempDir()
+	s, err := Open(root)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer s.Close()
+
+	n := model.NewNote("Title", "v1", nil)
+	other := model.NewNote("Other", "x", nil)
+	if err := s.Append(n); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if err := s.Append(other); err != nil {
+		t.Fatalf("Append other: %v", err)
+	}
+	v2 := *n
+	v2.Text = "v2"
+	if err := s.Append(&v2); err != nil {
+		t.Fatalf("Append v2: %v", err)
+	}
+	tomb := v2
+	tomb.Deleted = true
+	if err := s.Append(&tomb); err != nil {
+		t.Fatalf("Append tombstone: %v", err)
+	}
+
+	hist, err := s.History(n.ID)
+	if err != nil {
+		t.Fatalf("History: %v", err)
+	}
+	if len(hist) != 3 {
+		t.Fatalf("History returned %d versions, want 3", len(hist))
+	}
+	if hist[0].Text != "v1" || hist[1].Text != "v2" || !hist[2].Deleted {
+		t.Fatalf("unexpected history: %+v", hist)
+	}
+
+	if _, err := s.History("missing"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("History(missing) = %v, want ErrNotFound", err)
+	}
+}
+
+func TestRestoreUndeleteAndVersion(t *testing.T) {
+	root := t.TempDir()
+	s, err := Open(root)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer s.Close()
+
+	n := model.NewNote("Title", "v1", []string{"a"})
+	if err := s.Append(n); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+
+	if _, err := s.Restore(n.ID, 0); !errors.Is(err, ErrNotDeleted) {
+		t.Fatalf("Restore of live note = %v, want ErrNotDeleted", err)
+	}
+
+	v2 := *n
+	v2.Text = "v2"
+	v2.UpdatedAt = n.UpdatedAt.Add(time.Hour)
+	if err := s.Append(&v2); err != nil {
+		t.Fatalf("Append v2: %v", err)
+	}
+	tomb := v2
+	tomb.Deleted = true
+	if err := s.Append(&tomb); err != nil {
+		t.Fatalf("Append tombstone: %v", err)
+	}
+
+	got, err := s.Restore(n.ID, 0)
+	if err != nil {
+		t.Fatalf("Restore undelete: %v", err)
+	}
+	if got.Text != "v2" {
+		t.Fatalf("undelete restored %q, want %q", got.Text, "v2")
+	}
+	if cur, err := s.GetByID(n.ID); err != nil || cur.Text != "v2" {
+		t.Fatalf("GetByID after undelete = %+v, %v", cur, err)
+	}
+
+	if _, err := s.Restore(n.ID, 1); err != nil {
+		t.Fatalf("Restore version 1: %v", err)
+	}
+	if cur, _ := s.GetByID(n.ID); cur.Text != "v1" {
+		t.Fatalf("after restore of version 1 text = %q, want %q", cur.Text, "v1")
+	}
+
+	if _, err := s.Restore(n.ID, 3); !errors.Is(err, ErrNoSuchVersion) {
+		t.Fatalf("Restore of tombstone version = %v, want ErrNoSuchVersion", err)
+	}
+	if _, err := s.Restore(n.ID, 42); !errors.Is(err, ErrNoSuchVersion) {
+		t.Fatalf("Restore(42) = %v, want ErrNoSuchVersion", err)
+	}
+
+	got, err = s.RestoreAt(n.ID, n.UpdatedAt.Add(30*time.Minute))
+	if err != nil {
+		t.Fatalf("RestoreAt: %v", err)
+	}
+	if got.Text != "v1" {
+		t.Fatalf("RestoreAt restored %q, want %q", got.Text, "v1")
+	}
+
+	hist, _ := s.History(n.ID)
+	if len(hist) != 6 {
+		t.Fatalf("history has %d versions, want 6", len(hist))
+	}
+}
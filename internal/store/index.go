@@ -0,0 +1,303 @@
+package store
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/model"
+)
+
+const (
+	filenameIDIndex = "id_index.json"
+	idIndexVersion  = 2
+)
+
+// indexEntry указывает на последнюю запись заметки в логе. Времена
+// создания и изменения (UnixNano) нужны фильтрам по дате, чтобы не
+// читать сегменты.
+type indexEntry struct {
+	Segment int   `json:"seg"`
+	Offset  int64 `json:"off"`
+	Length  int   `json:"len"`
+	Version int   `json:"ver"`
+	Deleted bool  `json:"del,omitempty"`
+	Created int64 `json:"ct"`
+	Updated int64 `json:"ut"`
+}
+
+// idIndex — первичный индекс ID -> положение записи. Segments хранит,
+// сколько байт каждого сегмента уже учтено: по нему Open понимает,
+// нужно ли дочитать хвост или перестроить индекс целиком.
+type idIndex struct {
+	Version  int                   `json:"version"`
+	Segments map[int]int64         `json:"segments"`
+	Entries  map[string]indexEntry `json:"entries"`
+
+	byCreated, byUpdated *timeOrder
+}
+
+func newIDIndex() *idIndex {
+	return &idIndex{
+		Version:  idIndexVersion,
+		Segments: make(map[int]int64),
+		Entries:  make(map[string]indexEntry),
+	}
+}
+
+func (x *idIndex) apply(seg int, off int64, size int, n model.Note) {
+	// У записей старых версий программы номера нет: считаем записи.
+	ver := n.Version
+	if ver == 0 {
+		ver = x.Entries[n.ID].Version + 1
+	}
+	x.Entries[n.ID] = indexEntry{
+		Segment: seg,
+		Offset:  off,
+		Length:  size,
+		Version: ver,
+		Deleted: n.Deleted,
+		Created: n.CreatedAt.UnixNano(),
+		Updated: n.UpdatedAt.UnixNano(),
+	}
+	x.byCreated, x.byUpdated = nil, nil
+	if end := off + int64(size); end > x.Segments[seg] {
+		x.Segments[seg] = end
+	}
+}
+
+// timeOrder — ID живых заметок, упорядоченные по времени создания или
+// изменения. Строится по idIndex при первом запросе с датой и
+// сбрасывается при любом изменении индекса.
+type timeOrder struct {
+	at  []int64
+	ids []string
+}
+
+func (x *idIndex) order(updated bool) *timeOrder {
+	o := &x.byCreated
+	if updated {
+		o = &x.byUpdated
+	}
+	if *o != nil {
+		return *o
+	}
+	ids := make([]string, 0, len(x.Entries))
+	for id, e := range x.Entries {
+		if !e.Deleted {
+			ids = append(ids, id)
+		}
+	}
+	key := func(id string) int64 {
+		if updated {
+			return x.Entries[id].Updated
+		}
+		return x.Entries[id].Created
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		a, b := key(ids[i]), key(ids[j])
+		if a != b {
+			return a < b
+		}
+		return ids[i] < ids[j]
+	})
+	at := make([]int64, len(ids))
+	for i, id := range ids {
+		at[i] = key(id)
+	}
+	*o = &timeOrder{at: at, ids: ids}
+	return *o
+}
+
+// between возвращает ID заметок со временем в [lo, hi].
+func (o *timeOrder) between(lo, hi int64) []string {
+	i := sort.Search(len(o.at), func(k int) bool { return o.at[k] >= lo })
+	j := sort.Search(len(o.at), func(k int) bool { return o.at[k] > hi })
+	if i >= j {
+		return nil
+	}
+	return o.ids[i:j]
+}
+
+func (s *Store) loadIDIndex() {
+	s.idx = nil
+	b, err := os.ReadFile(filepath.Join(s.root, filenameIDIndex))
+	if err == nil {
+		var x idIndex
+		if json.Unmarshal(b, &x) == nil && x.Version == idIndexVersion && x.Entries != nil && x.Segments != nil {
+			s.idx = &x
+		}
+	}
+	if s.idx == nil {
+		s.idx = newIDIndex()
+		s.idxDirty = true
+	}
+}
+
+// syncIDIndex сверяет индекс с сегментами на диске. Если в индексе не
+// хватает только хвоста (например, процесс упал до Close), хвост
+// дочитывается; при любом другом расхождении индекс строится заново.
+func (s *Store) syncIDIndex() error {
+	files := s.segmentFiles()
+	onDisk := make(map[int]bool, len(files))
+
+	type tail struct {
+		no   int
+		from int64
+	}
+	var tails []tail
+	stale := false
+
+	for _, path := range files {
+		no, err := parseSeqFromName(filepath.Base(path))
+		if err != nil {
+			continue
+		}
+		onDisk[no] = true
+
+		st, err := os.Stat(path)
+		if err != nil {
+			return err
+		}
+		covered := s.idx.Segments[no]
+		switch {
+		case covered > st.Size():
+			stale = true
+		case covered < st.Size():
+			// Дочитывать можно только последние сегменты: если за отстающим
+			// сегментом идёт уже учтённый, порядок версий был бы нарушен.
+			if len(tails) > 0 && covered != 0 {
+				stale = true
+			}
+			tails = append(tails, tail{no: no, from: covered})
+		case len(tails) > 0 && st.Size() > 0:
+			stale = true
+		}
+	}
+	for no := range s.idx.Segments {
+		if !onDisk[no] {
+			stale = true
+		}
+	}
+
+	if stale {
+		return s.rebuildIDIndex()
+	}
+	for _, t := range tails {
+		err := scanSegmentFrom(s.segmentPath(t.no), t.from, func(off int64, size int, n model.Note) error {
+			s.idx.apply(t.no, off, size, n)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if st, err := os.Stat(s.segmentPath(t.no)); err == nil {
+			s.idx.Segments[t.no] = st.Size()
+		}
+		s.idxDirty = true
+	}
+	return nil
+}
+
+func (s *Store) rebuildIDIndex() error {
+	x := newIDIndex()
+	for _, path := range s.segmentFiles() {
+		no, err := parseSeqFromName(filepath.Base(path))
+		if err != nil {
+			continue
+		}
+		err = scanSegment(path, func(off int64, size int, n model.Note) error {
+			x.apply(no, off, size, n)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+		if st, err := os.Stat(path); err == nil {
+			x.Segments[no] = st.Size()
+		}
+	}
+	s.idx = x
+	s.idxDirty = true
+	return nil
+}
+
+func (s *Store) saveIDIndex() error {
+	if s.idx == nil || !s.idxDirty {
+		return nil
+	}
+	b, err := json.Marshal(s.idx)
+	if err != nil {
+		return err
+	}
+	if err := writeFileAtomic(filepath.Join(s.root, filenameIDIndex), b); err != nil {
+		return err
+	}
+	s.idxDirty = false
+	return nil
+}
+
+func (s *Store) segmentReader(no int) (*os.File, error) {
+	if f, ok := s.readers[no]; ok {
+		return f, nil
+	}
+	f, err := os.Open(s.segmentPath(no))
+	if err != nil {
+		return nil, err
+	}
+	if s.readers == nil {
+		s.readers = make(map[int]*os.File)
+	}
+	s.readers[no] = f
+	return f, nil
+}
+
+func (s *Store) closeReaders() {
+	for no, f := range s.readers {
+		_ = f.Close()
+		delete(s.readers, no)
+	}
+}
+
+func (s *Store) readEntry(e indexEntry) (model.Note, error) {
+	f, err := s.segmentReader(e.Segment)
+	if err != nil {
+		return model.Note{}, err
+	}
+	buf := make([]byte, e.Length)
+	if _, err := f.ReadAt(buf, e.Offset); err != nil {
+		return model.Note{}, fmt.Errorf("read segment %d at %d: %w", e.Segment, e.Offset, err)
+	}
+	n, err := decodeRecord(buf)
+	if err != nil {
+		return model.Note{}, fmt.Errorf("decode segment %d at %d: %w", e.Segment, e.Offset, err)
+	}
+	if n.Version == 0 {
+		n.Version = e.Version
+	}
+	return n, nil
+}
+
+func (s *Store) lookup(id string) (model.Note, error) {
+	e, ok := s.idx.Entries[id]
+	if !ok || e.Deleted {
+		return model.Note{}, ErrNotFound
+	}
+	n, err := s.readEntry(e)
+	if err != nil {
+		return model.Note{}, err
+	}
+	if n.ID != id {
+		return model.Note{}, errors.New("id index points to a foreign record, rebuild required")
+	}
+	return n, nil
+}
+
+// Exists сообщает, есть ли живая заметка с таким ID, не читая сегменты.
+func (s *Store) Exists(id string) bool {
+	e, ok := s.idx.Entries[id]
+	return ok && !e.Deleted
+}
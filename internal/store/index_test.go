@@ -0,0 +1,124 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/model"
+)
+
+func TestIDIndexPersistedAndUsedAfterReopen(t *testing.T) {
+	root := t.TempDir()
+	s, err := Open(root)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	n := model.NewNote("Title", "Body", nil)
+	if err := s.Append(n); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	upd := *n
+	upd.Text = "Body v2"
+	if err := s.Append(&upd); err != nil {
+		t.Fatalf("Append update: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	if _, err := os.Stat(filepath.Join(root, filenameIDIndex)); err != nil {
+		t.Fatalf("id index not written: %v", err)
+	}
+
+	s2, err := Open(root)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer s2.Close()
+	noteCache.Clear()
+
+	e, ok := s2.idx.Entries[n.ID]
+	if !ok {
+		t.Fatalf("id index has no entry for %s", n.ID)
+	}
+	if e.Version != 2 {
+		t.Fatalf("entry version = %d, want 2", e.Version)
+	}
+
+	got, err := s2.GetByID(n.ID)
+	if err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if got.Text != "Body v2" {
+		t.Fatalf("Text = %q, want %q", got.Text, "Body v2")
+	}
+}
+
+func TestIDIndexCatchesUpAndRebuilds(t *testing.T) {
+	root := t.TempDir()
+	s, err := Open(root)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	n1 := model.NewNote("First", "one", nil)
+	if err := s.Append(n1); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	seg := s.segmentPath(s.activeNo)
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// Запись, которой нет в индексе: как будто процесс упал до Close.
+	n2 := model.NewNote("Second", "two", nil)
+	b, err := encodeRecord(n2)
+	if err != nil {
+		t.Fatalf("encodeRecord: %v", err)
+	}
+	f, err := os.OpenFile(seg, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	if _, err := f.Write(b); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	_ = f.Close()
+
+	s2, err := Open(root)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	noteCache.Clear()
+	if _, err := s2.GetByID(n2.ID); err != nil {
+		t.Fatalf("GetByID after catch-up: %v", err)
+	}
+	if err := s2.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// Индекс ссылается на несуществующий сегмент — должен перестроиться.
+	s2.idx.Segments[99] = 10
+	s2.idxDirty = true
+	if err := s2.saveIDIndex(); err != nil {
+		t.Fatalf("saveIDIndex: %v", err)
+	}
+
+	s3, err := Open(root)
+	if err != nil {
+		t.Fatalf("reopen after corruption: %v", err)
+	}
+	defer s3.Close()
+	noteCache.Clear()
+	if _, ok := s3.idx.Segments[99]; ok {
+		t.Fatalf("stale segment kept in rebuilt index")
+	}
+	list, err := s3.List(Filter{})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(list) != 2 {
+		t.Fatalf("List returned %d notes, want 2", len(list))
+	}
+}
@@ -0,0 +1,141 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	filenameLock       = "noteline.lock"
+	defaultLockTimeout = 5 * time.Second
+	lockPollInterval   = 50 * time.Millisecond
+)
+
+type LockMode int
+
+const (
+	// LockExclusive — для команд, которые пишут в хранилище.
+	LockExclusive LockMode = iota
+	// LockShared — для команд, которые только читают; таких держателей
+	// может быть несколько одновременно.
+	LockShared
+)
+
+func (m LockMode) String() string {
+	if m == LockShared {
+		return "shared"
+	}
+	return "exclusive"
+}
+
+type Options struct {
+	Lock LockMode
+	// LockTimeout — сколько ждать, пока блокировку отпустит другой процесс.
+	// 0 — взять значение из NOTELINE_LOCK_TIMEOUT (по умолчанию 5s),
+	// отрицательное значение — не ждать вовсе.
+	LockTimeout time.Duration
+}
+
+func (o Options) lockTimeout() time.Duration {
+	if o.LockTimeout != 0 {
+		return max(o.LockTimeout, 0)
+	}
+	if v := strings.TrimSpace(os.Getenv("NOTELINE_LOCK_TIMEOUT")); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			return max(d, 0)
+		}
+	}
+	return defaultLockTimeout
+}
+
+var (
+	ErrLocked   = errors.New("store is locked by another process")
+	ErrReadOnly = errors.New("store is opened read-only")
+)
+
+// LockedError возвращается, если блокировку не удалось взять за отведённое
+// время. PID известен, только когда хранилище держат эксклюзивно.
+type LockedError struct {
+	Path string
+	Mode LockMode
+	PID  int
+}
+
+func (e *LockedError) Error() string {
+	if e.PID > 0 {
+		return fmt.Sprintf("cannot take %s lock %s: held by PID %d", e.Mode, e.Path, e.PID)
+	}
+	return fmt.Sprintf("cannot take %s lock %s: held by another process", e.Mode, e.Path)
+}
+
+func (e *LockedError) Unwrap() error { return ErrLocked }
+
+type fileLock struct {
+	f    *os.File
+	mode LockMode
+}
+
+func acquireLock(root string, mode LockMode, timeout time.Duration) (*fileLock, error) {
+	path := filepath.Join(root, filenameLock)
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		ok, err := tryLockFile(f, mode == LockExclusive)
+		if err != nil {
+			_ = f.Close()
+			return nil, fmt.Errorf("lock %s: %w", path, err)
+		}
+		if ok {
+			break
+		}
+		if !time.Now().Before(deadline) {
+			_ = f.Close()
+			return nil, &LockedError{Path: path, Mode: mode, PID: readLockPID(path)}
+		}
+		time.Sleep(lockPollInterval)
+	}
+
+	// Эксклюзивный держатель записывает свой PID, чтобы ожидающий процесс
+	// мог назвать его в сообщении об ошибке.
+	if mode == LockExclusive {
+		_ = f.Truncate(0)
+		_, _ = f.WriteAt([]byte(strconv.Itoa(os.Getpid())+"\n"), 0)
+	}
+	return &fileLock{f: f, mode: mode}, nil
+}
+
+func (l *fileLock) release() error {
+	if l == nil || l.f == nil {
+		return nil
+	}
+	if l.mode == LockExclusive {
+		_ = l.f.Truncate(0)
+	}
+	err := unlockFile(l.f)
+	if cerr := l.f.Close(); err == nil {
+		err = cerr
+	}
+	l.f = nil
+	return err
+}
+
+func readLockPID(path string) int {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		return 0
+	}
+	return pid
+}
@@ -0,0 +1,120 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/model"
+)
+
+func TestExclusiveLockNamesHolder(t *testing.T) {
+	root := t.TempDir()
+	s, err := Open(root)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	_, err = OpenWith(root, Options{LockTimeout: -1})
+	var le *LockedError
+	if !errors.As(err, &le) || !errors.Is(err, ErrLocked) {
+		t.Fatalf("second Open error = %v, want LockedError", err)
+	}
+	if le.PID != os.Getpid() {
+		t.Fatalf("LockedError.PID = %d, want %d", le.PID, os.Getpid())
+	}
+	if _, err := OpenWith(root, Options{Lock: LockShared, LockTimeout: -1}); !errors.Is(err, ErrLocked) {
+		t.Fatalf("shared Open under exclusive lock: %v, want ErrLocked", err)
+	}
+
+	// Ожидающий процесс должен дождаться, пока держатель отпустит блокировку.
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		_ = s.Close()
+	}()
+	s2, err := OpenWith(root, Options{LockTimeout: 5 * time.Second})
+	if err != nil {
+		t.Fatalf("Open after release: %v", err)
+	}
+	if err := s2.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+}
+
+func TestSharedLockIsReadOnly(t *testing.T) {
+	root := t.TempDir()
+	s, err := Open(root)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	n := model.NewNote("Title", "Body", nil)
+	if err := s.Append(n); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	r1, err := OpenWith(root, Options{Lock: LockShared, LockTimeout: -1})
+	if err != nil {
+		t.Fatalf("first shared Open: %v", err)
+	}
+	r2, err := OpenWith(root, Options{Lock: LockShared, LockTimeout: -1})
+	if err != nil {
+		t.Fatalf("second shared Open: %v", err)
+	}
+	if !r1.ReadOnly() {
+		t.Fatalf("shared store is not read-only")
+	}
+	if _, err := r2.GetByID(n.ID); err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+	if err := r2.Append(model.NewNote("x", "y", nil)); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("Append on shared store: %v, want ErrReadOnly", err)
+	}
+	if _, err := OpenWith(root, Options{LockTimeout: -1}); !errors.Is(err, ErrLocked) {
+		t.Fatalf("exclusive Open under shared locks: %v, want ErrLocked", err)
+	}
+	_ = r1.Close()
+	_ = r2.Close()
+}
+
+func TestSharedOpenRecoversTornTail(t *testing.T) {
+	root := t.TempDir()
+	s, err := Open(root)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	n := model.NewNote("Title", "Body", nil)
+	if err := s.Append(n); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	seg := s.segmentPath(s.activeNo)
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	st, _ := os.Stat(seg)
+	f, err := os.OpenFile(seg, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	_, _ = f.Write([]byte("0000000a 99 {\"id\":"))
+	_ = f.Close()
+
+	r, err := OpenWith(root, Options{Lock: LockShared, LockTimeout: -1})
+	if err != nil {
+		t.Fatalf("shared Open: %v", err)
+	}
+	defer r.Close()
+	if !r.ReadOnly() {
+		t.Fatalf("store is not read-only after maintenance")
+	}
+	after, _ := os.Stat(seg)
+	if after.Size() != st.Size() {
+		t.Fatalf("segment size = %d, want %d after recovery", after.Size(), st.Size())
+	}
+	if _, err := r.GetByID(n.ID); err != nil {
+		t.Fatalf("GetByID: %v", err)
+	}
+}
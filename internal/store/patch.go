@@ -0,0 +1,60 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"reflect"
+	"slices"
+	"time"
+
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/model"
+)
+
+// ErrConflict — заметка успела получить новую версию, пока её меняли.
+var ErrConflict = errors.New("version conflict")
+
+// CheckVersion возвращает ErrConflict, если версия заметки не want.
+// Вызванная внутри функции Patch, проверка атомарна: запись идёт под
+// эксклюзивной блокировкой.
+func CheckVersion(n *model.Note, want int) error {
+	if n.Version != want {
+		return fmt.Errorf("%w: note %s is at version %d, expected %d", ErrConflict, n.ID, n.Version, want)
+	}
+	return nil
+}
+
+// Patch читает текущую версию заметки, даёт fn изменить её копию и
+// дописывает результат новой версией. ID и время создания fn поменять не
+// может, UpdatedAt выставляется заново. Если fn вернул ошибку, ничего не
+// пишется; если заголовок, текст, теги и Meta не изменились — тоже, и
+// возвращается текущая версия.
+func (s *Store) Patch(id string, fn func(n *model.Note) error) (*model.Note, error) {
+	if s.readOnly {
+		return nil, ErrReadOnly
+	}
+	old, err := s.GetByID(id)
+	if err != nil {
+		return nil, err
+	}
+
+	n := *old
+	n.Tags = slices.Clone(old.Tags)
+	n.Meta = maps.Clone(old.Meta)
+	if err := fn(&n); err != nil {
+		return nil, err
+	}
+	if n.Meta, err = model.NormalizeMeta(n.Meta); err != nil {
+		return nil, err
+	}
+	if n.Title == old.Title && n.Text == old.Text && slices.Equal(n.Tags, old.Tags) && reflect.DeepEqual(n.Meta, old.Meta) {
+		return old, nil
+	}
+
+	n.ID, n.CreatedAt, n.Deleted = old.ID, old.CreatedAt, false
+	n.UpdatedAt = time.Now().UTC()
+	if err := s.Append(&n); err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
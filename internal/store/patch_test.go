@@ -0,0 +1,118 @@
+package store
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/model"
+)
+
+func TestPatch(t *testing.T) {
+	s, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer s.Close()
+
+	n := model.NewNote("Title", "body", []string{"go"})
+	if err := s.Append(n); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+
+	got, err := s.Patch(n.ID, func(p *model.Note) error {
+		p.Tags = append(p.Tags, "cli")
+		p.ID = "other"
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("Patch: %v", err)
+	}
+	if got.ID != n.ID || got.Title != "Title" || got.Text != "body" || !reflect.DeepEqual(got.Tags, []string{"go", "cli"}) {
+		t.Fatalf("Patch = %+v", got)
+	}
+	if !got.CreatedAt.Equal(n.CreatedAt) || got.UpdatedAt.Before(n.UpdatedAt) {
+		t.Fatalf("Patch times: created %v, updated %v", got.CreatedAt, got.UpdatedAt)
+	}
+	if cur, err := s.GetByID(n.ID); err != nil || !reflect.DeepEqual(cur.Tags, got.Tags) {
+		t.Fatalf("GetByID after Patch = %+v, %v", cur, err)
+	}
+
+	// Без изменений и при ошибке fn новая версия не пишется.
+	if _, err := s.Patch(n.ID, func(*model.Note) error { return nil }); err != nil {
+		t.Fatalf("Patch without changes: %v", err)
+	}
+	boom := errors.New("boom")
+	if _, err := s.Patch(n.ID, func(p *model.Note) error { p.Text = "x"; return boom }); !errors.Is(err, boom) {
+		t.Fatalf("Patch error = %v", err)
+	}
+	if hist, err := s.History(n.ID); err != nil || len(hist) != 2 {
+		t.Fatalf("History = %d versions, %v", len(hist), err)
+	}
+
+	if _, err := s.Patch("missing", func(*model.Note) error { return nil }); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Patch missing = %v", err)
+	}
+}
+
+func TestVersions(t *testing.T) {
+	root := t.TempDir()
+	s, err := Open(root)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	s.man.SegmentSizeBytes = 256
+
+	n := model.NewNote("Title", "v1", nil)
+	for i := 1; i <= 3; i++ {
+		n.Text = strings.Repeat("v", 40*i)
+		if err := s.Append(n); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+		if n.Version != i {
+			t.Fatalf("Version after append %d = %d", i, n.Version)
+		}
+	}
+
+	got, err := s.Patch(n.ID, func(p *model.Note) error { return CheckVersion(p, 2) })
+	if !errors.Is(err, ErrConflict) || got != nil {
+		t.Fatalf("Patch with stale version = %v, %v", got, err)
+	}
+	got, err = s.Patch(n.ID, func(p *model.Note) error {
+		if err := CheckVersion(p, 3); err != nil {
+			return err
+		}
+		p.Title = "New"
+		return nil
+	})
+	if err != nil || got.Version != 4 {
+		t.Fatalf("Patch = %+v, %v", got, err)
+	}
+
+	// Номера переживают сжатие и перестроение индекса.
+	if _, err := s.Compact(); err != nil {
+		t.Fatalf("Compact: %v", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	if err := os.Remove(filepath.Join(root, filenameIDIndex)); err != nil {
+		t.Fatalf("Remove id index: %v", err)
+	}
+	s, err = Open(root)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer s.Close()
+	cur, err := s.GetByID(n.ID)
+	if err != nil || cur.Version != 4 {
+		t.Fatalf("GetByID after compact = %+v, %v", cur, err)
+	}
+	next := *cur
+	if err := s.Append(&next); err != nil || next.Version != 5 {
+		t.Fatalf("Append after compact: version %d, %v", next.Version, err)
+	}
+}
@@ -0,0 +1,630 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strings"
+	"time"
+	"unicode"
+
+	fts "github.com/Victor3563/NoteLine/cli-notebook/internal/fulltext"
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/model"
+)
+
+// Язык запросов list и search:
+//
+//	deploy                 слово в заголовке, тексте или тегах
+//	"exact phrase"         точная фраза
+//	title:deploy           слово только в заголовке (также text:, title:"…")
+//	tag:work               заметка с тегом work или work/… на любой глубине
+//	created:>2025-01-01    дата создания; операторы > >= < <= =, по
+//	updated:>=7d           умолчанию =, диапазон a..b; форматы — как в
+//	                       ParseTimeBound, неполная дата означает весь
+//	                       день (месяц, год) в местном часовом поясе
+//	meta.status:open       поле метаданных заметки (Note.Meta): значение
+//	meta.points:>=3        без учёта регистра, у списка — любой элемент;
+//	meta.due:*             операторы > >= < <= сравнивают числа как числа,
+//	                       остальное — как строки; * — поле задано
+//	-tag:archived, NOT x   отрицание
+//	a OR b                 любое из условий
+//	a b, a AND b           все условия
+//	( … )                  группировка
+//
+// OR связывает сильнее, чем неявное AND: `tag:work deploy OR incident`
+// означает tag:work AND (deploy OR incident). Текстовые условия выполняет
+// bleve, теги и даты проверяются по метаданным заметок.
+
+var ErrBadQuery = errors.New("invalid query")
+
+type queryOp int
+
+const (
+	opAnd queryOp = iota
+	opOr
+	opNot
+	opText // слова или фраза, ищутся в полнотекстовом индексе
+	opTag  // тег или любой его потомок в иерархии a/b/c
+	opDate // сравнение created/updated с интервалом [from, to)
+	opMeta // поле Meta: сравнение cmp с value, пустой cmp — поле задано
+)
+
+type queryNode struct {
+	op   queryOp
+	kids []*queryNode
+
+	match fts.Match // opText
+	value string    // opTag, opMeta, исходное значение opDate
+
+	field    string // opDate: created или updated; opMeta: ключ
+	from, to time.Time
+	cmp      string
+}
+
+// String печатает дерево в каноническом виде; используется в тестах
+// и сообщениях об ошибках.
+func (n *queryNode) String() string {
+	switch n.op {
+	case opAnd, opOr:
+		name := "and"
+		if n.op == opOr {
+			name = "or"
+		}
+		parts := make([]string, len(n.kids))
+		for i, k := range n.kids {
+			parts[i] = k.String()
+		}
+		return "(" + name + " " + strings.Join(parts, " ") + ")"
+	case opNot:
+		return "(not " + n.kids[0].String() + ")"
+	case opText:
+		v := n.match.Text
+		if n.match.Phrase {
+			v = `"` + v + `"`
+		}
+		if n.match.Field != "" {
+			return n.match.Field + ":" + v
+		}
+		return v
+	case opTag:
+		return "tag:" + n.value
+	case opDate:
+		return n.field + ":" + n.cmp + n.value
+	case opMeta:
+		if n.cmp == "" {
+			return metaField + n.field + ":*"
+		}
+		return metaField + n.field + ":" + n.cmp + n.value
+	}
+	return "?"
+}
+
+type tokKind int
+
+const (
+	tokWord tokKind = iota
+	tokPhrase
+	tokLParen
+	tokRParen
+	tokNot
+	tokOr
+	tokAnd
+)
+
+type queryToken struct {
+	kind  tokKind
+	field string
+	text  string
+	pos   int
+}
+
+func tokenizeQuery(s string) ([]queryToken, error) {
+	rs := []rune(s)
+	var out []queryToken
+	i := 0
+	for i < len(rs) {
+		r := rs[i]
+		switch {
+		case unicode.IsSpace(r):
+			i++
+			continue
+		case r == '(':
+			out = append(out, queryToken{kind: tokLParen, pos: i})
+			i++
+			continue
+		case r == ')':
+			out = append(out, queryToken{kind: tokRParen, pos: i})
+			i++
+			continue
+		case r == '-' && i+1 < len(rs) && !unicode.IsSpace(rs[i+1]) && rs[i+1] != ')':
+			out = append(out, queryToken{kind: tokNot, pos: i})
+			i++
+			continue
+		}
+
+		start := i
+		field := ""
+		for i < len(rs) && !unicode.IsSpace(rs[i]) && rs[i] != '(' && rs[i] != ')' && rs[i] != '"' {
+			if rs[i] == ':' && field == "" && i > start && isFieldName(rs[start:i]) {
+				field = strings.ToLower(string(rs[start:i]))
+				i++
+				start = i
+				continue
+			}
+			i++
+		}
+		word := string(rs[start:i])
+
+		if word == "" {
+			if i >= len(rs) || rs[i] != '"' {
+				return nil, fmt.Errorf("%w: empty value for %s: at position %d", ErrBadQuery, field, start)
+			}
+			j := i + 1
+			for j < len(rs) && rs[j] != '"' {
+				j++
+			}
+			if j >= len(rs) {
+				return nil, fmt.Errorf("%w: unterminated quote at position %d", ErrBadQuery, i+1)
+			}
+			phrase := strings.TrimSpace(string(rs[i+1 : j]))
+			i = j + 1
+			if phrase == "" {
+				continue
+			}
+			out = append(out, queryToken{kind: tokPhrase, field: field, text: phrase, pos: start})
+			continue
+		}
+
+		tok := queryToken{kind: tokWord, field: field, text: word, pos: start}
+		if field == "" {
+			switch word {
+			case "OR":
+				tok.kind = tokOr
+			case "AND":
+				tok.kind = tokAnd
+			case "NOT":
+				tok.kind = tokNot
+			}
+		}
+		out = append(out, tok)
+	}
+	return out, nil
+}
+
+func isFieldName(rs []rune) bool {
+	if name := strings.ToLower(string(rs)); strings.HasPrefix(name, metaField) && len(name) > len(metaField) {
+		_, err := model.CleanMetaKey(name[len(metaField):])
+		return err == nil
+	}
+	for _, r := range rs {
+		if !unicode.IsLetter(r) {
+			return false
+		}
+	}
+	return true
+}
+
+type queryParser struct {
+	toks []queryToken
+	pos  int
+	now  time.Time
+}
+
+// parseQuery разбирает запрос в дерево. Пустой запрос даёт nil.
+func parseQuery(s string, now time.Time) (*queryNode, error) {
+	toks, err := tokenizeQuery(s)
+	if err != nil {
+		return nil, err
+	}
+	if len(toks) == 0 {
+		return nil, nil
+	}
+	p := &queryParser{toks: toks, now: now}
+	n, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.toks) {
+		return nil, fmt.Errorf("%w: unexpected %q at position %d", ErrBadQuery, p.tokText(p.toks[p.pos]), p.toks[p.pos].pos+1)
+	}
+	return n, nil
+}
+
+func (p *queryParser) peek() (queryToken, bool) {
+	if p.pos >= len(p.toks) {
+		return queryToken{}, false
+	}
+	return p.toks[p.pos], true
+}
+
+func (p *queryParser) tokText(t queryToken) string {
+	switch t.kind {
+	case tokLParen:
+		return "("
+	case tokRParen:
+		return ")"
+	case tokNot:
+		return "-"
+	case tokOr:
+		return "OR"
+	case tokAnd:
+		return "AND"
+	}
+	return t.text
+}
+
+func (p *queryParser) parseAnd() (*queryNode, error) {
+	var kids []*queryNode
+	for {
+		t, ok := p.peek()
+		if !ok || t.kind == tokRParen {
+			break
+		}
+		if t.kind == tokAnd {
+			if len(kids) == 0 {
+				return nil, fmt.Errorf("%w: AND without left operand at position %d", ErrBadQuery, t.pos+1)
+			}
+			p.pos++
+			if t, ok := p.peek(); !ok || t.kind == tokRParen {
+				return nil, fmt.Errorf("%w: AND without right operand", ErrBadQuery)
+			}
+			continue
+		}
+		n, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		kids = append(kids, n)
+	}
+	switch len(kids) {
+	case 0:
+		return nil, fmt.Errorf("%w: empty expression", ErrBadQuery)
+	case 1:
+		return kids[0], nil
+	}
+	return &queryNode{op: opAnd, kids: kids}, nil
+}
+
+func (p *queryParser) parseOr() (*queryNode, error) {
+	if t, _ := p.peek(); t.kind == tokOr {
+		return nil, fmt.Errorf("%w: OR without left operand at position %d", ErrBadQuery, t.pos+1)
+	}
+	n, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+	kids := []*queryNode{n}
+	for {
+		t, ok := p.peek()
+		if !ok || t.kind != tokOr {
+			break
+		}
+		p.pos++
+		if t, ok := p.peek(); !ok || t.kind == tokRParen || t.kind == tokOr || t.kind == tokAnd {
+			return nil, fmt.Errorf("%w: OR without right operand", ErrBadQuery)
+		}
+		n, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		kids = append(kids, n)
+	}
+	if len(kids) == 1 {
+		return kids[0], nil
+	}
+	return &queryNode{op: opOr, kids: kids}, nil
+}
+
+func (p *queryParser) parseUnary() (*queryNode, error) {
+	t, ok := p.peek()
+	if !ok {
+		return nil, fmt.Errorf("%w: unexpected end of query", ErrBadQuery)
+	}
+	switch t.kind {
+	case tokNot:
+		p.pos++
+		n, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &queryNode{op: opNot, kids: []*queryNode{n}}, nil
+	case tokLParen:
+		p.pos++
+		n, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		if r, ok := p.peek(); !ok || r.kind != tokRParen {
+			return nil, fmt.Errorf("%w: missing ) for ( at position %d", ErrBadQuery, t.pos+1)
+		}
+		p.pos++
+		return n, nil
+	case tokWord, tokPhrase:
+		p.pos++
+		return p.leaf(t)
+	}
+	return nil, fmt.Errorf("%w: unexpected %q at position %d", ErrBadQuery, p.tokText(t), t.pos+1)
+}
+
+func (p *queryParser) leaf(t queryToken) (*queryNode, error) {
+	phrase := t.kind == tokPhrase
+	switch t.field {
+	case "":
+		return &queryNode{op: opText, match: fts.Match{Text: t.text, Phrase: phrase}}, nil
+	case fts.FieldTitle, fts.FieldText:
+		return &queryNode{op: opText, match: fts.Match{Field: t.field, Text: t.text, Phrase: phrase}}, nil
+	case "tag", "tags":
+		tag := model.CleanTag(t.text)
+		if tag == "" {
+			return nil, fmt.Errorf("%w: empty tag at position %d", ErrBadQuery, t.pos+1)
+		}
+		return &queryNode{op: opTag, value: tag}, nil
+	case "created", "updated":
+		return parseDateTerm(t.field, t.text, p.now)
+	}
+	if key, ok := strings.CutPrefix(t.field, metaField); ok {
+		if phrase {
+			return &queryNode{op: opMeta, field: key, cmp: "=", value: t.text}, nil
+		}
+		return parseMetaTerm(key, t.text)
+	}
+	return nil, fmt.Errorf("%w: unknown field %q at position %d", ErrBadQuery, t.field, t.pos+1)
+}
+
+// parseDateTerm разбирает значение created:/updated:.
+func parseDateTerm(field, v string, now time.Time) (*queryNode, error) {
+	if a, b, ok := strings.Cut(v, ".."); ok {
+		var kids []*queryNode
+		if a != "" {
+			n, err := parseDateTerm(field, ">="+a, now)
+			if err != nil {
+				return nil, err
+			}
+			kids = append(kids, n)
+		}
+		if b != "" {
+			n, err := parseDateTerm(field, "<="+b, now)
+			if err != nil {
+				return nil, err
+			}
+			kids = append(kids, n)
+		}
+		switch len(kids) {
+		case 0:
+			return nil, fmt.Errorf("%w: empty range for %s:", ErrBadQuery, field)
+		case 1:
+			return kids[0], nil
+		}
+		return &queryNode{op: opAnd, kids: kids}, nil
+	}
+
+	cmp := "="
+	for _, c := range []string{">=", "<=", ">", "<", "="} {
+		if strings.HasPrefix(v, c) {
+			cmp, v = c, v[len(c):]
+			break
+		}
+	}
+	from, to, err := ParseTimeBound(v, now)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s:%s: %v", ErrBadQuery, field, v, err)
+	}
+	return &queryNode{op: opDate, field: field, cmp: cmp, value: v, from: from, to: to}, nil
+}
+
+func (n *queryNode) matchDate(note *model.Note) bool {
+	t := note.CreatedAt
+	if n.field == "updated" {
+		t = note.UpdatedAt
+	}
+	point := n.from.Equal(n.to)
+	switch n.cmp {
+	case ">":
+		if point {
+			return t.After(n.from)
+		}
+		return !t.Before(n.to)
+	case ">=":
+		return !t.Before(n.from)
+	case "<":
+		return t.Before(n.from)
+	case "<=":
+		if point {
+			return !t.After(n.from)
+		}
+		return t.Before(n.to)
+	}
+	if point {
+		return t.Equal(n.from)
+	}
+	return !t.Before(n.from) && t.Before(n.to)
+}
+
+// metaField — префикс полей метаданных в запросе: meta.status:open.
+const metaField = "meta."
+
+// parseMetaTerm разбирает значение meta.KEY:.
+func parseMetaTerm(key, v string) (*queryNode, error) {
+	if v == "*" {
+		return &queryNode{op: opMeta, field: key}, nil
+	}
+	cmp := "="
+	for _, c := range []string{">=", "<=", ">", "<", "="} {
+		if strings.HasPrefix(v, c) {
+			cmp, v = c, v[len(c):]
+			break
+		}
+	}
+	if v == "" {
+		return nil, fmt.Errorf("%w: empty value for %s%s:", ErrBadQuery, metaField, key)
+	}
+	return &queryNode{op: opMeta, field: key, cmp: cmp, value: v}, nil
+}
+
+// parseWhere разбирает условие --where: key=value, key!=value,
+// key>value, key>=value, key<value, key<=value или просто key — поле
+// задано. Значение в двойных кавычках берётся без них.
+func parseWhere(expr string) (*queryNode, error) {
+	i := strings.IndexAny(expr, "=!<>")
+	if i < 0 {
+		key, err := model.CleanMetaKey(expr)
+		if err != nil {
+			return nil, fmt.Errorf("%w: where %q: %v", ErrBadQuery, expr, err)
+		}
+		return &queryNode{op: opMeta, field: key}, nil
+	}
+	key, err := model.CleanMetaKey(expr[:i])
+	if err != nil {
+		return nil, fmt.Errorf("%w: where %q: %v", ErrBadQuery, expr, err)
+	}
+	rest := expr[i:]
+	cmp := ""
+	for _, c := range []string{"!=", ">=", "<=", "=", ">", "<"} {
+		if strings.HasPrefix(rest, c) {
+			cmp = c
+			break
+		}
+	}
+	v := strings.TrimSpace(rest[len(cmp):])
+	if len(v) >= 2 && v[0] == '"' && v[len(v)-1] == '"' {
+		v = v[1 : len(v)-1]
+	}
+	if cmp == "" || v == "" {
+		return nil, fmt.Errorf("%w: where %q: expected key=value", ErrBadQuery, expr)
+	}
+	if cmp == "!=" {
+		n := &queryNode{op: opMeta, field: key, cmp: "=", value: v}
+		return &queryNode{op: opNot, kids: []*queryNode{n}}, nil
+	}
+	return &queryNode{op: opMeta, field: key, cmp: cmp, value: v}, nil
+}
+
+// matchMeta сравнивает поле метаданных заметки; у списка достаточно
+// одного подходящего элемента.
+func (n *queryNode) matchMeta(note *model.Note) bool {
+	v, ok := model.MetaLookup(note.Meta, n.field)
+	if !ok || v == nil {
+		return false
+	}
+	if n.cmp == "" {
+		return true
+	}
+	list, isList := v.([]any)
+	if !isList {
+		list = []any{v}
+	}
+	for _, x := range list {
+		if compareMeta(x, n.cmp, n.value) {
+			return true
+		}
+	}
+	return false
+}
+
+// compareMeta сравнивает скалярное значение с want: числа — как числа,
+// остальное — как строки без учёта регистра.
+func compareMeta(x any, cmp, want string) bool {
+	switch x.(type) {
+	case nil, map[string]any, []any:
+		return false
+	}
+	var c int
+	f, isNum := x.(float64)
+	w, wantNum := model.ParseMetaNumber(want)
+	if isNum && wantNum {
+		c = cmpFloat(f, w)
+	} else {
+		c = strings.Compare(strings.ToLower(model.MetaString(x)), strings.ToLower(want))
+	}
+	switch cmp {
+	case ">":
+		return c > 0
+	case ">=":
+		return c >= 0
+	case "<":
+		return c < 0
+	case "<=":
+		return c <= 0
+	}
+	return c == 0
+}
+
+func cmpFloat(a, b float64) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// bounds возвращает границы условия с датой в UnixNano включительно —
+// в том же виде, в каком времена лежат в индексе ID.
+func (n *queryNode) bounds() (lo, hi int64) {
+	lo, hi = math.MinInt64, math.MaxInt64
+	from, to := n.from.UnixNano(), n.to.UnixNano()
+	point := from == to
+	switch n.cmp {
+	case ">":
+		if point {
+			lo = from + 1
+		} else {
+			lo = to
+		}
+	case ">=":
+		lo = from
+	case "<":
+		hi = from - 1
+	case "<=":
+		if point {
+			hi = from
+		} else {
+			hi = to - 1
+		}
+	default:
+		lo = from
+		if point {
+			hi = from
+		} else {
+			hi = to - 1
+		}
+	}
+	return lo, hi
+}
+
+// matchTag проверяет тег с учётом иерархии: tag:project находит и
+// project/noteline/bugs.
+func (n *queryNode) matchTag(note *model.Note) bool {
+	for _, t := range note.Tags {
+		if model.TagUnder(t, n.value) {
+			return true
+		}
+	}
+	return false
+}
+
+// textLeaves возвращает текстовые условия дерева, которые не стоят под
+// отрицанием: только они могут дать отрывки и вклад в релевантность.
+func (n *queryNode) textLeaves(neg bool, out []*queryNode) []*queryNode {
+	switch n.op {
+	case opText:
+		if !neg {
+			out = append(out, n)
+		}
+	case opNot:
+		out = n.kids[0].textLeaves(!neg, out)
+	default:
+		for _, k := range n.kids {
+			out = k.textLeaves(neg, out)
+		}
+	}
+	return out
+}
+
+func (n *queryNode) walk(fn func(*queryNode)) {
+	fn(n)
+	for _, k := range n.kids {
+		k.walk(fn)
+	}
+}
@@ -0,0 +1,131 @@
+package store
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/model"
+)
+
+func TestParseQuery(t *testing.T) {
+	now := time.Date(2025, 6, 15, 12, 0, 0, 0, time.UTC)
+	cases := []struct {
+		in, want string
+	}{
+		{`deploy`, `deploy`},
+		{`title:deploy tag:work -tag:archived created:>2025-01-01 "exact phrase" OR incident`,
+			`(and title:deploy tag:work (not tag:archived) created:>2025-01-01 (or "exact phrase" incident))`},
+		{`a AND b`, `(and a b)`},
+		{`a OR b OR c`, `(or a b c)`},
+		{`(a b) OR c`, `(or (and a b) c)`},
+		{`NOT (a OR b)`, `(not (or a b))`},
+		{`Title:"release plan"`, `title:"release plan"`},
+		{`text:rollback`, `text:rollback`},
+		{`updated:2025-01..2025-03`, `(and updated:>=2025-01 updated:<=2025-03)`},
+		{`created:..2025`, `created:<=2025`},
+		{`self-hosted 12:30`, `(and self-hosted 12:30)`},
+		{`meta.status:open Meta.Points:>=3 meta.due:* -meta.owner:"Ann Lee"`,
+			`(and meta.status:=open meta.points:>=3 meta.due:* (not meta.owner:=Ann Lee))`},
+		{`  `, `<nil>`},
+	}
+	for _, c := range cases {
+		q, err := parseQuery(c.in, now)
+		if err != nil {
+			t.Fatalf("parseQuery(%q): %v", c.in, err)
+		}
+		got := "<nil>"
+		if q != nil {
+			got = q.String()
+		}
+		if got != c.want {
+			t.Fatalf("parseQuery(%q) = %s, want %s", c.in, got, c.want)
+		}
+	}
+}
+
+func TestParseQueryErrors(t *testing.T) {
+	for _, in := range []string{
+		`"unterminated`,
+		`(a b`,
+		`a)`,
+		`OR a`,
+		`a OR`,
+		`AND a`,
+		`a AND`,
+		`()`,
+		`NOT`,
+		`body:x`,
+		`title:`,
+		`created:yesterday-ish`,
+		`created:..`,
+		`meta.status:`,
+		`meta.points:>`,
+	} {
+		if _, err := parseQuery(in, time.Now()); !errors.Is(err, ErrBadQuery) {
+			t.Fatalf("parseQuery(%q) error = %v, want ErrBadQuery", in, err)
+		}
+	}
+}
+
+func TestDateTerms(t *testing.T) {
+	loc := time.FixedZone("test", 3*3600)
+	now := time.Date(2025, 6, 15, 12, 0, 0, 0, loc)
+	// 2025-01-01 23:30 по местному времени — ещё 1 января.
+	note := &model.Note{
+		CreatedAt: time.Date(2025, 1, 1, 20, 30, 0, 0, time.UTC),
+		UpdatedAt: time.Date(2025, 2, 10, 9, 0, 0, 0, time.UTC),
+	}
+	cases := map[string]bool{
+		"created:2025-01-01":             true,
+		"created:>2025-01-01":            false,
+		"created:>=2025-01-01":           true,
+		"created:<2025-01-02":            true,
+		"created:<=2024-12-31":           false,
+		"created:2025-01":                true,
+		"created:2025":                   true,
+		"created:>2024":                  true,
+		"created:2025-01-01T23:30":       true,
+		"created:2025-01-01T20:30:00Z":   true,
+		"created:>2025-01-01T20:30:00Z":  false,
+		"created:<=2025-01-01T20:30:00Z": true,
+		"updated:2025-02-01..2025-02-28": true,
+		"updated:2025-03..":              false,
+	}
+	ev := (&Store{}).newQueryEval(nil)
+	for in, want := range cases {
+		q, err := parseQuery(in, now)
+		if err != nil {
+			t.Fatalf("parseQuery(%q): %v", in, err)
+		}
+		if got, _ := ev.match(q, note); got != want {
+			t.Fatalf("%s matched = %v, want %v", in, got, want)
+		}
+	}
+}
+
+func TestParseWhere(t *testing.T) {
+	cases := map[string]string{
+		"Status=open":      "meta.status:=open",
+		"status != closed": "(not meta.status:=closed)",
+		"points>=3":        "meta.points:>=3",
+		"points<10":        "meta.points:<10",
+		`owner="Ann Lee"`:  "meta.owner:=Ann Lee",
+		"params.owner=ann": "meta.params.owner:=ann",
+		"due":              "meta.due:*",
+	}
+	for in, want := range cases {
+		n, err := parseWhere(in)
+		if err != nil {
+			t.Fatalf("parseWhere(%q): %v", in, err)
+		}
+		if got := n.String(); got != want {
+			t.Fatalf("parseWhere(%q) = %s, want %s", in, got, want)
+		}
+	}
+	for _, in := range []string{"", "=open", "status=", "title=x", "a b=c", "status!open"} {
+		if _, err := parseWhere(in); !errors.Is(err, ErrBadQuery) {
+			t.Fatalf("parseWhere(%q) error = %v, want ErrBadQuery", in, err)
+		}
+	}
+}
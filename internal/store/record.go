@@ -0,0 +1,148 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/model"
+)
+
+// Формат записи в сегменте:
+//
+//	<crc32c, 8 hex> <длина JSON> <JSON заметки>\n
+//
+// Строки, начинающиеся с '{', — записи старого формата без контрольной
+// суммы; они по-прежнему читаются.
+
+var ErrCorruptRecord = errors.New("corrupt record")
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+func encodeRecord(n *model.Note) ([]byte, error) {
+	payload, err := json.Marshal(n)
+	if err != nil {
+		return nil, err
+	}
+	head := fmt.Sprintf("%08x %d ", crc32.Checksum(payload, crcTable), len(payload))
+	b := make([]byte, 0, len(head)+len(payload)+1)
+	b = append(b, head...)
+	b = append(b, payload...)
+	return append(b, '\n'), nil
+}
+
+func decodeRecord(line []byte) (model.Note, error) {
+	var n model.Note
+	line = bytes.TrimRight(line, "\r\n")
+	if len(line) == 0 {
+		return n, fmt.Errorf("%w: empty line", ErrCorruptRecord)
+	}
+
+	if line[0] == '{' {
+		if err := json.Unmarshal(line, &n); err != nil {
+			return n, fmt.Errorf("%w: %v", ErrCorruptRecord, err)
+		}
+		if n.ID == "" {
+			return n, fmt.Errorf("%w: missing id", ErrCorruptRecord)
+		}
+		return n, nil
+	}
+
+	crcPart, rest, ok := bytes.Cut(line, []byte(" "))
+	if !ok || len(crcPart) != 8 {
+		return n, fmt.Errorf("%w: bad header", ErrCorruptRecord)
+	}
+	lenPart, payload, ok := bytes.Cut(rest, []byte(" "))
+	if !ok {
+		return n, fmt.Errorf("%w: bad header", ErrCorruptRecord)
+	}
+	want, err := strconv.ParseUint(string(crcPart), 16, 32)
+	if err != nil {
+		return n, fmt.Errorf("%w: bad checksum field", ErrCorruptRecord)
+	}
+	size, err := strconv.Atoi(string(lenPart))
+	if err != nil || size != len(payload) {
+		return n, fmt.Errorf("%w: length mismatch", ErrCorruptRecord)
+	}
+	if crc32.Checksum(payload, crcTable) != uint32(want) {
+		return n, fmt.Errorf("%w: checksum mismatch", ErrCorruptRecord)
+	}
+	if err := json.Unmarshal(payload, &n); err != nil {
+		return n, fmt.Errorf("%w: %v", ErrCorruptRecord, err)
+	}
+	if n.ID == "" {
+		return n, fmt.Errorf("%w: missing id", ErrCorruptRecord)
+	}
+	return n, nil
+}
+
+// rawRecord — одна строка сегмента. Err != nil, если строку не удалось
+// разобрать; Complete == false, если строка не завершена переводом строки
+// (так выглядит оборванная запись в конце файла).
+type rawRecord struct {
+	Off      int64
+	Size     int
+	Note     model.Note
+	Err      error
+	Complete bool
+}
+
+func readSegment(path string, start int64, fn func(rec rawRecord) error) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if start > 0 {
+		if _, err := f.Seek(start, io.SeekStart); err != nil {
+			return err
+		}
+	}
+
+	r := bufio.NewReader(f)
+	off := start
+	for {
+		line, err := r.ReadBytes('\n')
+		if len(line) > 0 {
+			rec := rawRecord{
+				Off:      off,
+				Size:     len(line),
+				Complete: line[len(line)-1] == '\n',
+			}
+			rec.Note, rec.Err = decodeRecord(line)
+			if ferr := fn(rec); ferr != nil {
+				return ferr
+			}
+			off += int64(len(line))
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// scanSegment читает сегмент построчно и вызывает fn для каждой записи
+// со смещением строки в файле. Строки, которые не удалось разобрать,
+// пропускаются.
+func scanSegment(path string, fn func(off int64, size int, n model.Note) error) error {
+	return scanSegmentFrom(path, 0, fn)
+}
+
+func scanSegmentFrom(path string, start int64, fn func(off int64, size int, n model.Note) error) error {
+	return readSegment(path, start, func(rec rawRecord) error {
+		if rec.Err != nil || !rec.Complete {
+			return nil
+		}
+		return fn(rec.Off, rec.Size, rec.Note)
+	})
+}
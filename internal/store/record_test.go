@@ -0,0 +1,48 @@
+package store
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/model"
+)
+
+func TestEncodeDecodeRecord(t *testing.T) {
+	n := model.NewNote("Title", "multi\nline", []string{"a"})
+	b, err := encodeRecord(n)
+	if err != nil {
+		t.Fatalf("encodeRecord: %v", err)
+	}
+	if b[len(b)-1] != '\n' {
+		t.Fatalf("record must end with newline: %q", b)
+	}
+
+	got, err := decodeRecord(b)
+	if err != nil {
+		t.Fatalf("decodeRecord: %v", err)
+	}
+	if got.ID != n.ID || got.Text != n.Text {
+		t.Fatalf("decoded %+v, want %+v", got, n)
+	}
+
+	corrupt := append([]byte{}, b...)
+	corrupt[len(corrupt)-3] ^= 0x20
+	if _, err := decodeRecord(corrupt); !errors.Is(err, ErrCorruptRecord) {
+		t.Fatalf("decodeRecord(corrupt) = %v, want ErrCorruptRecord", err)
+	}
+
+	if _, err := decodeRecord(b[:len(b)/2]); !errors.Is(err, ErrCorruptRecord) {
+		t.Fatalf("decodeRecord(truncated) = %v, want ErrCorruptRecord", err)
+	}
+}
+
+func TestDecodeLegacyRecord(t *testing.T) {
+	line := []byte(`{"id":"abc","title":"Old","text":"format","tags":null,"created_at":"2025-01-01T00:00:00Z","updated_at":"2025-01-01T00:00:00Z","deleted":false}` + "\n")
+	n, err := decodeRecord(line)
+	if err != nil {
+		t.Fatalf("decodeRecord(legacy): %v", err)
+	}
+	if n.ID != "abc" || n.Title != "Old" {
+		t.Fatalf("decoded legacy %+v", n)
+	}
+}
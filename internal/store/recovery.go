@@ -0,0 +1,110 @@
+package store
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/i18n"
+)
+
+// RecoveryReport описывает, что Open отрезал от хвоста активного сегмента.
+type RecoveryReport struct {
+	Segment        string `json:"segment"`
+	Offset         int64  `json:"offset"`
+	DiscardedBytes int64  `json:"discarded_bytes"`
+}
+
+// Recovery возвращает отчёт о восстановлении после сбоя или nil, если
+// хвост последнего сегмента был цел.
+func (s *Store) Recovery() *RecoveryReport {
+	return s.recovery
+}
+
+type tailState struct {
+	path           string
+	goodEnd        int64
+	size           int64
+	missingNewline bool
+}
+
+func (t *tailState) dirty() bool {
+	return t != nil && (t.goodEnd < t.size || t.missingNewline)
+}
+
+// checkTail ищет конец последней целой записи в последнем сегменте.
+// Проверка начинается с места, уже учтённого первичным индексом.
+func (s *Store) checkTail() (*tailState, error) {
+	files := s.segmentFiles()
+	if len(files) == 0 {
+		return nil, nil
+	}
+	path := files[len(files)-1]
+	no, err := parseSeqFromName(filepath.Base(path))
+	if err != nil {
+		return nil, nil
+	}
+	st, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	start := s.idx.Segments[no]
+	if start > st.Size() {
+		start = 0
+	}
+	t := &tailState{path: path, goodEnd: start, size: st.Size()}
+	if start == st.Size() {
+		return t, nil
+	}
+
+	err = readSegment(path, start, func(rec rawRecord) error {
+		if rec.Err == nil {
+			t.goodEnd = rec.Off + int64(rec.Size)
+			t.missingNewline = !rec.Complete
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// recoverTail отрезает всё, что идёт после последней целой записи
+// последнего сегмента: так выглядит запись, оборванная сбоем.
+func (s *Store) recoverTail() error {
+	t, err := s.checkTail()
+	if err != nil || !t.dirty() {
+		return err
+	}
+
+	if t.goodEnd < t.size {
+		if err := os.Truncate(t.path, t.goodEnd); err != nil {
+			return fmt.Errorf("truncate torn tail of %s: %w", filepath.Base(t.path), err)
+		}
+		s.recovery = &RecoveryReport{
+			Segment:        filepath.Base(t.path),
+			Offset:         t.goodEnd,
+			DiscardedBytes: t.size - t.goodEnd,
+		}
+		fmt.Fprintf(os.Stderr, "%s\n", i18n.T("warning.torn_tail_truncated", s.recovery.Segment, s.recovery.DiscardedBytes, s.recovery.Offset))
+	}
+
+	// Последняя запись цела, но без перевода строки: дописываем его, чтобы
+	// следующая запись не склеилась с ней.
+	if t.missingNewline {
+		f, err := os.OpenFile(t.path, os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return err
+		}
+		_, werr := f.Write([]byte("\n"))
+		if err := f.Close(); werr == nil {
+			werr = err
+		}
+		if werr != nil {
+			return werr
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package store
+
+import (
+	"os"
+	"testing"
+
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/model"
+)
+
+func TestOpenTruncatesTornTail(t *testing.T) {
+	root := t.TempDir()
+	s, err := Open(root)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	n := model.NewNote("Title", "Body", nil)
+	if err := s.Append(n); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	seg := s.segmentPath(s.activeNo)
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	st, _ := os.Stat(seg)
+	goodSize := st.Size()
+
+	torn, err := encodeRecord(model.NewNote("Torn", "never finished", nil))
+	if err != nil {
+		t.Fatalf("encodeRecord: %v", err)
+	}
+	f, err := os.OpenFile(seg, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatalf("OpenFile: %v", err)
+	}
+	if _, err := f.Write(torn[:len(torn)/2]); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+	_ = f.Close()
+
+	s2, err := Open(root)
+	if err != nil {
+		t.Fatalf("reopen: %v", err)
+	}
+	defer s2.Close()
+
+	rep := s2.Recovery()
+	if rep == nil {
+		t.Fatalf("expected recovery report after torn write")
+	}
+	if rep.Offset != goodSize || rep.DiscardedBytes != int64(len(torn)/2) {
+		t.Fatalf("recovery = %+v, want offset %d discarded %d", rep, goodSize, len(torn)/2)
+	}
+	if st, _ := os.Stat(seg); st.Size() != goodSize {
+		t.Fatalf("segment size after recovery = %d, want %d", st.Size(), goodSize)
+	}
+
+	n2 := model.NewNote("After", "recovery", nil)
+	if err := s2.Append(n2); err != nil {
+		t.Fatalf("Append after recovery: %v", err)
+	}
+	noteCache.Clear()
+	for _, id := range []string{n.ID, n2.ID} {
+		if _, err := s2.GetByID(id); err != nil {
+			t.Fatalf("GetByID(%s): %v", id, err)
+		}
+	}
+}
+
+func TestOpenReadsLegacySegmentWithoutTrailingNewline(t *testing.T) {
+	root := t.TempDir()
+	if err := Ensure(root); err != nil {
+		t.Fatalf("Ensure: %v", err)
+	}
+	legacy := `{"id":"old1","title":"Old","text":"one","tags":null,"created_at":"2025-01-01T00:00:00Z","updated_at":"2025-01-01T00:00:00Z","deleted":false}` + "\n" +
+		`{"id":"old2","title":"Old","text":"two","tags":null,"created_at":"2025-01-01T00:00:00Z","updated_at":"2025-01-01T00:00:00Z","deleted":false}`
+	if err := os.WriteFile(root+"/segments/notes-00000001.ndjson", []byte(legacy), 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	s, err := Open(root)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer s.Close()
+	noteCache.Clear()
+
+	if s.Recovery() != nil {
+		t.Fatalf("legacy record must not be discarded: %+v", s.Recovery())
+	}
+	n := model.NewNote("New", "three", nil)
+	if err := s.Append(n); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	for _, id := range []string{"old1", "old2", n.ID} {
+		if _, err := s.GetByID(id); err != nil {
+			t.Fatalf("GetByID(%s): %v", id, err)
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// MinIDPrefix — минимальная длина префикса ID, по которому ищется заметка.
+const MinIDPrefix = 4
+
+var (
+	ErrAmbiguous   = errors.New("ambiguous note reference")
+	ErrShortPrefix = fmt.Errorf("id prefix is shorter than %d characters", MinIDPrefix)
+)
+
+// AmbiguousError перечисляет заметки, подходящие под ссылку. errors.Is
+// сравнивает её с ErrAmbiguous.
+type AmbiguousError struct {
+	Ref        string
+	Candidates []Candidate
+}
+
+type Candidate struct {
+	ID, Title string
+}
+
+func (e *AmbiguousError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%v %q, candidates:", ErrAmbiguous, e.Ref)
+	for _, c := range e.Candidates {
+		fmt.Fprintf(&b, "\n  %s  %s", c.ID, c.Title)
+	}
+	return b.String()
+}
+
+func (e *AmbiguousError) Is(target error) bool { return target == ErrAmbiguous }
+
+// ResolveID находит заметку по полному ID или по его уникальному префиксу
+// (git-style, не короче MinIDPrefix). Живые заметки важнее удалённых:
+// удалённая находится по префиксу, только если живых совпадений нет, —
+// так history и restore работают и с удалёнными заметками.
+func (s *Store) ResolveID(ref string) (string, error) {
+	ref = strings.TrimSpace(ref)
+	if _, ok := s.idx.Entries[ref]; ok {
+		return ref, nil
+	}
+	if len(ref) < MinIDPrefix {
+		return "", fmt.Errorf("%w: %q", ErrShortPrefix, ref)
+	}
+
+	var live, deleted []string
+	for id, e := range s.idx.Entries {
+		if !strings.HasPrefix(id, ref) {
+			continue
+		}
+		if e.Deleted {
+			deleted = append(deleted, id)
+		} else {
+			live = append(live, id)
+		}
+	}
+	if len(live) == 0 {
+		live = deleted
+	}
+	switch len(live) {
+	case 0:
+		return "", ErrNotFound
+	case 1:
+		return live[0], nil
+	}
+	sort.Strings(live)
+	return "", s.ambiguous(ref, live)
+}
+
+// ResolveTitle находит живую заметку с точно таким заголовком.
+func (s *Store) ResolveTitle(title string) (string, error) {
+	notes, err := s.liveNotes()
+	if err != nil {
+		return "", err
+	}
+	var ids []string
+	for _, n := range notes {
+		if n.Title == title {
+			ids = append(ids, n.ID)
+		}
+	}
+	switch len(ids) {
+	case 0:
+		return "", ErrNotFound
+	case 1:
+		return ids[0], nil
+	}
+	sort.Strings(ids)
+	return "", s.ambiguous(title, ids)
+}
+
+func (s *Store) ambiguous(ref string, ids []string) error {
+	e := &AmbiguousError{Ref: ref}
+	for _, id := range ids {
+		c := Candidate{ID: id}
+		if n, err := s.readEntry(s.idx.Entries[id]); err == nil {
+			c.Title = n.Title
+		}
+		e.Candidates = append(e.Candidates, c)
+	}
+	return e
+}
+
+// ShortIDs возвращает для каждой живой заметки кратчайший префикс ID,
+// не короче MinIDPrefix, по которому ResolveID найдёт именно её.
+func (s *Store) ShortIDs() map[string]string {
+	ids := make([]string, 0, len(s.idx.Entries))
+	for id, e := range s.idx.Entries {
+		if !e.Deleted {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	out := make(map[string]string, len(ids))
+	for i, id := range ids {
+		n := MinIDPrefix
+		// В отсортированном списке самый длинный общий префикс — с соседями.
+		if i > 0 {
+			n = max(n, commonPrefix(id, ids[i-1])+1)
+		}
+		if i+1 < len(ids) {
+			n = max(n, commonPrefix(id, ids[i+1])+1)
+		}
+		out[id] = id[:min(n, len(id))]
+	}
+	return out
+}
+
+func commonPrefix(a, b string) int {
+	n := 0
+	for n < len(a) && n < len(b) && a[n] == b[n] {
+		n++
+	}
+	return n
+}
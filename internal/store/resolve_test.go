@@ -0,0 +1,105 @@
+package store
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/model"
+)
+
+func TestResolveID(t *testing.T) {
+	s, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer s.Close()
+
+	for _, id := range []string{"abcd1111", "abcd2222", "abce0000", "ffff0000", "ffff0001"} {
+		n := model.NewNote("note "+id, "t", nil)
+		n.ID = id
+		if err := s.Append(n); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+	dup := model.NewNote("note abce0000", "t", nil)
+	if err := s.Append(dup); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	for _, id := range []string{"ffff0000", "ffff0001"} {
+		n := &model.Note{ID: id, Deleted: true}
+		if err := s.Append(n); err != nil {
+			t.Fatalf("Append tombstone: %v", err)
+		}
+	}
+	live := model.NewNote("live", "t", nil)
+	live.ID = "ffff0002"
+	if err := s.Append(live); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+
+	for ref, want := range map[string]string{
+		"abcd1111": "abcd1111",
+		"abcd1":    "abcd1111",
+		"abce":     "abce0000",
+		"ffff":     "ffff0002", // живая заметка важнее удалённых
+		"ffff0001": "ffff0001",
+		"ffff000":  "ffff0002",
+	} {
+		got, err := s.ResolveID(ref)
+		if err != nil || got != want {
+			t.Fatalf("ResolveID(%q) = %q, %v, want %q", ref, got, err, want)
+		}
+	}
+
+	_, err = s.ResolveID("abcd")
+	var amb *AmbiguousError
+	if !errors.As(err, &amb) || !errors.Is(err, ErrAmbiguous) {
+		t.Fatalf("ResolveID(abcd) = %v, want ambiguity", err)
+	}
+	if len(amb.Candidates) != 2 || amb.Candidates[0] != (Candidate{"abcd1111", "note abcd1111"}) {
+		t.Fatalf("candidates = %+v", amb.Candidates)
+	}
+	if !strings.Contains(err.Error(), "abcd2222  note abcd2222") {
+		t.Fatalf("error text = %q", err)
+	}
+	if _, err := s.ResolveID("abc"); !errors.Is(err, ErrShortPrefix) {
+		t.Fatalf("ResolveID(abc) = %v", err)
+	}
+	if _, err := s.ResolveID("0000"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("ResolveID(0000) = %v", err)
+	}
+
+	if got, err := s.ResolveTitle("live"); err != nil || got != "ffff0002" {
+		t.Fatalf("ResolveTitle(live) = %q, %v", got, err)
+	}
+	if _, err := s.ResolveTitle("note abce0000"); !errors.Is(err, ErrAmbiguous) {
+		t.Fatalf("ResolveTitle(duplicate) = %v", err)
+	}
+	if _, err := s.ResolveTitle("note ffff0000"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("ResolveTitle(deleted) = %v", err)
+	}
+
+	short := s.ShortIDs()
+	for id, want := range map[string]string{
+		"abcd1111": "abcd1",
+		"abcd2222": "abcd2",
+		"abce0000": "abce",
+		"ffff0002": "ffff",
+	} {
+		if short[id] != want {
+			t.Fatalf("ShortIDs[%s] = %q, want %q", id, short[id], want)
+		}
+	}
+	if _, ok := short["ffff0000"]; ok {
+		t.Fatalf("ShortIDs includes a deleted note")
+	}
+	for id, p := range short {
+		if got, err := s.ResolveID(p); err != nil || got != id {
+			t.Fatalf("ResolveID(ShortIDs[%s] = %q) = %q, %v", id, p, got, err)
+		}
+	}
+	if len(short) != 5 {
+		t.Fatalf("ShortIDs has %d entries", len(short))
+	}
+}
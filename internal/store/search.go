@@ -0,0 +1,480 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	fts "github.com/Victor3563/NoteLine/cli-notebook/internal/fulltext"
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/model"
+)
+
+// Порядок результатов в Filter.Sort.
+const (
+	SortRelevance = "relevance"
+	SortCreated   = "created"
+	SortUpdated   = "updated"
+	SortTitle     = "title"
+)
+
+var ErrUnknownSort = errors.New("unknown sort order")
+
+// Hit — заметка, найденная Search, с оценкой релевантности и отрывками
+// полей, где нашёлся запрос. У заметок, найденных без полнотекстового
+// индекса, Score равен 0.
+type Hit struct {
+	model.Note
+	Score     float64        `json:"score"`
+	Fragments []fts.Fragment `json:"fragments,omitempty"`
+}
+
+// Search отбирает заметки по filter и по умолчанию упорядочивает их по
+// релевантности. Запрос filter.Query разбирается в дерево (см. query.go):
+// текстовые условия выполняет полнотекстовый индекс, теги и даты
+// проверяются по метаданным. Если индекс недоступен или ничего не нашёл
+// по условию, оно проверяется поиском подстроки по заметкам.
+func (s *Store) Search(filter Filter) ([]Hit, error) {
+	if filter.Sort == "" {
+		filter.Sort = SortRelevance
+	}
+	switch filter.Sort {
+	case SortRelevance, SortCreated, SortUpdated, SortTitle:
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownSort, filter.Sort)
+	}
+	q, err := filter.query(time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	ev := s.newQueryEval(q)
+	notes, err := s.queryCandidates(ev)
+	if err != nil {
+		return nil, err
+	}
+	var hits []Hit
+	for _, n := range notes {
+		if ok, score := ev.match(q, &n); ok {
+			hits = append(hits, Hit{Note: n, Score: score})
+		}
+	}
+
+	sortHits(hits, filter.Sort)
+
+	if filter.Limit > 0 && len(hits) > filter.Limit {
+		hits = hits[:filter.Limit]
+	}
+
+	// Отрывки нужны только для того, что попадёт в вывод.
+	ev.fragments(hits)
+	return hits, nil
+}
+
+// query собирает дерево запроса из всех условий фильтра. nil означает,
+// что подходят все заметки.
+func (f Filter) query(now time.Time) (*queryNode, error) {
+	var kids []*queryNode
+	bound := func(field, cmp string, t time.Time) {
+		if !t.IsZero() {
+			kids = append(kids, &queryNode{op: opDate, field: field, cmp: cmp, value: t.Format(time.RFC3339), from: t, to: t})
+		}
+	}
+	bound("created", ">=", f.Since)
+	bound("created", "<=", f.Until)
+	bound("updated", ">=", f.UpdatedSince)
+	tagNodes := func(tags []string) []*queryNode {
+		var out []*queryNode
+		for _, t := range tags {
+			if t = model.CleanTag(t); t != "" {
+				out = append(out, &queryNode{op: opTag, value: t})
+			}
+		}
+		return out
+	}
+	kids = append(kids, tagNodes(f.Tags)...)
+	if anyOf := tagNodes(f.AnyTags); len(anyOf) > 0 {
+		kids = append(kids, &queryNode{op: opOr, kids: anyOf})
+	}
+	for _, n := range tagNodes(f.NotTags) {
+		kids = append(kids, &queryNode{op: opNot, kids: []*queryNode{n}})
+	}
+	for _, w := range f.Where {
+		n, err := parseWhere(w)
+		if err != nil {
+			return nil, err
+		}
+		kids = append(kids, n)
+	}
+	if text := strings.TrimSpace(f.Contains); text != "" {
+		kids = append(kids, &queryNode{op: opText, match: fts.Match{Text: text}})
+	}
+	q, err := parseQuery(f.Query, now)
+	if err != nil {
+		return nil, err
+	}
+	if q != nil {
+		kids = append(kids, q)
+	}
+	switch len(kids) {
+	case 0:
+		return nil, nil
+	case 1:
+		return kids[0], nil
+	}
+	return &queryNode{op: opAnd, kids: kids}, nil
+}
+
+// queryEval хранит результаты полнотекстового поиска для текстовых
+// условий запроса. Условие без записи в scores проверяется подстрокой.
+type queryEval struct {
+	root   *queryNode
+	idx    *idIndex
+	scores map[*queryNode]map[string]float64
+	// termDocs — заметки с тегом или значением метаданных условия по
+	// точным термам полнотекстового индекса; без записи условие сужает
+	// выборку только проверкой.
+	termDocs map[*queryNode]map[string]bool
+}
+
+func (s *Store) newQueryEval(root *queryNode) *queryEval {
+	ev := &queryEval{
+		root:     root,
+		idx:      s.idx,
+		scores:   map[*queryNode]map[string]float64{},
+		termDocs: map[*queryNode]map[string]bool{},
+	}
+	if root == nil {
+		return ev
+	}
+	root.walk(func(n *queryNode) {
+		if n.op == opTag || n.op == opMeta {
+			if ids, err := termDocs(n); err == nil {
+				m := make(map[string]bool, len(ids))
+				for _, id := range ids {
+					m[id] = true
+				}
+				ev.termDocs[n] = m
+			}
+			return
+		}
+		if n.op != opText {
+			return
+		}
+		res, err := fts.Search(n.match, 0)
+		if err != nil || len(res) == 0 {
+			return
+		}
+		m := make(map[string]float64, len(res))
+		for _, h := range res {
+			m[h.ID] = h.Score
+		}
+		ev.scores[n] = m
+	})
+	return ev
+}
+
+// termDocs ищет заметки условия точным термом. Для метаданных индекс
+// годится только на равенство со строкой: числа, сравнения и проверка
+// наличия поля проверяются по заметкам.
+func termDocs(n *queryNode) ([]string, error) {
+	if n.op == opTag {
+		return fts.TagDocs(n.value)
+	}
+	if n.cmp != "=" {
+		return nil, errNoTerm
+	}
+	if _, isNum := model.ParseMetaNumber(n.value); isNum {
+		return nil, errNoTerm
+	}
+	return fts.MetaDocs(n.field, n.value)
+}
+
+var errNoTerm = errors.New("no index term for condition")
+
+// candidates возвращает множество заметок, за пределами которого запрос
+// заведомо ничего не найдёт; ok == false, если нужно проверить все.
+func (ev *queryEval) candidates(n *queryNode) (ids map[string]bool, ok bool) {
+	switch n.op {
+	case opText:
+		m, found := ev.scores[n]
+		if !found {
+			return nil, false
+		}
+		ids = make(map[string]bool, len(m))
+		for id := range m {
+			ids[id] = true
+		}
+		return ids, true
+	case opTag, opMeta:
+		ids, found := ev.termDocs[n]
+		if !found {
+			return nil, false
+		}
+		// Копия: множество кандидатов изменяется при пересечении.
+		out := make(map[string]bool, len(ids))
+		for id := range ids {
+			out[id] = true
+		}
+		return out, true
+	case opDate:
+		// Даты берутся из индекса ID, упорядоченного по времени.
+		if ev.idx == nil {
+			return nil, false
+		}
+		lo, hi := n.bounds()
+		found := ev.idx.order(n.field == "updated").between(lo, hi)
+		ids = make(map[string]bool, len(found))
+		for _, id := range found {
+			ids[id] = true
+		}
+		return ids, true
+	case opAnd:
+		for _, k := range n.kids {
+			kidIDs, kidOK := ev.candidates(k)
+			if !kidOK {
+				continue
+			}
+			if !ok {
+				ids, ok = kidIDs, true
+				continue
+			}
+			for id := range ids {
+				if !kidIDs[id] {
+					delete(ids, id)
+				}
+			}
+		}
+		return ids, ok
+	case opOr:
+		ids = map[string]bool{}
+		for _, k := range n.kids {
+			kidIDs, kidOK := ev.candidates(k)
+			if !kidOK {
+				return nil, false
+			}
+			for id := range kidIDs {
+				ids[id] = true
+			}
+		}
+		return ids, true
+	}
+	return nil, false
+}
+
+// queryCandidates читает заметки, которые нужно проверить запросом:
+// найденные индексом, если их хватает, иначе все живые.
+func (s *Store) queryCandidates(ev *queryEval) ([]model.Note, error) {
+	if ev.root != nil {
+		if ids, ok := ev.candidates(ev.root); ok {
+			notes := make([]model.Note, 0, len(ids))
+			for id := range ids {
+				n, err := s.GetByID(id)
+				if err != nil {
+					continue
+				}
+				notes = append(notes, *n)
+			}
+			return notes, nil
+		}
+	}
+	return s.liveNotes()
+}
+
+// match проверяет заметку и возвращает сумму оценок bleve по совпавшим
+// текстовым условиям.
+func (ev *queryEval) match(n *queryNode, note *model.Note) (bool, float64) {
+	if n == nil {
+		return true, 0
+	}
+	switch n.op {
+	case opAnd:
+		total := 0.0
+		for _, k := range n.kids {
+			ok, score := ev.match(k, note)
+			if !ok {
+				return false, 0
+			}
+			total += score
+		}
+		return true, total
+	case opOr:
+		matched, total := false, 0.0
+		for _, k := range n.kids {
+			if ok, score := ev.match(k, note); ok {
+				matched = true
+				total += score
+			}
+		}
+		return matched, total
+	case opNot:
+		ok, _ := ev.match(n.kids[0], note)
+		return !ok, 0
+	case opText:
+		if m, found := ev.scores[n]; found {
+			score, ok := m[note.ID]
+			return ok, score
+		}
+		return matchSubstring(n.match, note), 0
+	case opTag:
+		return n.matchTag(note), 0
+	case opDate:
+		return n.matchDate(note), 0
+	case opMeta:
+		return n.matchMeta(note), 0
+	}
+	return false, 0
+}
+
+// fragments заполняет отрывки: для условий, найденных индексом, их строит
+// bleve, для остальных — поиск подстроки.
+func (ev *queryEval) fragments(hits []Hit) {
+	if ev.root == nil || len(hits) == 0 {
+		return
+	}
+	var ranked []fts.Match
+	var plain []fts.Match
+	for _, leaf := range ev.root.textLeaves(false, nil) {
+		if _, found := ev.scores[leaf]; found {
+			ranked = append(ranked, leaf.match)
+		} else {
+			plain = append(plain, leaf.match)
+		}
+	}
+
+	if len(ranked) > 0 {
+		ids := make([]string, len(hits))
+		for i := range hits {
+			ids[i] = hits[i].ID
+		}
+		if frs, err := fts.Fragments(ranked, ids); err == nil {
+			for i := range hits {
+				hits[i].Fragments = frs[hits[i].ID]
+			}
+		}
+	}
+	for i := range hits {
+		for _, m := range plain {
+			if len(hits[i].Fragments) > 0 {
+				break
+			}
+			hits[i].Fragments = substringFragments(&hits[i].Note, m)
+		}
+	}
+}
+
+// matchSubstring проверяет текстовое условие без индекса: фраза ищется
+// целиком, слова — каждое по отдельности, без учёта регистра.
+func matchSubstring(m fts.Match, n *model.Note) bool {
+	var hay string
+	switch m.Field {
+	case fts.FieldTitle:
+		hay = n.Title
+	case fts.FieldText:
+		hay = n.Text
+	default:
+		hay = n.Title + " " + n.Text
+	}
+	hay = strings.ToLower(hay)
+	for _, needle := range substringNeedles(m) {
+		if !strings.Contains(hay, strings.ToLower(needle)) {
+			return false
+		}
+	}
+	return true
+}
+
+func substringNeedles(m fts.Match) []string {
+	if m.Phrase {
+		return []string{m.Text}
+	}
+	return strings.Fields(m.Text)
+}
+
+// substringFragments строит отрывки для заметки, найденной поиском
+// подстроки: первое совпадение в заголовке и в тексте с контекстом вокруг.
+func substringFragments(n *model.Note, m fts.Match) []fts.Fragment {
+	var out []fts.Fragment
+	fields := []struct{ name, value string }{{"title", n.Title}, {"text", n.Text}}
+	for _, f := range fields {
+		if m.Field != "" && m.Field != f.name {
+			continue
+		}
+		for _, needle := range substringNeedles(m) {
+			if fr, ok := substringFragment(f.name, f.value, needle); ok {
+				out = append(out, fr)
+				break
+			}
+		}
+	}
+	return out
+}
+
+func substringFragment(field, s, needle string) (fts.Fragment, bool) {
+	const contextRunes = 40
+	if needle == "" {
+		return fts.Fragment{}, false
+	}
+	// Перебираем только начала символов, а конец совпадения проверяем,
+	// чтобы отрывок никогда не разрезал многобайтовый символ.
+	for i := range s {
+		j := i + len(needle)
+		if j > len(s) {
+			break
+		}
+		if j < len(s) && !utf8.RuneStart(s[j]) {
+			continue
+		}
+		if !strings.EqualFold(s[i:j], needle) {
+			continue
+		}
+		start, end := i, j
+		for k := 0; k < contextRunes && start > 0; k++ {
+			_, size := utf8.DecodeLastRuneInString(s[:start])
+			start -= size
+		}
+		for k := 0; k < contextRunes && end < len(s); k++ {
+			_, size := utf8.DecodeRuneInString(s[end:])
+			end += size
+		}
+		return fts.Fragment{
+			Field:      field,
+			Text:       s[start:end],
+			Start:      start,
+			End:        end,
+			Highlights: []fts.Span{{Start: i, End: j}},
+		}, true
+	}
+	return fts.Fragment{}, false
+}
+
+func sortHits(hits []Hit, by string) {
+	newer := func(a, b *model.Note) bool {
+		if !a.CreatedAt.Equal(b.CreatedAt) {
+			return a.CreatedAt.After(b.CreatedAt)
+		}
+		return a.ID < b.ID
+	}
+
+	sort.SliceStable(hits, func(i, j int) bool {
+		a, b := &hits[i], &hits[j]
+		switch by {
+		case SortRelevance:
+			if a.Score != b.Score {
+				return a.Score > b.Score
+			}
+		case SortUpdated:
+			if !a.UpdatedAt.Equal(b.UpdatedAt) {
+				return a.UpdatedAt.After(b.UpdatedAt)
+			}
+		case SortTitle:
+			at, bt := strings.ToLower(a.Title), strings.ToLower(b.Title)
+			if at != bt {
+				return at < bt
+			}
+		}
+		return newer(&a.Note, &b.Note)
+	})
+}
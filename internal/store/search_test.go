@@ -0,0 +1,386 @@
+package store
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/model"
+)
+
+func TestSearchRanksByRelevance(t *testing.T) {
+	root := t.TempDir()
+	s, err := Open(root)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer s.Close()
+
+	base := time.Now().UTC().Add(-time.Hour)
+	weak := model.NewNote("Groceries", "buy milk, bread and one kiwi", []string{"home"})
+	weak.CreatedAt, weak.UpdatedAt = base.Add(2*time.Minute), base.Add(2*time.Minute)
+	strong := model.NewNote("Kiwi", "kiwi kiwi: how to grow kiwi", []string{"garden"})
+	strong.CreatedAt, strong.UpdatedAt = base, base.Add(5*time.Minute)
+	other := model.NewNote("Apples", "nothing to see", nil)
+	for _, n := range []*model.Note{weak, strong, other} {
+		if err := s.Append(n); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+
+	hits, err := s.Search(Filter{Contains: "kiwi"})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(hits) != 2 {
+		t.Fatalf("Search returned %d hits, want 2", len(hits))
+	}
+	if hits[0].ID != strong.ID || hits[0].Score <= hits[1].Score {
+		t.Fatalf("hits not ranked by relevance: %s (%.3f), %s (%.3f)", hits[0].Title, hits[0].Score, hits[1].Title, hits[1].Score)
+	}
+
+	cases := map[string]string{
+		SortCreated: weak.ID,
+		SortUpdated: strong.ID,
+		SortTitle:   weak.ID,
+	}
+	for by, first := range cases {
+		hits, err := s.Search(Filter{Contains: "kiwi", Sort: by})
+		if err != nil {
+			t.Fatalf("Search sort=%s: %v", by, err)
+		}
+		if len(hits) != 2 || hits[0].ID != first {
+			t.Fatalf("Search sort=%s: first hit %q, want %q", by, hits[0].ID, first)
+		}
+	}
+
+	hits, err = s.Search(Filter{Contains: "kiwi", Tags: []string{"home"}, Limit: 1})
+	if err != nil {
+		t.Fatalf("Search with tag: %v", err)
+	}
+	if len(hits) != 1 || hits[0].ID != weak.ID {
+		t.Fatalf("Search with tag returned %v, want only %s", hits, weak.ID)
+	}
+
+	if _, err := s.Search(Filter{Contains: "kiwi", Sort: "size"}); !errors.Is(err, ErrUnknownSort) {
+		t.Fatalf("Search with unknown sort: %v, want ErrUnknownSort", err)
+	}
+}
+
+func TestSearchFragments(t *testing.T) {
+	root := t.TempDir()
+	s, err := Open(root)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer s.Close()
+
+	n := model.NewNote("Ёлка", strings.Repeat("ёжик ", 30)+"колючий ЁЖИКОВЫЙ день", nil)
+	if err := s.Append(n); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+
+	hits, err := s.Search(Filter{Contains: "колючий"})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(hits) != 1 || len(hits[0].Fragments) != 1 || hits[0].Fragments[0].Field != "text" {
+		t.Fatalf("Search fragments = %+v, want one text fragment", hits)
+	}
+	h := hits[0].Fragments[0].Highlights[0]
+	if n.Text[h.Start:h.End] != "колючий" {
+		t.Fatalf("highlight = %q, want %q", n.Text[h.Start:h.End], "колючий")
+	}
+
+	// Часть слова индекс не находит: отрывок строится поиском подстроки.
+	hits, err = s.Search(Filter{Contains: "ёжиков"})
+	if err != nil {
+		t.Fatalf("Search substring: %v", err)
+	}
+	if len(hits) != 1 || len(hits[0].Fragments) != 1 {
+		t.Fatalf("substring fragments = %+v, want one", hits)
+	}
+	fr := hits[0].Fragments[0]
+	if !utf8.ValidString(fr.Text) || n.Text[fr.Start:fr.End] != fr.Text {
+		t.Fatalf("substring fragment %q does not match offsets %d:%d", fr.Text, fr.Start, fr.End)
+	}
+	if got := n.Text[fr.Highlights[0].Start:fr.Highlights[0].End]; got != "ЁЖИКОВ" {
+		t.Fatalf("substring highlight = %q, want %q", got, "ЁЖИКОВ")
+	}
+}
+
+func TestSearchQuery(t *testing.T) {
+	root := t.TempDir()
+	s, err := Open(root)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer s.Close()
+
+	at := func(n *model.Note, day int) *model.Note {
+		n.CreatedAt = time.Date(2025, 1, day, 12, 0, 0, 0, time.UTC)
+		n.UpdatedAt = n.CreatedAt
+		return n
+	}
+	deploy := at(model.NewNote("Deploy checklist", "steps for the release", []string{"work"}), 10)
+	old := at(model.NewNote("Deploy v1", "first release, exact phrase inside", []string{"work", "archived"}), 2)
+	incident := at(model.NewNote("Outage", "incident report about the release", []string{"work"}), 12)
+	home := at(model.NewNote("Garden", "plant the kiwi and deploy the hose", []string{"home"}), 15)
+	for _, n := range []*model.Note{deploy, old, incident, home} {
+		if err := s.Append(n); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+
+	ids := func(q string) []string {
+		t.Helper()
+		hits, err := s.Search(Filter{Query: q, Sort: SortCreated})
+		if err != nil {
+			t.Fatalf("Search(%q): %v", q, err)
+		}
+		out := make([]string, len(hits))
+		for i, h := range hits {
+			out[i] = h.ID
+		}
+		return out
+	}
+	cases := []struct {
+		q    string
+		want []string
+	}{
+		{`title:deploy`, []string{deploy.ID, old.ID}},
+		{`deploy`, []string{home.ID, deploy.ID, old.ID}},
+		{`tag:work -tag:archived`, []string{incident.ID, deploy.ID}},
+		{`title:deploy OR incident`, []string{incident.ID, deploy.ID, old.ID}},
+		{`tag:work created:>2025-01-02 (title:deploy OR incident)`, []string{incident.ID, deploy.ID}},
+		{`"exact phrase" OR incident`, []string{incident.ID, old.ID}},
+		{`-deploy`, []string{incident.ID}},
+		{`created:2025-01-10..2025-01-12 release`, []string{incident.ID, deploy.ID}},
+		// Подстрока, которой нет среди слов индекса, ищется перебором.
+		{`title:checkl`, []string{deploy.ID}},
+	}
+	for _, c := range cases {
+		got := ids(c.q)
+		if strings.Join(got, ",") != strings.Join(c.want, ",") {
+			t.Fatalf("Search(%q) = %v, want %v", c.q, got, c.want)
+		}
+	}
+
+	// Tag и Contains фильтра объединяются с запросом через AND, а
+	// Contains не разбирается как запрос.
+	hits, err := s.Search(Filter{Tags: []string{"home"}, Query: "deploy"})
+	if err != nil || len(hits) != 1 || hits[0].ID != home.ID {
+		t.Fatalf("Search with Tag and Query = %v, %v", hits, err)
+	}
+	hits, err = s.Search(Filter{Contains: "tag:work"})
+	if err != nil || len(hits) != 0 {
+		t.Fatalf("Contains was parsed as a query: %v, %v", hits, err)
+	}
+
+	hits, err = s.Search(Filter{Query: `title:deploy -tag:archived`})
+	if err != nil || len(hits) != 1 {
+		t.Fatalf("Search: %v, %v", hits, err)
+	}
+	if hits[0].Score <= 0 || len(hits[0].Fragments) != 1 || hits[0].Fragments[0].Field != "title" {
+		t.Fatalf("hit = %+v, want a scored title fragment", hits[0])
+	}
+
+	if _, err := s.Search(Filter{Query: "title:(deploy"}); !errors.Is(err, ErrBadQuery) {
+		t.Fatalf("Search with broken query: %v, want ErrBadQuery", err)
+	}
+}
+
+func TestSearchTimeBounds(t *testing.T) {
+	root := t.TempDir()
+	s, err := Open(root)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+
+	base := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	var notes []*model.Note
+	for i := 0; i < 5; i++ {
+		n := model.NewNote(fmt.Sprintf("Note %d", i), "text", nil)
+		n.CreatedAt = base.AddDate(0, 0, i)
+		n.UpdatedAt = n.CreatedAt
+		notes = append(notes, n)
+		if err := s.Append(n); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+	// Заметка 0 изменена позже всех.
+	edited := *notes[0]
+	edited.Text = "edited"
+	edited.UpdatedAt = base.AddDate(0, 0, 10)
+	if err := s.Append(&edited); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	// Удалённая заметка не находится даже по дате.
+	gone := *notes[4]
+	gone.Deleted = true
+	gone.UpdatedAt = base.AddDate(0, 0, 11)
+	if err := s.Append(&gone); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+
+	titles := func(f Filter) string {
+		t.Helper()
+		f.Sort = SortCreated
+		hits, err := s.Search(f)
+		if err != nil {
+			t.Fatalf("Search(%+v): %v", f, err)
+		}
+		var out []string
+		for _, h := range hits {
+			out = append(out, strings.TrimPrefix(h.Title, "Note "))
+		}
+		return strings.Join(out, ",")
+	}
+	cases := []struct {
+		f    Filter
+		want string
+	}{
+		{Filter{Since: base.AddDate(0, 0, 2)}, "3,2"},
+		{Filter{Until: base.AddDate(0, 0, 1)}, "1,0"},
+		{Filter{Since: base.AddDate(0, 0, 1), Until: base.AddDate(0, 0, 3)}, "3,2,1"},
+		{Filter{UpdatedSince: base.AddDate(0, 0, 5)}, "0"},
+		{Filter{Since: base.AddDate(0, 0, 1), Query: "created:<=2025-01-02 OR edited"}, "1"},
+		{Filter{Query: "updated:>2025-01-03"}, "3,0"},
+	}
+	for _, c := range cases {
+		if got := titles(c.f); got != c.want {
+			t.Fatalf("Search(%+v) = %q, want %q", c.f, got, c.want)
+		}
+	}
+
+	// Индекс времени переживает переоткрытие хранилища.
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	s, err = Open(root)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer s.Close()
+	if got := titles(Filter{Since: base.AddDate(0, 0, 2)}); got != "3,2" {
+		t.Fatalf("after reopen Search = %q", got)
+	}
+	if o := s.idx.order(false); len(o.ids) != 4 {
+		t.Fatalf("time order has %d notes, want 4", len(o.ids))
+	}
+}
+
+func TestSearchTagSets(t *testing.T) {
+	s, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer s.Close()
+
+	base := time.Now().UTC().Add(-time.Hour)
+	tagged := map[string][]string{
+		"a": {"work", "urgent"},
+		"b": {"work"},
+		"c": {"home", "urgent"},
+		"d": {"work", "urgent", "archived"},
+		"e": nil,
+	}
+	for i, title := range []string{"a", "b", "c", "d", "e"} {
+		n := model.NewNote(title, "deploy", tagged[title])
+		n.CreatedAt = base.Add(time.Duration(i) * time.Minute)
+		n.UpdatedAt = n.CreatedAt
+		if err := s.Append(n); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+
+	cases := []struct {
+		f    Filter
+		want string
+	}{
+		{Filter{Tags: []string{"work", "urgent"}}, "d,a"},
+		{Filter{AnyTags: []string{"home", "archived"}}, "d,c"},
+		{Filter{NotTags: []string{"archived", "home"}}, "e,b,a"},
+		{Filter{Tags: []string{"work", "urgent"}, NotTags: []string{"archived"}}, "a"},
+		{Filter{Tags: []string{"urgent"}, AnyTags: []string{"home", "missing"}}, "c"},
+		{Filter{Tags: []string{" work ", ""}, Contains: "deploy", NotTags: []string{"urgent"}}, "b"},
+	}
+	for _, c := range cases {
+		c.f.Sort = SortCreated
+		hits, err := s.Search(c.f)
+		if err != nil {
+			t.Fatalf("Search(%+v): %v", c.f, err)
+		}
+		var got []string
+		for _, h := range hits {
+			got = append(got, h.Title)
+		}
+		if strings.Join(got, ",") != c.want {
+			t.Fatalf("Search(%+v) = %v, want %s", c.f, got, c.want)
+		}
+	}
+}
+
+func TestSearchWhere(t *testing.T) {
+	s, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer s.Close()
+
+	base := time.Now().UTC().Add(-time.Hour)
+	meta := map[string]map[string]any{
+		"a": {"status": "Open", "points": float64(3), "due": "2025-03-01"},
+		"b": {"status": "closed", "points": float64(10), "labels": []any{"x", "open"}},
+		"c": {"status": "open", "params": map[string]any{"owner": "ann"}},
+		"d": nil,
+	}
+	for i, title := range []string{"a", "b", "c", "d"} {
+		n := model.NewNote(title, "deploy", nil)
+		n.Meta = meta[title]
+		n.CreatedAt = base.Add(time.Duration(i) * time.Minute)
+		n.UpdatedAt = n.CreatedAt
+		if err := s.Append(n); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+
+	cases := []struct {
+		f    Filter
+		want string
+	}{
+		{Filter{Where: []string{"status=open"}}, "a,c"},
+		{Filter{Where: []string{"status!=open"}}, "b,d"},
+		{Filter{Where: []string{"points>=3", "points<10"}}, "a"},
+		{Filter{Where: []string{"points>4"}}, "b"},
+		{Filter{Where: []string{"labels=OPEN"}}, "b"},
+		{Filter{Where: []string{"params.owner=ann"}}, "c"},
+		{Filter{Where: []string{"due"}}, "a"},
+		{Filter{Where: []string{"due<2025-06"}}, "a"},
+		{Filter{Where: []string{"status=open"}, Query: "meta.points:3 OR meta.params.owner:*"}, "a,c"},
+		{Filter{Query: "open"}, "a,b,c"},
+	}
+	for _, c := range cases {
+		c.f.Sort = SortCreated
+		hits, err := s.Search(c.f)
+		if err != nil {
+			t.Fatalf("Search(%+v): %v", c.f, err)
+		}
+		var got []string
+		for _, h := range hits {
+			got = append(got, h.Title)
+		}
+		slices.Sort(got)
+		if strings.Join(got, ",") != c.want {
+			t.Fatalf("Search(%+v) = %v, want %s", c.f, got, c.want)
+		}
+	}
+
+	if _, err := s.Search(Filter{Where: []string{"title=x"}}); !errors.Is(err, ErrBadQuery) {
+		t.Fatalf("Search(where title) error = %v, want ErrBadQuery", err)
+	}
+}
@@ -4,10 +4,8 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"os"
 	"path/filepath"
-	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -24,16 +22,29 @@ const (
 	filenameManifest = "manifest.json"
 
 	defaultSegSize int = 8 * 1024 * 1024
+
+	fsyncAlways   = "always"
+	fsyncInterval = "interval"
+	fsyncNever    = "never"
+
+	defaultFsyncIntervalMs = 1000
 )
 
 var ErrNotFound = errors.New("note not found")
+var ErrEmptyID = errors.New("empty note id")
+var ErrUnknownLanguage = errors.New("unknown search language")
 var noteCache *lru.LRU
 
 type manifest struct {
-	Version          int   `json:"version"`
-	SegmentSizeBytes int   `json:"segment_size_bytes"`
-	NextSegmentSeq   int   `json:"next_segment_seq"`
-	CreatedAtUnix    int64 `json:"created_at_unix"`
+	Version           int                `json:"version"`
+	SegmentSizeBytes  int                `json:"segment_size_bytes"`
+	NextSegmentSeq    int                `json:"next_segment_seq"`
+	CreatedAtUnix     int64              `json:"created_at_unix"`
+	Fsync             string             `json:"fsync,omitempty"`
+	FsyncIntervalMs   int                `json:"fsync_interval_ms,omitempty"`
+	PendingCompaction *pendingCompaction `json:"pending_compaction,omitempty"`
+	// Language — язык анализаторов полнотекстового поиска: en или ru.
+	Language string `json:"language,omitempty"`
 }
 
 type Store struct {
@@ -42,12 +53,37 @@ type Store struct {
 	active    *os.File
 	activeNo  int
 	cacheFile string
+	idx       *idIndex
+	idxDirty  bool
+	readers   map[int]*os.File
+	lastSync  time.Time
+	recovery  *RecoveryReport
+	lock      *fileLock
+	readOnly  bool
 }
 
 type Filter struct {
-	Tag      string
+	// Tags — теги, которые должны быть у заметки все, AnyTags — хотя бы
+	// один из них, NotTags — ни одного. Теги сравниваются по иерархии:
+	// тег project подходит и к заметкам с project/noteline.
+	Tags, AnyTags, NotTags []string
+	// Contains — слова, которые должны встретиться в заметке; синтаксис
+	// запросов в нём не разбирается.
 	Contains string
-	Limit    int
+	// Query — запрос на языке из query.go.
+	Query string
+	// Where — условия на поля Meta: key=value, key!=value, key>value,
+	// key>=value, key<value, key<=value или key (поле задано); все
+	// должны выполняться.
+	Where []string
+	// Since и Until ограничивают время создания включительно,
+	// UpdatedSince — время последнего изменения; нулевое время
+	// означает отсутствие границы.
+	Since, Until, UpdatedSince time.Time
+
+	Limit int
+	// Sort — SortRelevance, SortCreated, SortUpdated или SortTitle.
+	Sort string
 }
 
 func Ensure(root string) error {
@@ -67,6 +103,9 @@ func Ensure(root string) error {
 			SegmentSizeBytes: defaultSegSize,
 			NextSegmentSeq:   1,
 			CreatedAtUnix:    time.Now().UTC().Unix(),
+			Fsync:            fsyncAlways,
+			FsyncIntervalMs:  defaultFsyncIntervalMs,
+			Language:         defaultLanguage(),
 		}
 		f, err := os.Create(manPath)
 		if err != nil {
@@ -83,7 +122,24 @@ func Ensure(root string) error {
 	return nil
 }
 
+// defaultLanguage выбирает язык поиска для нового хранилища по языку
+// интерфейса.
+func defaultLanguage() string {
+	if strings.HasPrefix(i18n.Locale(), fts.LangRussian) {
+		return fts.LangRussian
+	}
+	return fts.LangEnglish
+}
+
 func Open(root string) (*Store, error) {
+	return OpenWith(root, Options{})
+}
+
+// OpenWith открывает хранилище под межпроцессной блокировкой noteline.lock.
+// Эксклюзивный режим нужен для записи; в разделяемом режиме хранилище
+// открывается только для чтения и может быть открыто несколькими
+// процессами сразу.
+func OpenWith(root string, opts Options) (*Store, error) {
 	if strings.TrimSpace(root) == "" {
 		home, _ := os.UserHomeDir()
 		root = filepath.Join(home, ".noteline")
@@ -91,60 +147,242 @@ func Open(root string) (*Store, error) {
 	if err := Ensure(root); err != nil {
 		return nil, err
 	}
+	if opts.Lock == LockShared {
+		return openShared(root, opts)
+	}
 
-	var man manifest
-	b, err := os.ReadFile(filepath.Join(root, filenameManifest))
+	lock, err := acquireLock(root, LockExclusive, opts.lockTimeout())
 	if err != nil {
 		return nil, err
 	}
-	if err := json.Unmarshal(b, &man); err != nil {
+	s := &Store{root: root, lock: lock}
+	if err := s.openExclusive(); err != nil {
+		s.closeReaders()
+		_ = lock.release()
 		return nil, err
 	}
+	return s, nil
+}
 
-	s := &Store{
-		root: root,
-		man:  man,
+func (s *Store) readManifest() error {
+	b, err := os.ReadFile(filepath.Join(s.root, filenameManifest))
+	if err != nil {
+		return err
 	}
+	return json.Unmarshal(b, &s.man)
+}
 
-	noteCache = lru.New(4096)
-	s.cacheFile = filepath.Join(root, "lru_cache.json")
-	s.loadCacheFromDisk()
+func (s *Store) openExclusive() error {
+	if err := s.readManifest(); err != nil {
+		return err
+	}
+
+	if s.man.PendingCompaction != nil {
+		if err := s.finishCompaction(); err != nil {
+			return err
+		}
+	}
+
+	s.loadIDIndex()
+	if err := s.recoverTail(); err != nil {
+		return err
+	}
+	if err := s.syncIDIndex(); err != nil {
+		return err
+	}
+
+	s.loadCache()
 
-	if err := fts.Init(root); err != nil {
+	fresh, err := fts.Init(s.root, s.Language())
+	if err != nil {
 		fmt.Fprintf(os.Stderr, "%s\n", i18n.T("warning.fulltext_init_failed", err))
+	} else if fresh && len(s.idx.Entries) > 0 {
+		// Индекс создан заново (его не было или поменялась схема):
+		// заполняем его заметками из сегментов.
+		n, err := s.fillFulltext()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", i18n.T("warning.fulltext_init_failed", err))
+		} else if n > 0 {
+			fmt.Fprintf(os.Stderr, "%s\n", i18n.T("warning.fulltext_rebuilt", n))
+		}
 	}
 
-	if err := s.openActiveSegmentRW(); err != nil {
+	return s.openActiveSegmentRW()
+}
+
+func (s *Store) liveNotes() ([]model.Note, error) {
+	all, err := s.loadAllNotes()
+	if err != nil {
 		return nil, err
 	}
+	notes := make([]model.Note, 0, len(all))
+	for _, n := range all {
+		notes = append(notes, n)
+	}
+	return notes, nil
+}
 
-	return s, nil
+func (s *Store) fillFulltext() (int, error) {
+	notes, err := s.liveNotes()
+	if err != nil {
+		return 0, err
+	}
+	return len(notes), fts.IndexNotes(notes)
+}
+
+// Language возвращает язык анализаторов полнотекстового поиска.
+func (s *Store) Language() string {
+	if fts.ValidLanguage(s.man.Language) {
+		return s.man.Language
+	}
+	return fts.LangEnglish
+}
+
+// SetLanguage меняет язык полнотекстового поиска и перестраивает индекс.
+func (s *Store) SetLanguage(lang string) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	if !fts.ValidLanguage(lang) {
+		return fmt.Errorf("%w: %q", ErrUnknownLanguage, lang)
+	}
+	if s.man.Language == lang {
+		return nil
+	}
+	s.man.Language = lang
+	if err := s.saveManifest(); err != nil {
+		return err
+	}
+	_, err := s.Reindex()
+	return err
+}
+
+// openShared открывает хранилище только для чтения. Если хранилищу нужно
+// обслуживание (незавершённое сжатие, оборванный хвост, полнотекстового
+// индекса нет или его схема устарела), оно выполняется под эксклюзивной
+// блокировкой, после чего разделяемая блокировка берётся заново.
+func openShared(root string, opts Options) (*Store, error) {
+	const attempts = 3
+	for i := 1; ; i++ {
+		lock, err := acquireLock(root, LockShared, opts.lockTimeout())
+		if err != nil {
+			return nil, err
+		}
+		s := &Store{root: root, lock: lock, readOnly: true}
+		needs, err := s.loadShared()
+		last := i == attempts
+		if err == nil && (!needs || last) {
+			s.loadCache()
+			ferr := fts.InitReadOnly(root, s.Language())
+			if !errors.Is(ferr, fts.ErrMappingChanged) || last {
+				if ferr != nil {
+					fmt.Fprintf(os.Stderr, "%s\n", i18n.T("warning.fulltext_init_failed", ferr))
+				}
+				return s, nil
+			}
+			// Схема индекса устарела: его перестроит эксклюзивное открытие.
+		}
+		s.closeReaders()
+		_ = lock.release()
+		if err != nil {
+			return nil, err
+		}
+
+		w, err := OpenWith(root, Options{Lock: LockExclusive, LockTimeout: opts.LockTimeout})
+		if err != nil {
+			return nil, err
+		}
+		if err := w.Close(); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// loadShared читает манифест и индекс, ничего не меняя на диске, и
+// сообщает, нужно ли хранилищу обслуживание.
+func (s *Store) loadShared() (bool, error) {
+	if err := s.readManifest(); err != nil {
+		return false, err
+	}
+	if s.man.PendingCompaction != nil {
+		return true, nil
+	}
+	if _, err := os.Stat(filepath.Join(s.root, "index.bleve")); err != nil {
+		return true, nil
+	}
+	s.loadIDIndex()
+	t, err := s.checkTail()
+	if err != nil {
+		return false, err
+	}
+	if t.dirty() {
+		return true, nil
+	}
+	return false, s.syncIDIndex()
+}
+
+func (s *Store) loadCache() {
+	noteCache = lru.New(4096)
+	s.cacheFile = filepath.Join(s.root, "lru_cache.json")
+	s.loadCacheFromDisk()
+}
+
+// ReadOnly сообщает, открыто ли хранилище в разделяемом режиме.
+func (s *Store) ReadOnly() bool {
+	return s.readOnly
 }
 
 func (s *Store) Close() error {
 	var err1 error
 
-	s.saveCacheToDisk()
+	if !s.readOnly {
+		s.saveCacheToDisk()
+
+		if err := s.saveIDIndex(); err != nil {
+			err1 = err
+		}
+	}
+	s.closeReaders()
 
 	if s.active != nil {
-		err1 = s.active.Close()
+		if s.fsyncPolicy() != fsyncNever {
+			if err := s.active.Sync(); err != nil {
+				err1 = err
+			}
+		}
+		if err := s.active.Close(); err != nil {
+			err1 = err
+		}
+		s.active = nil
 	}
 
 	if err := fts.Close(); err != nil {
 		fmt.Fprintf(os.Stderr, "%s\n", i18n.T("warning.fulltext_close_error", err))
 	}
+	if err := s.lock.release(); err != nil && err1 == nil {
+		err1 = err
+	}
 	return err1
 }
 
-func (s *Store) openActiveSegmentRW() error {
+func (s *Store) segmentFiles() []string {
 	segDir := filepath.Join(s.root, dirSegments)
 	files, _ := filepath.Glob(filepath.Join(segDir, "notes-*.ndjson"))
+	sort.Strings(files)
+	return files
+}
+
+func (s *Store) segmentPath(no int) string {
+	return filepath.Join(s.root, dirSegments, fmt.Sprintf("notes-%08d.ndjson", no))
+}
+
+func (s *Store) openActiveSegmentRW() error {
+	files := s.segmentFiles()
 	if len(files) == 0 {
 
 		return s.rotate()
 	}
 
-	sort.Strings(files)
 	last := files[len(files)-1]
 	f, err := os.OpenFile(last, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0o644)
 	if err != nil {
@@ -168,9 +406,7 @@ func parseSeqFromName(name string) (int, error) {
 }
 
 func (s *Store) rotate() error {
-	segDir := filepath.Join(s.root, dirSegments)
-	name := fmt.Sprintf("notes-%08d.ndjson", s.man.NextSegmentSeq)
-	path := filepath.Join(segDir, name)
+	path := s.segmentPath(s.man.NextSegmentSeq)
 
 	f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_RDWR, 0o644)
 	if err != nil {
@@ -178,6 +414,9 @@ func (s *Store) rotate() error {
 	}
 
 	if s.active != nil {
+		if s.fsyncPolicy() != fsyncNever {
+			_ = s.active.Sync()
+		}
 		_ = s.active.Close()
 	}
 
@@ -185,39 +424,185 @@ func (s *Store) rotate() error {
 	s.activeNo = s.man.NextSegmentSeq
 	s.man.NextSegmentSeq++
 
+	return s.saveManifest()
+}
+
+// saveManifest пишет manifest.json через временный файл и rename,
+// чтобы при сбое на диске оставалась либо старая, либо новая версия.
+func (s *Store) saveManifest() error {
 	b, err := json.MarshalIndent(s.man, "", "  ")
 	if err != nil {
 		return err
 	}
-	return os.WriteFile(filepath.Join(s.root, filenameManifest), b, 0o644)
+	return writeFileAtomic(filepath.Join(s.root, filenameManifest), b)
+}
+
+// writeFileAtomic пишет во временный файл с уникальным именем и
+// переименовывает его поверх path, так что читатель видит либо старое,
+// либо новое содержимое целиком.
+func writeFileAtomic(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	ok := false
+	defer func() {
+		if !ok {
+			_ = os.Remove(tmp)
+		}
+	}()
+
+	if _, err := f.Write(data); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp, 0o644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return err
+	}
+	ok = true
+	return nil
+}
+
+func (s *Store) fsyncPolicy() string {
+	switch s.man.Fsync {
+	case fsyncInterval, fsyncNever:
+		return s.man.Fsync
+	default:
+		return fsyncAlways
+	}
+}
+
+func (s *Store) syncAfterWrite() error {
+	switch s.fsyncPolicy() {
+	case fsyncNever:
+		return nil
+	case fsyncInterval:
+		interval := time.Duration(s.man.FsyncIntervalMs) * time.Millisecond
+		if interval <= 0 {
+			interval = defaultFsyncIntervalMs * time.Millisecond
+		}
+		if time.Since(s.lastSync) < interval {
+			return nil
+		}
+	}
+	if err := s.active.Sync(); err != nil {
+		return err
+	}
+	s.lastSync = time.Now()
+	return nil
 }
 
 func (s *Store) Append(n *model.Note) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	if err := s.writeRecord(n); err != nil {
+		return err
+	}
+	if err := s.syncAfterWrite(); err != nil {
+		return err
+	}
+
+	// Удалённая заметка не должна находиться поиском и занимать место
+	// в результатах.
+	var err error
+	if n.Deleted {
+		err = fts.DeleteNote(n.ID)
+	} else {
+		err = fts.IndexNote(n)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", i18n.T("warning.fulltext_index_update_failed", n.ID, err))
+	}
+
+	return nil
+}
+
+// AppendBatch дописывает версии нескольких заметок подряд: лог
+// синхронизируется один раз, а полнотекстовый индекс обновляется одной
+// пачкой. Если запись прервалась, уже записанные версии остаются в логе
+// и попадают в индекс.
+func (s *Store) AppendBatch(notes []*model.Note) error {
+	if s.readOnly {
+		return ErrReadOnly
+	}
+	var written []*model.Note
+	var werr error
+	for _, n := range notes {
+		if werr = s.writeRecord(n); werr != nil {
+			break
+		}
+		written = append(written, n)
+	}
+	if len(written) == 0 {
+		return werr
+	}
+	if err := s.syncAfterWrite(); err != nil {
+		return err
+	}
+
+	var live []model.Note
+	var deleted []string
+	for _, n := range written {
+		if n.Deleted {
+			deleted = append(deleted, n.ID)
+		} else {
+			live = append(live, *n)
+		}
+	}
+	if err := fts.ApplyBatch(live, deleted); err != nil {
+		fmt.Fprintf(os.Stderr, "%s\n", i18n.T("warning.fulltext_index_update_failed", written[0].ID, err))
+	}
+	return werr
+}
+
+// writeRecord пишет запись в активный сегмент и обновляет индекс ID и
+// кэш; синхронизацию и полнотекстовый индекс оставляет вызывающему.
+func (s *Store) writeRecord(n *model.Note) error {
+	// Запись без id decodeRecord счёл бы битой.
+	if n.ID == "" {
+		return ErrEmptyID
+	}
 	if s.active == nil {
 		if err := s.openActiveSegmentRW(); err != nil {
 			return err
 		}
 	}
 
-	b, err := json.Marshal(n)
+	n.Version = s.idx.Entries[n.ID].Version + 1
+	b, err := encodeRecord(n)
 	if err != nil {
 		return err
 	}
 
-	if st, err := s.active.Stat(); err == nil {
-		if st.Size()+int64(len(b)+1) > int64(s.man.SegmentSizeBytes) {
-			if err := s.rotate(); err != nil {
-				return err
-			}
+	st, err := s.active.Stat()
+	if err != nil {
+		return err
+	}
+	off := st.Size()
+	if off > 0 && off+int64(len(b)) > int64(s.man.SegmentSizeBytes) {
+		if err := s.rotate(); err != nil {
+			return err
 		}
+		off = 0
 	}
 
 	if _, err := s.active.Write(b); err != nil {
 		return err
 	}
-	if _, err := s.active.Write([]byte("\n")); err != nil {
-		return err
-	}
+	s.idx.apply(s.activeNo, off, len(b), *n)
+	s.idxDirty = true
 
 	if noteCache != nil {
 		if n.Deleted {
@@ -227,48 +612,21 @@ func (s *Store) Append(n *model.Note) error {
 			noteCache.Add(n.ID, &noteCopy)
 		}
 	}
-
-	if err := fts.IndexNote(n); err != nil {
-		fmt.Fprintf(os.Stderr, "%s\n", i18n.T("warning.fulltext_index_update_failed", n.ID, err))
-	}
-
 	return nil
 }
 
 func (s *Store) loadAllNotes() (map[string]model.Note, error) {
-	segDir := filepath.Join(s.root, dirSegments)
-	files, _ := filepath.Glob(filepath.Join(segDir, "notes-*.ndjson"))
-	sort.Strings(files)
-
-	notes := make(map[string]model.Note)
+	notes := make(map[string]model.Note, len(s.idx.Entries))
 
-	for _, path := range files {
-		f, err := os.Open(path)
-		if err != nil {
+	for id, e := range s.idx.Entries {
+		if e.Deleted {
 			continue
 		}
-
-		dec := json.NewDecoder(f)
-		for {
-			var n model.Note
-			if err := dec.Decode(&n); err != nil {
-				if errors.Is(err, io.EOF) {
-					break
-				}
-
-				continue
-			}
-
-			if n.Deleted {
-
-				delete(notes, n.ID)
-				continue
-			}
-
-			notes[n.ID] = n
+		n, err := s.readEntry(e)
+		if err != nil {
+			continue
 		}
-
-		_ = f.Close()
+		notes[id] = n
 	}
 
 	return notes, nil
@@ -276,8 +634,9 @@ func (s *Store) loadAllNotes() (map[string]model.Note, error) {
 
 func (s *Store) GetByID(id string) (*model.Note, error) {
 	if noteCache != nil {
+		// Копия из кэша, расходящаяся с индексом по версии, устарела.
 		if v, ok := noteCache.Get(id); ok {
-			if n, ok2 := v.(*model.Note); ok2 {
+			if n, ok2 := v.(*model.Note); ok2 && n.Version == s.idx.Entries[id].Version {
 				if !n.Deleted {
 					nCopy := *n
 					return &nCopy, nil
@@ -287,14 +646,10 @@ func (s *Store) GetByID(id string) (*model.Note, error) {
 		}
 	}
 
-	notes, err := s.loadAllNotes()
+	n, err := s.lookup(id)
 	if err != nil {
 		return nil, err
 	}
-	n, ok := notes[id]
-	if !ok {
-		return nil, ErrNotFound
-	}
 
 	if noteCache != nil {
 		nCopy := n
@@ -305,83 +660,36 @@ func (s *Store) GetByID(id string) (*model.Note, error) {
 	return &nCopy, nil
 }
 
+// List возвращает заметки, по умолчанию от новых к старым.
 func (s *Store) List(filter Filter) ([]model.Note, error) {
-	filter.Tag = strings.TrimSpace(filter.Tag)
-	filter.Contains = strings.TrimSpace(filter.Contains)
-
-	if filter.Contains != "" {
-		ids, err := fts.Search(filter.Contains, filter.Limit)
-		if err == nil && len(ids) > 0 {
-			var out []model.Note
-			seen := make(map[string]bool)
-
-			for _, id := range ids {
-				if filter.Limit > 0 && len(out) >= filter.Limit {
-					break
-				}
-				if seen[id] {
-					continue
-				}
-				n, err := s.GetByID(id)
-				if err != nil {
-					continue
-				}
-				if filter.Tag != "" && !slices.Contains(n.Tags, filter.Tag) {
-					continue
-				}
-				out = append(out, *n)
-				seen[id] = true
-			}
-
-			sort.SliceStable(out, func(i, j int) bool {
-				return out[i].CreatedAt.After(out[j].CreatedAt)
-			})
-			return out, nil
-		}
-
+	if filter.Sort == "" {
+		filter.Sort = SortCreated
 	}
-
-	notes, err := s.loadAllNotes()
+	hits, err := s.Search(filter)
 	if err != nil {
 		return nil, err
 	}
-
-	var out []model.Note
-	for _, n := range notes {
-
-		if filter.Tag != "" {
-			found := false
-			for _, t := range n.Tags {
-				if t == filter.Tag {
-					found = true
-					break
-				}
-			}
-			if !found {
-				continue
-			}
-		}
-
-		if filter.Contains != "" {
-			hay := strings.ToLower(n.Title + " " + n.Text)
-			needle := strings.ToLower(filter.Contains)
-			if !strings.Contains(hay, needle) {
-				continue
-			}
-		}
-
-		out = append(out, n)
+	out := make([]model.Note, 0, len(hits))
+	for _, h := range hits {
+		out = append(out, h.Note)
 	}
+	return out, nil
+}
 
-	sort.Slice(out, func(i, j int) bool {
-		return out[i].CreatedAt.After(out[j].CreatedAt)
-	})
-
-	if filter.Limit > 0 && len(out) > filter.Limit {
-		out = out[:filter.Limit]
+// Reindex строит полнотекстовый индекс заново по живым заметкам из
+// сегментов и возвращает число проиндексированных заметок.
+func (s *Store) Reindex() (int, error) {
+	if s.readOnly {
+		return 0, ErrReadOnly
 	}
-
-	return out, nil
+	notes, err := s.liveNotes()
+	if err != nil {
+		return 0, err
+	}
+	if err := fts.Rebuild(s.root, s.Language(), notes); err != nil {
+		return 0, err
+	}
+	return len(notes), nil
 }
 
 func (s *Store) loadCacheFromDisk() {
@@ -426,5 +734,5 @@ func (s *Store) saveCacheToDisk() {
 	if err != nil {
 		return
 	}
-	_ = os.WriteFile(s.cacheFile, b, 0o644)
+	_ = writeFileAtomic(s.cacheFile, b)
 }
@@ -9,6 +9,7 @@ import (
 	"testing"
 	"time"
 
+	fts "github.com/Victor3563/NoteLine/cli-notebook/internal/fulltext"
 	"github.com/Victor3563/NoteLine/cli-notebook/internal/model"
 )
 
@@ -52,6 +53,28 @@ func TestAppendAndGetByID(t *testing.T) {
 	}
 }
 
+func TestAppendRejectsEmptyID(t *testing.T) {
+	root := t.TempDir()
+	s, err := Open(root)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer s.Close()
+
+	n := model.NewNote("Title", "Body", nil)
+	n.ID = ""
+	if err := s.Append(n); !errors.Is(err, ErrEmptyID) {
+		t.Fatalf("Append without id = %v, want ErrEmptyID", err)
+	}
+	if err := s.AppendBatch([]*model.Note{n}); !errors.Is(err, ErrEmptyID) {
+		t.Fatalf("AppendBatch without id = %v, want ErrEmptyID", err)
+	}
+	rep, err := s.Fsck(false)
+	if err != nil || len(rep.Issues) != 0 || rep.Records != 0 {
+		t.Fatalf("Fsck after rejected append = %+v, %v", rep, err)
+	}
+}
+
 func TestListWithTagAndContains(t *testing.T) {
 	root := t.TempDir()
 	s, err := Open(root)
@@ -70,7 +93,7 @@ func TestListWithTagAndContains(t *testing.T) {
 		t.Fatalf("Append n2: %v", err)
 	}
 
-	list, err := s.List(Filter{Tag: "go"})
+	list, err := s.List(Filter{Tags: []string{"go"}})
 	if err != nil {
 		t.Fatalf("List(Tag): %v", err)
 	}
@@ -154,6 +177,58 @@ func TestSoftDelete(t *testing.T) {
 	if _, err := s.GetByID(n.ID); !errors.Is(err, ErrNotFound) {
 		t.Fatalf("GetByID after delete = %v, want ErrNotFound", err)
 	}
+
+	docs, err := fts.DocIDs()
+	if err != nil {
+		t.Fatalf("DocIDs: %v", err)
+	}
+	if len(docs) != 0 {
+		t.Fatalf("deleted note still in full-text index: %v", docs)
+	}
+}
+
+func TestReindexRebuildsFromSegments(t *testing.T) {
+	root := t.TempDir()
+	s, err := Open(root)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer s.Close()
+
+	for i := 0; i < 3; i++ {
+		if err := s.Append(model.NewNote(fmt.Sprintf("Note %d", i), "searchable body", nil)); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+	dead := model.NewNote("Dead", "searchable body", nil)
+	if err := s.Append(dead); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	tomb := *dead
+	tomb.Deleted = true
+	if err := s.Append(&tomb); err != nil {
+		t.Fatalf("Append tombstone: %v", err)
+	}
+
+	// Индекс, испорченный снаружи, должен исправиться.
+	if err := fts.IndexNote(&tomb); err != nil {
+		t.Fatalf("IndexNote: %v", err)
+	}
+
+	n, err := s.Reindex()
+	if err != nil {
+		t.Fatalf("Reindex: %v", err)
+	}
+	if n != 3 {
+		t.Fatalf("Reindex indexed %d notes, want 3", n)
+	}
+	list, err := s.List(Filter{Contains: "searchable", Limit: 3})
+	if err != nil {
+		t.Fatalf("List: %v", err)
+	}
+	if len(list) != 3 {
+		t.Fatalf("List returned %d notes, want 3", len(list))
+	}
 }
 
 func TestRotateCreatesNewSegment(t *testing.T) {
@@ -182,3 +257,49 @@ func TestRotateCreatesNewSegment(t *testing.T) {
 		t.Fatalf("expected at least 2 segment files after rotation, got %d", len(files))
 	}
 }
+
+func TestLanguageChangeRebuildsFulltext(t *testing.T) {
+	root := t.TempDir()
+	s, err := Open(root)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	if got := s.Language(); got != fts.LangEnglish {
+		t.Fatalf("Language = %q, want %q", got, fts.LangEnglish)
+	}
+	n := model.NewNote("Сервисы", "развёртывание сервисов", nil)
+	if err := s.Append(n); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	if err := s.SetLanguage("de"); !errors.Is(err, ErrUnknownLanguage) {
+		t.Fatalf("SetLanguage(de): %v, want ErrUnknownLanguage", err)
+	}
+	if err := s.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+
+	// Язык поменяли в манифесте вручную: читатель должен получить индекс,
+	// перестроенный под русский анализатор.
+	manPath := filepath.Join(root, filenameManifest)
+	b, err := os.ReadFile(manPath)
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	b = []byte(strings.Replace(string(b), `"language": "en"`, `"language": "ru"`, 1))
+	if err := os.WriteFile(manPath, b, 0o644); err != nil {
+		t.Fatalf("WriteFile: %v", err)
+	}
+
+	r, err := OpenWith(root, Options{Lock: LockShared, LockTimeout: -1})
+	if err != nil {
+		t.Fatalf("shared Open: %v", err)
+	}
+	defer r.Close()
+	hits, err := r.Search(Filter{Contains: "сервис"})
+	if err != nil {
+		t.Fatalf("Search: %v", err)
+	}
+	if len(hits) != 1 || hits[0].Score == 0 {
+		t.Fatalf("Search after language change = %+v, want one ranked hit", hits)
+	}
+}
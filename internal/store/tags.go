@@ -0,0 +1,177 @@
+package store
+
+import (
+	"errors"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/model"
+)
+
+// TagCount — узел иерархии тегов. Direct — сколько живых заметок несут
+// ровно этот тег, Total — сколько заметок несут его или любого потомка
+// (каждая заметка считается один раз). LastUsed — самое позднее время
+// изменения среди этих Total заметок.
+type TagCount struct {
+	Tag      string    `json:"tag"`
+	Direct   int       `json:"direct"`
+	Total    int       `json:"total"`
+	LastUsed time.Time `json:"last_used"`
+}
+
+var ErrEmptyTag = errors.New("empty tag")
+
+// TagCounts возвращает все теги живых заметок вместе с промежуточными
+// узлами иерархии (у них Direct == 0), упорядоченные по имени.
+func (s *Store) TagCounts() ([]TagCount, error) {
+	notes, err := s.liveNotes()
+	if err != nil {
+		return nil, err
+	}
+	return countTags(notes), nil
+}
+
+func countTags(notes []model.Note) []TagCount {
+	nodes := map[string]*TagCount{}
+	node := func(tag string) *TagCount {
+		c, ok := nodes[tag]
+		if !ok {
+			c = &TagCount{Tag: tag}
+			nodes[tag] = c
+		}
+		return c
+	}
+
+	for _, n := range notes {
+		direct := map[string]bool{}
+		total := map[string]bool{}
+		for _, t := range n.Tags {
+			prefixes := model.TagPrefixes(t)
+			if len(prefixes) == 0 {
+				continue
+			}
+			direct[prefixes[len(prefixes)-1]] = true
+			for _, p := range prefixes {
+				total[p] = true
+			}
+		}
+		for t := range direct {
+			node(t).Direct++
+		}
+		for t := range total {
+			c := node(t)
+			c.Total++
+			if n.UpdatedAt.After(c.LastUsed) {
+				c.LastUsed = n.UpdatedAt
+			}
+		}
+	}
+
+	out := make([]TagCount, 0, len(nodes))
+	for _, c := range nodes {
+		out = append(out, *c)
+	}
+	// Сортировка по сегментам, чтобы потомки шли сразу за родителем:
+	// "a/b" раньше "a-b", хотя '-' < '/'.
+	sort.Slice(out, func(i, j int) bool {
+		return tagLess(out[i].Tag, out[j].Tag)
+	})
+	return out
+}
+
+func tagLess(a, b string) bool {
+	pa, pb := model.TagPrefixes(a), model.TagPrefixes(b)
+	for k := 0; k < len(pa) && k < len(pb); k++ {
+		if pa[k] != pb[k] {
+			return lastSegment(pa[k]) < lastSegment(pb[k])
+		}
+	}
+	return len(pa) < len(pb)
+}
+
+func lastSegment(tag string) string {
+	return tag[strings.LastIndex(tag, model.TagSeparator)+1:]
+}
+
+// RenameTags переносит теги from вместе с потомками под to, сохраняя
+// хвост иерархии: при переименовании a в b тег a/x становится b/x.
+// Несколько from сливаются в один тег. Изменённые заметки записываются
+// одной пачкой; возвращается их число.
+func (s *Store) RenameTags(from []string, to string) (int, error) {
+	to = model.CleanTag(to)
+	if to == "" {
+		return 0, ErrEmptyTag
+	}
+	var olds []string
+	for _, f := range from {
+		f = model.CleanTag(f)
+		if f == "" {
+			return 0, ErrEmptyTag
+		}
+		olds = append(olds, f)
+	}
+	if len(olds) == 0 {
+		return 0, ErrEmptyTag
+	}
+	return s.rewriteTags(func(tag string) (string, bool) {
+		for _, old := range olds {
+			if model.TagUnder(tag, old) {
+				return to + model.CleanTag(tag)[len(old):], true
+			}
+		}
+		return tag, true
+	})
+}
+
+// RemoveTag убирает тег и всех его потомков из живых заметок и
+// возвращает число изменённых заметок.
+func (s *Store) RemoveTag(tag string) (int, error) {
+	tag = model.CleanTag(tag)
+	if tag == "" {
+		return 0, ErrEmptyTag
+	}
+	return s.rewriteTags(func(t string) (string, bool) {
+		return t, !model.TagUnder(t, tag)
+	})
+}
+
+// rewriteTags применяет f к каждому тегу живых заметок (false — тег
+// удаляется) и записывает новые версии заметок, у которых набор тегов
+// изменился.
+func (s *Store) rewriteTags(f func(string) (string, bool)) (int, error) {
+	if s.readOnly {
+		return 0, ErrReadOnly
+	}
+	notes, err := s.liveNotes()
+	if err != nil {
+		return 0, err
+	}
+	now := time.Now().UTC()
+	var changed []*model.Note
+	for _, n := range notes {
+		tags := make([]string, 0, len(n.Tags))
+		seen := map[string]bool{}
+		for _, t := range n.Tags {
+			t, keep := f(t)
+			if !keep || seen[t] {
+				continue
+			}
+			seen[t] = true
+			tags = append(tags, t)
+		}
+		if slices.Equal(tags, n.Tags) {
+			continue
+		}
+		n := n
+		n.Tags = tags
+		n.UpdatedAt = now
+		changed = append(changed, &n)
+	}
+	if len(changed) == 0 {
+		return 0, nil
+	}
+	sort.Slice(changed, func(i, j int) bool { return changed[i].ID < changed[j].ID })
+	return len(changed), s.AppendBatch(changed)
+}
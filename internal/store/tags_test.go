@@ -0,0 +1,171 @@
+package store
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Victor3563/NoteLine/cli-notebook/internal/model"
+)
+
+func TestTagCounts(t *testing.T) {
+	s, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer s.Close()
+
+	for _, tags := range [][]string{
+		{"project/noteline/bugs", "project/noteline"},
+		{"project/noteline/docs"},
+		{"project/other", "go"},
+		{"project-x"},
+	} {
+		if err := s.Append(model.NewNote("n", "t", tags)); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+	gone := model.NewNote("gone", "t", []string{"project/noteline/bugs"})
+	if err := s.Append(gone); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+	gone.Deleted = true
+	if err := s.Append(gone); err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+
+	got, err := s.TagCounts()
+	if err != nil {
+		t.Fatalf("TagCounts: %v", err)
+	}
+	want := []TagCount{
+		{Tag: "go", Direct: 1, Total: 1},
+		{Tag: "project", Direct: 0, Total: 3},
+		{Tag: "project/noteline", Direct: 1, Total: 2},
+		{Tag: "project/noteline/bugs", Direct: 1, Total: 1},
+		{Tag: "project/noteline/docs", Direct: 1, Total: 1},
+		{Tag: "project/other", Direct: 1, Total: 1},
+		{Tag: "project-x", Direct: 1, Total: 1},
+	}
+	for i := range got {
+		if got[i].LastUsed.IsZero() {
+			t.Fatalf("TagCounts: %s has no LastUsed", got[i].Tag)
+		}
+		got[i].LastUsed = time.Time{}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("TagCounts = %+v, want %+v", got, want)
+	}
+}
+
+func TestSearchTagHierarchy(t *testing.T) {
+	s, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer s.Close()
+
+	for _, title := range []string{"project/noteline/bugs", "project/noteline", "project/notes", "project"} {
+		if err := s.Append(model.NewNote(title, "x", []string{title})); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+
+	titles := func(f Filter) string {
+		t.Helper()
+		f.Sort = SortTitle
+		hits, err := s.Search(f)
+		if err != nil {
+			t.Fatalf("Search(%+v): %v", f, err)
+		}
+		var out []string
+		for _, h := range hits {
+			out = append(out, h.Title)
+		}
+		return strings.Join(out, " ")
+	}
+	cases := []struct {
+		f    Filter
+		want string
+	}{
+		{Filter{Tags: []string{"project/noteline"}}, "project/noteline project/noteline/bugs"},
+		{Filter{Tags: []string{"project/noteline/"}}, "project/noteline project/noteline/bugs"},
+		{Filter{Tags: []string{"project"}, NotTags: []string{"project/noteline"}}, "project project/notes"},
+		{Filter{AnyTags: []string{"project/notes", "project/noteline/bugs"}}, "project/noteline/bugs project/notes"},
+		{Filter{Query: "tag:project/noteline -tag:project/noteline/bugs"}, "project/noteline"},
+		{Filter{Tags: []string{"project/note"}}, ""},
+	}
+	for _, c := range cases {
+		if got := titles(c.f); got != c.want {
+			t.Fatalf("Search(%+v) = %q, want %q", c.f, got, c.want)
+		}
+	}
+}
+
+func TestRenameAndRemoveTags(t *testing.T) {
+	s, err := Open(t.TempDir())
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer s.Close()
+
+	a := model.NewNote("a", "t", []string{"work/deploy", "todo"})
+	b := model.NewNote("b", "t", []string{"job", "work"})
+	c := model.NewNote("c", "t", []string{"workshop"})
+	for _, n := range []*model.Note{a, b, c} {
+		if err := s.Append(n); err != nil {
+			t.Fatalf("Append: %v", err)
+		}
+	}
+	tagsOf := func(id string) []string {
+		n, err := s.GetByID(id)
+		if err != nil {
+			t.Fatalf("Get: %v", err)
+		}
+		return n.Tags
+	}
+
+	// Слияние: work и job становятся office, потомки переезжают,
+	// дубликаты схлопываются, workshop не затрагивается.
+	n, err := s.RenameTags([]string{"work", "job"}, "office")
+	if err != nil || n != 2 {
+		t.Fatalf("RenameTags = %d, %v", n, err)
+	}
+	if got := tagsOf(a.ID); !reflect.DeepEqual(got, []string{"office/deploy", "todo"}) {
+		t.Fatalf("a tags = %v", got)
+	}
+	if got := tagsOf(b.ID); !reflect.DeepEqual(got, []string{"office"}) {
+		t.Fatalf("b tags = %v", got)
+	}
+	if got := tagsOf(c.ID); !reflect.DeepEqual(got, []string{"workshop"}) {
+		t.Fatalf("c tags = %v", got)
+	}
+	if v, err := s.History(a.ID); err != nil || len(v) != 2 {
+		t.Fatalf("Versions(a) = %d, %v", len(v), err)
+	}
+
+	hits, err := s.Search(Filter{Tags: []string{"office"}})
+	if err != nil || len(hits) != 2 {
+		t.Fatalf("Search(office) = %d hits, %v", len(hits), err)
+	}
+	hits, err = s.Search(Filter{Query: "tag:work"})
+	if err != nil || len(hits) != 0 {
+		t.Fatalf("Search(tag:work) = %d hits, %v", len(hits), err)
+	}
+
+	n, err = s.RemoveTag("office")
+	if err != nil || n != 2 {
+		t.Fatalf("RemoveTag = %d, %v", n, err)
+	}
+	if got := tagsOf(a.ID); !reflect.DeepEqual(got, []string{"todo"}) {
+		t.Fatalf("a tags = %v", got)
+	}
+	if n, err := s.RemoveTag("missing"); err != nil || n != 0 {
+		t.Fatalf("RemoveTag(missing) = %d, %v", n, err)
+	}
+	if _, err := s.RenameTags([]string{"todo"}, " / "); !errors.Is(err, ErrEmptyTag) {
+		t.Fatalf("RenameTags to empty: %v", err)
+	}
+}
@@ -0,0 +1,90 @@
+package store
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// TimeLayouts — абсолютные форматы времени, которые понимают импорт,
+// restore --at и фильтры по дате; сначала самые точные.
+var TimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02T15:04",
+	"2006-01-02",
+	"2006-01",
+	"2006",
+}
+
+// ParseTimeBound разбирает момент для фильтров по дате и возвращает
+// интервал [from, to), который он обозначает: неполная дата — весь день,
+// месяц или год, RFC3339 и относительный срок — точку (from == to).
+// Кроме TimeLayouts понимаются now, today, yesterday и сроки назад от now:
+// 30m, 12h, 7d, 2w. Даты без зоны берутся в зоне now.
+func ParseTimeBound(s string, now time.Time) (from, to time.Time, err error) {
+	s = strings.TrimSpace(s)
+	loc := now.Location()
+	y, m, d := now.Date()
+	today := time.Date(y, m, d, 0, 0, 0, 0, loc)
+
+	switch strings.ToLower(s) {
+	case "now":
+		return now, now, nil
+	case "today":
+		return today, today.AddDate(0, 0, 1), nil
+	case "yesterday":
+		return today.AddDate(0, 0, -1), today, nil
+	}
+	if t, ok := parseAgo(s, now); ok {
+		return t, t, nil
+	}
+
+	for _, l := range TimeLayouts {
+		t, err := time.ParseInLocation(l, s, loc)
+		if err != nil {
+			continue
+		}
+		switch l {
+		case time.RFC3339:
+			return t, t, nil
+		case "2006-01-02 15:04:05", "2006-01-02T15:04:05":
+			return t, t.Add(time.Second), nil
+		case "2006-01-02 15:04", "2006-01-02T15:04":
+			return t, t.Add(time.Minute), nil
+		case "2006-01-02":
+			return t, t.AddDate(0, 0, 1), nil
+		case "2006-01":
+			return t, t.AddDate(0, 1, 0), nil
+		case "2006":
+			return t, t.AddDate(1, 0, 0), nil
+		}
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("unrecognized time %q (YYYY-MM-DD, RFC3339, 7d, yesterday, ...)", s)
+}
+
+// parseAgo разбирает срок вида 7d: дни и недели считаются по календарю,
+// минуты и часы — точно.
+func parseAgo(s string, now time.Time) (time.Time, bool) {
+	if len(s) < 2 {
+		return time.Time{}, false
+	}
+	k, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil || k < 0 || strings.ContainsAny(s[:1], "+-") {
+		return time.Time{}, false
+	}
+	switch s[len(s)-1] {
+	case 'm':
+		return now.Add(-time.Duration(k) * time.Minute), true
+	case 'h':
+		return now.Add(-time.Duration(k) * time.Hour), true
+	case 'd':
+		return now.AddDate(0, 0, -k), true
+	case 'w':
+		return now.AddDate(0, 0, -7*k), true
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,46 @@
+package store
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTimeBound(t *testing.T) {
+	loc := time.FixedZone("test", 3*3600)
+	now := time.Date(2025, 3, 10, 15, 30, 0, 0, loc)
+	day := func(y int, m time.Month, d int) time.Time { return time.Date(y, m, d, 0, 0, 0, 0, loc) }
+
+	cases := []struct {
+		in       string
+		from, to time.Time
+	}{
+		{"now", now, now},
+		{"today", day(2025, 3, 10), day(2025, 3, 11)},
+		{"Yesterday", day(2025, 3, 9), day(2025, 3, 10)},
+		{"7d", now.AddDate(0, 0, -7), now.AddDate(0, 0, -7)},
+		{"2w", now.AddDate(0, 0, -14), now.AddDate(0, 0, -14)},
+		{"12h", now.Add(-12 * time.Hour), now.Add(-12 * time.Hour)},
+		{"30m", now.Add(-30 * time.Minute), now.Add(-30 * time.Minute)},
+		{"2025-01-02", day(2025, 1, 2), day(2025, 1, 3)},
+		{"2025-01", day(2025, 1, 1), day(2025, 2, 1)},
+		{"2024", day(2024, 1, 1), day(2025, 1, 1)},
+		{"2025-01-02 10:20", day(2025, 1, 2).Add(10*time.Hour + 20*time.Minute), day(2025, 1, 2).Add(10*time.Hour + 21*time.Minute)},
+		{"2025-01-02T10:20:30", day(2025, 1, 2).Add(10*time.Hour + 20*time.Minute + 30*time.Second), day(2025, 1, 2).Add(10*time.Hour + 20*time.Minute + 31*time.Second)},
+		{"2025-01-02T10:20:30Z", time.Date(2025, 1, 2, 10, 20, 30, 0, time.UTC), time.Date(2025, 1, 2, 10, 20, 30, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		from, to, err := ParseTimeBound(c.in, now)
+		if err != nil {
+			t.Fatalf("ParseTimeBound(%q): %v", c.in, err)
+		}
+		if !from.Equal(c.from) || !to.Equal(c.to) {
+			t.Fatalf("ParseTimeBound(%q) = [%v, %v), want [%v, %v)", c.in, from, to, c.from, c.to)
+		}
+	}
+
+	for _, in := range []string{"", "d", "-7d", "7y", "last week", "2025-13-01"} {
+		if _, _, err := ParseTimeBound(in, now); err == nil {
+			t.Fatalf("ParseTimeBound(%q) succeeded", in)
+		}
+	}
+}